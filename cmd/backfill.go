@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/fetcher"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// HistorySource yields one day of rates per call and io.EOF when exhausted
+type HistorySource interface {
+	Next() ([]models.ExchangeRate, error)
+}
+
+type backfillOptions struct {
+	batchSize  int
+	currencies []string
+	from, to   time.Time
+	runID      string // fetch run the stored rates are attributed to
+	path       string // where the history is read from, journaled as the run's source URL
+}
+
+// backfillStats summarises a backfill run
+type backfillStats struct {
+	Days    int
+	Rates   int
+	Batches int
+	Oldest  time.Time
+	Newest  time.Time
+	Saved   models.SaveResult
+}
+
+func NewBackfillCmd(logger *slog.Logger, deps *Deps) *cobra.Command {
+	var (
+		opts     backfillOptions
+		from, to string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "backfill [path]",
+		Short: "Load historical rates from the ECB eurofxref-hist archive",
+		Long: "Load historical rates from the ECB eurofxref-hist archive.\n" +
+			"path is a local file or an http(s) URL of the zip archive or the extracted CSV, it defaults to " + fetcher.ECBHistoryURL,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := fetcher.ECBHistoryURL
+			if len(args) == 1 {
+				path = args[0]
+			}
+
+			if opts.batchSize < 1 {
+				return errors.New("batch size must be at least 1")
+			}
+
+			var err error
+
+			if opts.from, err = parseOptionalDate(from); err != nil {
+				return fmt.Errorf("invalid --from: %w", err)
+			}
+
+			if opts.to, err = parseOptionalDate(to); err != nil {
+				return fmt.Errorf("invalid --to: %w", err)
+			}
+
+			for i, currency := range opts.currencies {
+				opts.currencies[i] = strings.ToUpper(strings.TrimSpace(currency))
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts.runID = uuid.NewString()
+			opts.path = path
+
+			logger.Info("opening rate history", slog.String("path", path), slog.String("run_id", opts.runID))
+
+			file, err := fetcher.OpenECBHistory(ctx, &http.Client{}, path)
+			if err != nil {
+				return fmt.Errorf("open rate history: %w", err)
+			}
+			defer file.Close() // nolint:errcheck // We can't do much about a close error here
+
+			history, err := fetcher.NewECBHistoryReader(file)
+			if err != nil {
+				return fmt.Errorf("read rate history: %w", err)
+			}
+
+			stats, err := executeBackfill(ctx, logger, history, deps.ArchiveWriter, opts)
+			if err != nil {
+				return fmt.Errorf("failed to backfill rates: %w", err)
+			}
+
+			fmt.Printf("Loaded %d rates over %d days (%s to %s) in %d batches: %d new, %d updated, %d unchanged\n",
+				stats.Rates, stats.Days, stats.Oldest.Format(time.DateOnly), stats.Newest.Format(time.DateOnly), stats.Batches,
+				stats.Saved.Inserted, stats.Saved.Updated, stats.Saved.Unchanged)
+
+			return nil
+		},
+	}
+
+	// ------------ Flags --------------------
+
+	cmd.Flags().IntVar(&opts.batchSize, "batch-size", 1000, "Number of rates stored per database write")
+	cmd.Flags().StringSliceVarP(&opts.currencies, "currencies", "c", nil, "Comma-separated list of currency codes to load (default all)")
+	cmd.Flags().StringVar(&from, "from", "", "Skip days before this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "Skip days after this date (YYYY-MM-DD)")
+
+	return cmd
+}
+
+func parseOptionalDate(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.DateOnly, raw)
+}
+
+// executeBackfill streams the history into the writer in batches of at most opts.batchSize rates,
+// logging progress after every batch. A failed batch aborts the run; batches already written stay stored.
+// The run is journaled like a fetch.
+func executeBackfill(
+	ctx context.Context,
+	logger *slog.Logger,
+	history HistorySource,
+	rateWriter ExchangeRateWriter,
+	opts backfillOptions,
+) (backfillStats, error) {
+	run := models.FetchRun{
+		ID:         opts.runID,
+		Kind:       models.FetchRunKindBackfill,
+		Source:     fetcher.SourceECBHistory,
+		SourceURL:  opts.path,
+		Currencies: opts.currencies,
+		StartedAt:  time.Now().UTC(),
+	}
+
+	if err := rateWriter.StartFetchRun(ctx, run); err != nil {
+		return backfillStats{}, fmt.Errorf("journal backfill run: %w", err)
+	}
+
+	stats, err := loadHistory(ctx, logger, history, rateWriter, opts)
+
+	run.SaveResult = stats.Saved
+
+	if journalErr := finishFetchRun(ctx, rateWriter, &run, err); journalErr != nil {
+		err = errors.Join(err, fmt.Errorf("journal backfill run: %w", journalErr))
+	}
+
+	return stats, err
+}
+
+func loadHistory(
+	ctx context.Context,
+	logger *slog.Logger,
+	history HistorySource,
+	rateWriter ExchangeRateWriter,
+	opts backfillOptions,
+) (backfillStats, error) {
+	var stats backfillStats
+
+	batch := make([]models.ExchangeRate, 0, opts.batchSize)
+	start := time.Now()
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		result, err := rateWriter.SaveRates(ctx, opts.runID, batch)
+		if err != nil {
+			return fmt.Errorf("save batch %d: %w", stats.Batches+1, err)
+		}
+
+		stats.Saved.Inserted += result.Inserted
+		stats.Saved.Updated += result.Updated
+		stats.Saved.Unchanged += result.Unchanged
+
+		stats.Batches++
+		stats.Rates += len(batch)
+		batch = batch[:0]
+
+		logger.Info("backfill progress",
+			slog.Int("batches", stats.Batches),
+			slog.Int("rates", stats.Rates),
+			slog.Int("days", stats.Days),
+			slog.String("oldest", stats.Oldest.Format(time.DateOnly)),
+			slog.String("newest", stats.Newest.Format(time.DateOnly)),
+			slog.Duration("elapsed", time.Since(start)))
+
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		day, err := history.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return stats, err
+		}
+
+		if len(day) == 0 || !inRange(day[0].Date, opts.from, opts.to) {
+			continue
+		}
+
+		if len(opts.currencies) > 0 {
+			day = slices.DeleteFunc(day, func(rate models.ExchangeRate) bool {
+				return !slices.Contains(opts.currencies, rate.Currency)
+			})
+
+			if len(day) == 0 {
+				continue
+			}
+		}
+
+		stats.Days++
+
+		if date := day[0].Date; stats.Oldest.IsZero() || date.Before(stats.Oldest) {
+			stats.Oldest = date
+		}
+
+		if date := day[0].Date; date.After(stats.Newest) {
+			stats.Newest = date
+		}
+
+		for _, rate := range day {
+			batch = append(batch, rate)
+
+			if len(batch) == opts.batchSize {
+				if err := flush(); err != nil {
+					return stats, err
+				}
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// inRange reports whether date lies within the inclusive bounds, zero bounds are open
+func inRange(date, from, to time.Time) bool {
+	return (from.IsZero() || !date.Before(from)) && (to.IsZero() || !date.After(to))
+}
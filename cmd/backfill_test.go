@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type mockHistory struct {
+	days [][]models.ExchangeRate
+}
+
+func (m *mockHistory) Next() ([]models.ExchangeRate, error) {
+	if len(m.days) == 0 {
+		return nil, io.EOF
+	}
+
+	day := m.days[0]
+	m.days = m.days[1:]
+
+	return day, nil
+}
+
+type mockBatchWriter struct {
+	mockJournal
+
+	batches [][]models.ExchangeRate
+	err     error
+}
+
+func (m *mockBatchWriter) SaveRate(ctx context.Context, rate models.ExchangeRate) error {
+	return nil
+}
+
+func (m *mockBatchWriter) SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	if m.err != nil {
+		return models.SaveResult{}, m.err
+	}
+
+	m.batches = append(m.batches, slices.Clone(rates))
+
+	return models.SaveResult{Inserted: len(rates)}, nil
+}
+
+func (m *mockBatchWriter) QuarantineItems(ctx context.Context, runID string, items []models.QuarantinedItem) error {
+	return nil
+}
+
+func (m *mockBatchWriter) SaveDiscrepancies(ctx context.Context, runID string, discrepancies []models.RateDiscrepancy) error {
+	return nil
+}
+
+func TestExecuteBackfill(t *testing.T) {
+	t.Parallel()
+
+	day := func(date time.Time, currencies ...string) []models.ExchangeRate {
+		rates := make([]models.ExchangeRate, 0, len(currencies))
+		for _, currency := range currencies {
+			rates = append(rates, models.ExchangeRate{Currency: currency, Rate: decimal.NewFromFloat(1.15), Date: date})
+		}
+
+		return rates
+	}
+
+	date1 := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+	date2 := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	date3 := time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC)
+
+	history := func() [][]models.ExchangeRate {
+		return [][]models.ExchangeRate{
+			day(date1, "USD", "GBP", "JPY"),
+			day(date2, "USD", "GBP", "JPY"),
+			{},
+			day(date3, "USD", "GBP", "JPY"),
+		}
+	}
+
+	tests := []struct {
+		name          string
+		opts          backfillOptions
+		writerErr     error
+		expectedSizes []int
+		expectedStats backfillStats
+		expectErr     string
+	}{
+		{
+			name:          "Loads everything in bounded batches",
+			opts:          backfillOptions{batchSize: 4},
+			expectedSizes: []int{4, 4, 1},
+			expectedStats: backfillStats{Days: 3, Rates: 9, Batches: 3, Oldest: date3, Newest: date1, Saved: models.SaveResult{Inserted: 9}},
+		},
+		{
+			name:          "Filters currencies",
+			opts:          backfillOptions{batchSize: 1000, currencies: []string{"USD"}},
+			expectedSizes: []int{3},
+			expectedStats: backfillStats{Days: 3, Rates: 3, Batches: 1, Oldest: date3, Newest: date1, Saved: models.SaveResult{Inserted: 3}},
+		},
+		{
+			name:          "Filters date range",
+			opts:          backfillOptions{batchSize: 1000, from: date2, to: date2},
+			expectedSizes: []int{3},
+			expectedStats: backfillStats{Days: 1, Rates: 3, Batches: 1, Oldest: date2, Newest: date2, Saved: models.SaveResult{Inserted: 3}},
+		},
+		{
+			name:      "Aborts on write failure",
+			opts:      backfillOptions{batchSize: 4},
+			writerErr: errors.New("connection refused"),
+			expectErr: "save batch 1: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := &mockBatchWriter{err: tt.writerErr}
+
+			stats, err := executeBackfill(t.Context(), slog.Default(), &mockHistory{days: history()}, writer, tt.opts)
+
+			require.Len(t, writer.finished, 1, "run must be journaled")
+
+			run := writer.finished[0]
+			require.Equal(t, models.FetchRunKindBackfill, run.Kind)
+
+			if tt.expectErr != "" {
+				require.EqualError(t, err, tt.expectErr)
+				require.Equal(t, models.FetchRunFailed, run.Status)
+				require.Equal(t, tt.expectErr, run.Error)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, models.FetchRunSucceeded, run.Status)
+			require.Equal(t, stats.Saved, run.SaveResult)
+			require.Equal(t, tt.expectedStats, stats)
+
+			sizes := make([]int, 0, len(writer.batches))
+			for _, batch := range writer.batches {
+				sizes = append(sizes, len(batch))
+			}
+
+			require.Equal(t, tt.expectedSizes, sizes)
+		})
+	}
+}
@@ -2,54 +2,219 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"sync"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/anomaly"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/fetcher"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/webhook"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 type ExchangeRateFetcher interface {
-	GetAllRates(ctx context.Context) ([][]models.ExchangeRate, error)
-	GetCurrencyRates(ctx context.Context, currency string) ([]models.ExchangeRate, error)
+	GetFeed(ctx context.Context) (fetcher.Feed, error)
+	SourceURL(name string) string
+}
+
+// FetchRunJournal records what every fetch run did, including the runs that fail
+type FetchRunJournal interface {
+	StartFetchRun(ctx context.Context, run models.FetchRun) error
+	FinishFetchRun(ctx context.Context, run models.FetchRun) error
+}
+
+// FeedQuarantine keeps the feed items rejected by validation
+type FeedQuarantine interface {
+	QuarantineItems(ctx context.Context, runID string, items []models.QuarantinedItem) error
+}
+
+// DiscrepancyLog keeps the rates reconciled sources disagreed on
+type DiscrepancyLog interface {
+	SaveDiscrepancies(ctx context.Context, runID string, discrepancies []models.RateDiscrepancy) error
 }
 
 type ExchangeRateWriter interface {
 	SaveRate(ctx context.Context, rate models.ExchangeRate) error
-	SaveRates(ctx context.Context, rates []models.ExchangeRate) error
+	// SaveRates stores rates, attributing the changes they make to the fetch run runID
+	SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error)
+
+	FetchRunJournal
+	FeedQuarantine
+	DiscrepancyLog
+}
+
+// GuardableWriter is a writer the anomaly checks can read the recent history from and record anomalies in
+type GuardableWriter interface {
+	ExchangeRateWriter
+	anomaly.Store
+}
+
+// guardedWriter sends fetched rates through the anomaly checks before they are saved
+type guardedWriter struct {
+	ExchangeRateWriter
+	guard *anomaly.Guard
+}
+
+func (w guardedWriter) SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	return w.guard.SaveRates(ctx, runID, rates)
+}
+
+// newRateWriter returns the writer fetched rates are saved with, checking them for anomalies when enabled
+func newRateWriter(logger *slog.Logger, cfg config.AnomalyConfig, writer GuardableWriter) (ExchangeRateWriter, error) {
+	if !cfg.Enabled {
+		return writer, nil
+	}
+
+	anomalyCfg, err := anomaly.ParseConfig(cfg.Window, cfg.Thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	return guardedWriter{ExchangeRateWriter: writer, guard: anomaly.NewGuard(logger, writer, anomalyCfg)}, nil
+}
+
+// RateNotifier tells webhook subscribers about the rates a fetch run stored
+type RateNotifier interface {
+	Notify(ctx context.Context, runID string) error
 }
 
-type fetchResult struct {
-	Rates []models.ExchangeRate
-	Err   error
+// notifyingWriter notifies webhook subscribers once fetched rates are saved
+type notifyingWriter struct {
+	ExchangeRateWriter
+	logger   *slog.Logger
+	notifier RateNotifier
+}
+
+func (w notifyingWriter) SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	result, err := w.ExchangeRateWriter.SaveRates(ctx, runID, rates)
+	if err != nil || result.Inserted+result.Updated == 0 {
+		return result, err
+	}
+
+	// The rates are stored by now, failing to notify about them does not fail the fetch
+	if err := w.notifier.Notify(ctx, runID); err != nil {
+		w.logger.Error("failed to notify webhook subscribers", slog.String("run_id", runID), slog.Any("error", err))
+	}
+
+	return result, nil
+}
+
+// newNotifier returns the webhook notifier the rates fetched are announced with
+func newNotifier(logger *slog.Logger, cfg config.WebhookConfig, store webhook.Store) (*webhook.Notifier, error) {
+	if cfg.Timeout <= 0 {
+		return nil, fmt.Errorf("webhook timeout must be positive, got %s", cfg.Timeout)
+	}
+
+	if cfg.MaxAttempts < 1 {
+		return nil, fmt.Errorf("webhook max attempts must be at least 1, got %d", cfg.MaxAttempts)
+	}
+
+	if cfg.PollInterval <= 0 {
+		return nil, fmt.Errorf("webhook poll interval must be positive, got %s", cfg.PollInterval)
+	}
+
+	return webhook.NewNotifier(logger, store, webhook.Config{
+		Timeout:        cfg.Timeout,
+		MaxAttempts:    cfg.MaxAttempts,
+		RetryBaseDelay: cfg.RetryBaseDelay,
+		RetryMaxDelay:  cfg.RetryMaxDelay,
+		PollInterval:   cfg.PollInterval,
+
+		AllowPrivateTargets: cfg.AllowPrivateTargets,
+	}), nil
+}
+
+// fetchTimeout bounds a single fetch-and-store run
+const fetchTimeout = 20 * time.Second
+
+// How a fetch treats currencies missing from the feed
+const (
+	// failModeBestEffort stores the currencies found, missing ones make the run a partial success
+	failModeBestEffort = "best-effort"
+	// failModeAllOrNothing stores nothing unless every currency is found
+	failModeAllOrNothing = "all-or-nothing"
+	// failModeStrict stores the currencies found like best-effort, but any missing one fails the run
+	failModeStrict = "strict"
+)
+
+var failModes = []string{failModeBestEffort, failModeAllOrNothing, failModeStrict}
+
+// Outcomes of a fetch as reported to the caller
+const (
+	outcomeSuccess = "success"
+	outcomePartial = "partial"
+	outcomeFailure = "failure"
+)
+
+// Exit codes of the fetch command besides 0 for success and 1 for usage and configuration errors
+const (
+	exitCodePartial = 2
+	exitCodeFailure = 3
+)
+
+// fetchSummary is the machine-readable result of a fetch, printed to stdout
+type fetchSummary struct {
+	models.FetchRun
+	FailMode string                `json:"fail_mode"`
+	Outcome  string                `json:"outcome"`
+	Rates    []models.ExchangeRate `json:"rates"`
 }
 
-func NewFetchCmd(logger *slog.Logger, writerSvc ExchangeRateWriter) *cobra.Command {
+func NewFetchCmd(logger *slog.Logger, cfg *config.FetcherConfig, deps *Deps) *cobra.Command {
+	var failMode string
+
 	cmd := &cobra.Command{
 		Use:   "fetch",
-		Short: "Fetch latest currency rates from Bank.lv",
+		Short: "Fetch latest currency rates from the configured sources",
+		Long: "Fetch latest currency rates from the configured sources.\n" +
+			"A JSON summary of the run is printed to stdout. The exit code is 0 on success, " +
+			strconv.Itoa(exitCodePartial) + " when only some currencies were stored and " +
+			strconv.Itoa(exitCodeFailure) + " when the run failed.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			fetcherSvc := fetcher.NewBankLatviaFetcher(logger, &http.Client{Timeout: 30 * time.Second}, "https://www.bank.lv/vk/ecb_rss.xml")
-
-			ctx, cancel := context.WithTimeout(cmd.Context(), 20*time.Second)
-			defer cancel()
+			if !slices.Contains(failModes, failMode) {
+				return fmt.Errorf("unknown fail mode %q, expected one of %s", failMode, strings.Join(failModes, ", "))
+			}
 
-			rates, err := executeFetch(ctx, fetcherSvc, writerSvc, viper.GetStringSlice("currencies"))
+			fetcherSvc, err := newRateFetcher(logger, *cfg)
 			if err != nil {
-				return fmt.Errorf("failed to fetch rates: %w", err)
+				return err
 			}
 
-			for _, rate := range rates {
-				fmt.Printf("Currency: %s, Rate: %s, Date: %s\n", rate.Currency, rate.Rate, rate.Date.Format(time.DateOnly))
+			// Errors are reported through the summary and the exit code, usage would only clutter the output
+			cmd.SilenceUsage = true
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), fetchTimeout)
+			defer cancel()
+
+			summary, err := executeFetch(ctx, fetcherSvc, deps.RateWriter, viper.GetStringSlice("currencies"), failMode)
+
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+
+			if encodeErr := encoder.Encode(summary); encodeErr != nil {
+				return fmt.Errorf("print summary: %w", encodeErr)
 			}
 
-			return nil
+			switch summary.Outcome {
+			case outcomeSuccess:
+				return nil
+			case outcomePartial:
+				return &exitError{code: exitCodePartial, err: fmt.Errorf("some rates were not fetched: %w", err)}
+			default:
+				return &exitError{code: exitCodeFailure, err: fmt.Errorf("failed to fetch rates: %w", err)}
+			}
 		},
 	}
 
@@ -61,46 +226,327 @@ func NewFetchCmd(logger *slog.Logger, writerSvc ExchangeRateWriter) *cobra.Comma
 		logger.Error("bind flag failed", "flag", "currencies", "error", err)
 	}
 
+	cmd.Flags().StringVar(&failMode, "fail-mode", failModeBestEffort,
+		"How currencies missing from the feed are handled ("+strings.Join(failModes, ", ")+")")
+
+	addSourceFlag(cmd, cfg)
+
 	return cmd
 }
 
+func addSourceFlag(cmd *cobra.Command, cfg *config.FetcherConfig) {
+	available := strings.Join([]string{fetcher.SourceBankLatvia, fetcher.SourceECB, fetcher.SourceECB90Days}, ", ")
+
+	cmd.Flags().StringSliceVar(&cfg.Sources, "source", cfg.Sources,
+		"Rate sources in order of preference, later ones are fallbacks ("+available+")")
+
+	policies := make([]string, 0, len(fetcher.ConsensusPolicies))
+	for _, policy := range fetcher.ConsensusPolicies {
+		policies = append(policies, string(policy))
+	}
+
+	cmd.Flags().StringVar(&cfg.ReconcilePolicy, "reconcile", cfg.ReconcilePolicy,
+		"Ask every source and store the rate chosen by this policy ("+strings.Join(policies, ", ")+"), empty to use fallbacks")
+}
+
+// newRateFetcher returns the source registry with the configured sources selected, retrying transient
+// download failures, guarding every source with a circuit breaker and reconciling the sources when configured
+func newRateFetcher(logger *slog.Logger, cfg config.FetcherConfig) (*fetcher.Registry, error) {
+	opts := []fetcher.RegistryOption{
+		fetcher.WithBreaker(fetcher.BreakerConfig{
+			FailureThreshold: cfg.BreakerThreshold,
+			OpenTimeout:      cfg.BreakerOpenTimeout,
+		}),
+	}
+
+	if cfg.ReconcilePolicy != "" {
+		reconcileCfg, err := reconcileConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, fetcher.WithReconciliation(reconcileCfg))
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: fetcher.NewRetryTransport(logger, nil, fetcher.RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			BaseDelay:   cfg.RetryBaseDelay,
+			MaxDelay:    cfg.RetryMaxDelay,
+		}),
+	}
+
+	registry := fetcher.NewDefaultRegistry(logger, client, opts...)
+
+	if err := registry.Select(cfg.Sources...); err != nil {
+		return nil, fmt.Errorf("select rate sources: %w", err)
+	}
+
+	return registry, nil
+}
+
+func reconcileConfig(cfg config.FetcherConfig) (fetcher.ReconcileConfig, error) {
+	policy, err := fetcher.ParseConsensusPolicy(cfg.ReconcilePolicy)
+	if err != nil {
+		return fetcher.ReconcileConfig{}, err
+	}
+
+	tolerance, err := decimal.NewFromString(cfg.ReconcileTolerance)
+	if err != nil || tolerance.IsNegative() {
+		return fetcher.ReconcileConfig{}, fmt.Errorf("invalid reconcile tolerance %q, expected a non-negative fraction",
+			cfg.ReconcileTolerance)
+	}
+
+	return fetcher.ReconcileConfig{Policy: policy, Tolerance: tolerance}, nil
+}
+
+// executeFetch downloads the feed once, picks the requested currencies out of it and stores them as failMode
+// allows. The returned error joins every problem, the summary's outcome tells how bad they were.
+// Every call is journaled as a fetch run, including the ones that fail.
 func executeFetch(
 	ctx context.Context,
 	exchangeRateFetcher ExchangeRateFetcher,
 	rateWriter ExchangeRateWriter,
 	currencies []string,
-) ([]models.ExchangeRate, error) {
-	results := make(chan fetchResult, len(currencies))
-	var wg sync.WaitGroup
+	failMode string,
+) (fetchSummary, error) {
+	summary := fetchSummary{
+		FetchRun: models.FetchRun{
+			ID:         uuid.NewString(),
+			Kind:       models.FetchRunKindFetch,
+			Currencies: currencies,
+			StartedAt:  time.Now().UTC(),
+		},
+		FailMode: failMode,
+		Outcome:  outcomeFailure,
+		Rates:    []models.ExchangeRate{},
+	}
+
+	if err := rateWriter.StartFetchRun(ctx, summary.FetchRun); err != nil {
+		recordFetch(feedSource(nil), metrics.ResultFailure, currencies...)
+
+		summary.Status = models.FetchRunFailed
+		summary.Error = err.Error()
+
+		return summary, fmt.Errorf("journal fetch run: %w", err)
+	}
+
+	rates, currencyErrs, fatal := fetchRates(ctx, exchangeRateFetcher, rateWriter, &summary.FetchRun, failMode)
+
+	errs := currencyErrs
+	if fatal != nil {
+		errs = append(errs, fatal)
+	}
+
+	if err := finishFetchRun(ctx, rateWriter, &summary.FetchRun, fatal); err != nil {
+		errs = append(errs, fmt.Errorf("journal fetch run: %w", err))
+	}
+
+	if rates != nil {
+		summary.Rates = rates
+	}
+
+	switch {
+	case summary.Status == models.FetchRunSucceeded:
+		summary.Outcome = outcomeSuccess
+	case summary.Status == models.FetchRunPartial && failMode != failModeStrict:
+		summary.Outcome = outcomePartial
+	}
+
+	return summary, errors.Join(errs...)
+}
+
+// fetchRates does the work of a fetch run and fills in what the journal records about it. It returns the stored
+// rates, the currencies missing from the feed and, when nothing could be stored, a fatal error.
+func fetchRates(
+	ctx context.Context,
+	exchangeRateFetcher ExchangeRateFetcher,
+	rateWriter ExchangeRateWriter,
+	run *models.FetchRun,
+	failMode string,
+) (stored []models.ExchangeRate, currencyErrs []error, fatal error) {
+	feed, err := exchangeRateFetcher.GetFeed(ctx)
+
+	// Rejected items are kept even when the fetch failed, they may be why it did
+	quarantineErr := quarantineItems(ctx, rateWriter, run, feed.Rejected)
 
-	for _, curr := range currencies {
-		wg.Add(1)
-		go func(c string) {
-			defer wg.Done()
+	if err != nil {
+		recordFetch(feedSource(nil), metrics.ResultFailure, run.Currencies...)
 
-			rates, err := exchangeRateFetcher.GetCurrencyRates(ctx, c)
-			results <- fetchResult{Rates: rates, Err: err}
-		}(curr)
+		return nil, nil, errors.Join(fmt.Errorf("fetch rates: %w", err), quarantineErr)
 	}
 
-	wg.Wait()
-	close(results)
+	// Storing the valid rates while losing track of the rejected ones would hide that the feed was incomplete
+	if quarantineErr != nil {
+		recordFetch(feedSource(feed.Rates), metrics.ResultFailure, run.Currencies...)
+
+		return nil, nil, quarantineErr
+	}
+
+	// Likewise losing the discrepancies would hide that the sources disagreed on the rates stored
+	if err := saveDiscrepancies(ctx, rateWriter, run, feed.Discrepancies); err != nil {
+		recordFetch(feedSource(feed.Rates), metrics.ResultFailure, run.Currencies...)
+
+		return nil, nil, err
+	}
+
+	feedRates := feed.Rates
+	source := feedSource(feedRates)
+
+	run.Source = source
+	run.SourceURL = exchangeRateFetcher.SourceURL(source)
 
 	var allRates []models.ExchangeRate
-	var errs []error
+	var found []string
+
+	for _, curr := range run.Currencies {
+		rates := fetcher.FilterCurrency(feedRates, curr)
+		if len(rates) == 0 {
+			recordFetch(source, metrics.ResultFailure, curr)
+
+			currencyErrs = append(currencyErrs, currencyError(run, curr, fetcher.ErrRateNotFound))
 
-	for res := range results {
-		if res.Err != nil {
-			errs = append(errs, res.Err)
 			continue
 		}
 
-		allRates = append(allRates, res.Rates...)
+		allRates = append(allRates, rates...)
+		found = append(found, curr)
 	}
 
-	if err := rateWriter.SaveRates(ctx, allRates); err != nil {
-		errs = append(errs, fmt.Errorf("failed to save rates: %w", err))
+	if failMode == failModeAllOrNothing && len(currencyErrs) > 0 {
+		recordFetch(source, metrics.ResultFailure, found...)
+
+		return nil, currencyErrs, fmt.Errorf("%d of %d currencies missing, nothing stored in %s mode",
+			len(currencyErrs), len(run.Currencies), failModeAllOrNothing)
 	}
 
-	return allRates, errors.Join(errs...)
+	// All rates go into one transaction, a failed save stores none of them
+	result, err := rateWriter.SaveRates(ctx, run.ID, allRates)
+	if err != nil {
+		recordFetch(source, metrics.ResultFailure, found...)
+
+		return nil, currencyErrs, fmt.Errorf("failed to save rates: %w", err)
+	}
+
+	run.SaveResult = result
+
+	// Rates the anomaly checks held or rejected were not stored, a currency left without any is not a success
+	stored = withoutRates(allRates, result.Withheld)
+
+	for _, curr := range found {
+		if slices.ContainsFunc(stored, func(r models.ExchangeRate) bool { return r.Currency == curr }) {
+			recordFetch(source, metrics.ResultSuccess, curr)
+
+			continue
+		}
+
+		recordFetch(source, metrics.ResultWithheld, curr)
+
+		currencyErrs = append(currencyErrs, currencyError(run, curr, errRatesWithheld))
+	}
+
+	return stored, currencyErrs, nil
+}
+
+// errRatesWithheld is the error of a currency whose every fetched rate the anomaly checks held or rejected
+var errRatesWithheld = errors.New("rates withheld by the anomaly checks for currency")
+
+// currencyError records in the run that currency was not stored because of err
+func currencyError(run *models.FetchRun, currency string, err error) error {
+	err = fmt.Errorf("%w '%s'", err, currency)
+
+	if run.CurrencyErrors == nil {
+		run.CurrencyErrors = make(map[string]string)
+	}
+
+	run.CurrencyErrors[currency] = err.Error()
+
+	return err
+}
+
+// withoutRates returns rates without the ones of the same currency, date and value as a rate in removed
+func withoutRates(rates, removed []models.ExchangeRate) []models.ExchangeRate {
+	if len(removed) == 0 {
+		return rates
+	}
+
+	return slices.DeleteFunc(slices.Clone(rates), func(rate models.ExchangeRate) bool {
+		return slices.ContainsFunc(removed, func(r models.ExchangeRate) bool {
+			return r.Currency == rate.Currency && r.Date.Equal(rate.Date) && r.Rate.Equal(rate.Rate)
+		})
+	})
+}
+
+// quarantineItems stores the items the feed rejected and counts them in the run
+func quarantineItems(ctx context.Context, quarantine FeedQuarantine, run *models.FetchRun, items []models.QuarantinedItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	if err := quarantine.QuarantineItems(ctx, run.ID, items); err != nil {
+		return fmt.Errorf("store rejected feed items: %w", err)
+	}
+
+	run.Quarantined = len(items)
+
+	return nil
+}
+
+// saveDiscrepancies stores the rates the reconciled sources disagreed on
+func saveDiscrepancies(ctx context.Context, log DiscrepancyLog, run *models.FetchRun, discrepancies []models.RateDiscrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+
+	if err := log.SaveDiscrepancies(ctx, run.ID, discrepancies); err != nil {
+		return fmt.Errorf("store rate discrepancies: %w", err)
+	}
+
+	return nil
+}
+
+// journalTimeout bounds recording the outcome of a run, which happens even when the run itself timed out
+const journalTimeout = 5 * time.Second
+
+// finishFetchRun stamps the run's outcome and records it
+func finishFetchRun(ctx context.Context, journal FetchRunJournal, run *models.FetchRun, fatal error) error {
+	finishedAt := time.Now().UTC()
+	run.FinishedAt = &finishedAt
+
+	switch {
+	case fatal != nil:
+		run.Status = models.FetchRunFailed
+		run.Error = fatal.Error()
+	case len(run.CurrencyErrors) == len(run.Currencies) && len(run.Currencies) > 0:
+		run.Status = models.FetchRunFailed
+	case len(run.CurrencyErrors) > 0:
+		run.Status = models.FetchRunPartial
+	default:
+		run.Status = models.FetchRunSucceeded
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), journalTimeout)
+	defer cancel()
+
+	return journal.FinishFetchRun(ctx, *run)
+}
+
+// feedSource returns the name of the source that served the feed, "none" when no source did
+func feedSource(feedRates [][]models.ExchangeRate) string {
+	for _, rates := range feedRates {
+		for _, rate := range rates {
+			if rate.Source != "" {
+				return rate.Source
+			}
+		}
+	}
+
+	return "none"
+}
+
+func recordFetch(source, result string, currencies ...string) {
+	for _, currency := range currencies {
+		metrics.RateFetches.WithLabelValues(source, currency, result).Inc()
+	}
 }
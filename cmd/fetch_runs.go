@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/spf13/cobra"
+)
+
+type FetchRunLister interface {
+	ListFetchRuns(ctx context.Context, q models.FetchRunQuery) ([]models.FetchRun, error)
+}
+
+// fetchRunsTimeout bounds reading the run journal
+const fetchRunsTimeout = 30 * time.Second
+
+func NewFetchRunsCmd(deps *Deps) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fetch-runs",
+		Short: "Inspect the journal of fetch and backfill runs",
+	}
+
+	var q models.FetchRunQuery
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List runs, most recent first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if q.Status != "" && !slices.Contains(models.FetchRunStatuses, q.Status) {
+				return fmt.Errorf("unknown status %q, expected one of %s", q.Status, strings.Join(models.FetchRunStatuses, ", "))
+			}
+
+			if q.Limit < 0 {
+				return fmt.Errorf("limit must not be negative, got %d", q.Limit)
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), fetchRunsTimeout)
+			defer cancel()
+
+			runs, err := deps.FetchRuns.ListFetchRuns(ctx, q)
+			if err != nil {
+				return fmt.Errorf("failed to list fetch runs: %w", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tKIND\tSTATUS\tSTARTED AT\tDURATION\tSOURCE\tCURRENCIES\tNEW\tUPDATED\tUNCHANGED\tQUARANTINED\tFLAGGED\tHELD\tREJECTED\tERRORS") // nolint:errcheck // Printing to stdout
+
+			for _, run := range runs {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n", // nolint:errcheck // Printing to stdout
+					run.ID, run.Kind, run.Status, run.StartedAt.Format(time.RFC3339), runDuration(run),
+					run.Source, strings.Join(run.Currencies, ","),
+					run.Inserted, run.Updated, run.Unchanged, run.Quarantined,
+					run.Flagged, run.Held, run.Rejected, runErrors(run))
+			}
+
+			return w.Flush()
+		},
+	}
+
+	list.Flags().IntVarP(&q.Limit, "limit", "n", 20, "Maximum number of runs to list, 0 for all")
+	list.Flags().StringVar(&q.Status, "status", "",
+		"Only list runs with this status ("+strings.Join(models.FetchRunStatuses, ", ")+")")
+
+	cmd.AddCommand(list)
+
+	return cmd
+}
+
+func runDuration(run models.FetchRun) string {
+	if run.FinishedAt == nil {
+		return "-"
+	}
+
+	return run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond).String()
+}
+
+// runErrors flattens the run error and the per-currency errors into one column
+func runErrors(run models.FetchRun) string {
+	var errs []string
+
+	if run.Error != "" {
+		errs = append(errs, run.Error)
+	}
+
+	currencies := make([]string, 0, len(run.CurrencyErrors))
+	for currency := range run.CurrencyErrors {
+		currencies = append(currencies, currency)
+	}
+
+	slices.Sort(currencies)
+
+	for _, currency := range currencies {
+		errs = append(errs, run.CurrencyErrors[currency])
+	}
+
+	return strings.Join(errs, "; ")
+}
@@ -2,20 +2,80 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/fetcher"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
 
+// mockJournal keeps the fetch runs it is given
+type mockJournal struct {
+	started  []models.FetchRun
+	finished []models.FetchRun
+}
+
+func (m *mockJournal) StartFetchRun(ctx context.Context, run models.FetchRun) error {
+	m.started = append(m.started, run)
+
+	return nil
+}
+
+func (m *mockJournal) FinishFetchRun(ctx context.Context, run models.FetchRun) error {
+	m.finished = append(m.finished, run)
+
+	return nil
+}
+
 type mockFetcher struct {
+	mockJournal
+
 	latestRates []models.ExchangeRate
+	rejected    []models.QuarantinedItem
+	diverging   []models.RateDiscrepancy
+	calls       atomic.Int32
+	saved       []models.ExchangeRate
+	quarantined []models.QuarantinedItem
+	flagged     []models.RateDiscrepancy
+	hold        []string // currencies whose rates SaveRates holds back, as the anomaly checks would
 }
 
-func (m *mockFetcher) GetAllRates(ctx context.Context) ([][]models.ExchangeRate, error) {
-	return [][]models.ExchangeRate{m.latestRates}, nil
+func (m *mockFetcher) GetFeed(ctx context.Context) (fetcher.Feed, error) {
+	m.calls.Add(1)
+
+	if len(m.latestRates) == 0 {
+		return fetcher.Feed{Rejected: m.rejected}, fetcher.ErrNoRatesFound
+	}
+
+	return fetcher.Feed{
+		Rates:         [][]models.ExchangeRate{m.latestRates},
+		Rejected:      m.rejected,
+		Discrepancies: m.diverging,
+	}, nil
+}
+
+func (m *mockFetcher) QuarantineItems(ctx context.Context, runID string, items []models.QuarantinedItem) error {
+	m.quarantined = append(m.quarantined, items...)
+
+	return nil
+}
+
+func (m *mockFetcher) SaveDiscrepancies(ctx context.Context, runID string, discrepancies []models.RateDiscrepancy) error {
+	m.flagged = append(m.flagged, discrepancies...)
+
+	return nil
+}
+
+func (m *mockFetcher) SourceURL(name string) string {
+	return "https://rates.example/" + name
 }
 
 func (m *mockFetcher) GetCurrencyRates(ctx context.Context, currency string) ([]models.ExchangeRate, error) {
@@ -37,8 +97,22 @@ func (m *mockFetcher) SaveRate(ctx context.Context, rate models.ExchangeRate) er
 	return nil
 }
 
-func (m *mockFetcher) SaveRates(ctx context.Context, rates []models.ExchangeRate) error {
-	return nil
+func (m *mockFetcher) SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	var result models.SaveResult
+
+	for _, rate := range rates {
+		if slices.Contains(m.hold, rate.Currency) {
+			result.Held++
+			result.Withheld = append(result.Withheld, rate)
+
+			continue
+		}
+
+		m.saved = append(m.saved, rate)
+		result.Inserted++
+	}
+
+	return result, nil
 }
 
 func TestExecuteFetch(t *testing.T) {
@@ -47,10 +121,14 @@ func TestExecuteFetch(t *testing.T) {
 	now := time.Now().UTC().Truncate(time.Minute) // Truncate to avoid precision issues
 
 	tests := []struct {
-		name        string
-		currencies  []string
-		mockFetcher *mockFetcher
-		expected    []models.ExchangeRate
+		name            string
+		currencies      []string
+		failMode        string
+		mockFetcher     *mockFetcher
+		expected        []models.ExchangeRate
+		expectErr       string
+		expectedRun     models.FetchRun
+		expectedOutcome string
 	}{
 		{
 			name:       "successfully fetches rates",
@@ -111,6 +189,221 @@ func TestExecuteFetch(t *testing.T) {
 					Date: now,
 				},
 			},
+			expectedRun: models.FetchRun{
+				Status:     models.FetchRunSucceeded,
+				SourceURL:  "https://rates.example/none",
+				SaveResult: models.SaveResult{Inserted: 10},
+			},
+			expectedOutcome: outcomeSuccess,
+		},
+		{
+			name:       "missing currency is reported, others are kept",
+			currencies: []string{"USD", "XXX"},
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+					{Currency: "GBP", Rate: decimal.NewFromFloat(0.86), Date: now},
+				},
+			},
+			expected: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+			},
+			expectErr: "rate not found for currency 'XXX'",
+			expectedRun: models.FetchRun{
+				Status:         models.FetchRunPartial,
+				SourceURL:      "https://rates.example/none",
+				SaveResult:     models.SaveResult{Inserted: 1},
+				CurrencyErrors: map[string]string{"XXX": "rate not found for currency 'XXX'"},
+			},
+			expectedOutcome: outcomePartial,
+		},
+		{
+			name:       "run is failed when no currency is found",
+			currencies: []string{"XXX"},
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+				},
+			},
+			expectErr: "rate not found for currency 'XXX'",
+			expectedRun: models.FetchRun{
+				Status:         models.FetchRunFailed,
+				SourceURL:      "https://rates.example/none",
+				CurrencyErrors: map[string]string{"XXX": "rate not found for currency 'XXX'"},
+			},
+			expectedOutcome: outcomeFailure,
+		},
+		{
+			name:       "all-or-nothing stores nothing when a currency is missing",
+			currencies: []string{"USD", "XXX"},
+			failMode:   failModeAllOrNothing,
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+				},
+			},
+			expectErr: "1 of 2 currencies missing, nothing stored in all-or-nothing mode",
+			expectedRun: models.FetchRun{
+				Status:         models.FetchRunFailed,
+				SourceURL:      "https://rates.example/none",
+				CurrencyErrors: map[string]string{"XXX": "rate not found for currency 'XXX'"},
+			},
+			expectedOutcome: outcomeFailure,
+		},
+		{
+			name:       "all-or-nothing stores everything when every currency is found",
+			currencies: []string{"USD"},
+			failMode:   failModeAllOrNothing,
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+				},
+			},
+			expected: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+			},
+			expectedRun: models.FetchRun{
+				Status:     models.FetchRunSucceeded,
+				SourceURL:  "https://rates.example/none",
+				SaveResult: models.SaveResult{Inserted: 1},
+			},
+			expectedOutcome: outcomeSuccess,
+		},
+		{
+			name:       "strict keeps the rates found but fails the run",
+			currencies: []string{"USD", "XXX"},
+			failMode:   failModeStrict,
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+				},
+			},
+			expected: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+			},
+			expectErr: "rate not found for currency 'XXX'",
+			expectedRun: models.FetchRun{
+				Status:         models.FetchRunPartial,
+				SourceURL:      "https://rates.example/none",
+				SaveResult:     models.SaveResult{Inserted: 1},
+				CurrencyErrors: map[string]string{"XXX": "rate not found for currency 'XXX'"},
+			},
+			expectedOutcome: outcomeFailure,
+		},
+		{
+			name:       "rejected feed items are quarantined next to the valid rates",
+			currencies: []string{"USD"},
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+				},
+				rejected: []models.QuarantinedItem{
+					{Source: "banklv", Item: "<Item></Item>", Reason: "invalid feed item: malformed pubDate \"\""},
+				},
+			},
+			expected: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+			},
+			expectedRun: models.FetchRun{
+				Status:      models.FetchRunSucceeded,
+				SourceURL:   "https://rates.example/none",
+				SaveResult:  models.SaveResult{Inserted: 1},
+				Quarantined: 1,
+			},
+			expectedOutcome: outcomeSuccess,
+		},
+		{
+			name:       "rejected feed items are quarantined when no item is valid",
+			currencies: []string{"USD"},
+			mockFetcher: &mockFetcher{
+				rejected: []models.QuarantinedItem{
+					{Source: "banklv", Item: "<Item></Item>", Reason: "invalid feed item: odd number of fields (3), expected currency and rate pairs"},
+				},
+			},
+			expectErr: "fetch rates: no rates found",
+			expectedRun: models.FetchRun{
+				Status:      models.FetchRunFailed,
+				Quarantined: 1,
+			},
+			expectedOutcome: outcomeFailure,
+		},
+		{
+			name:       "currency with every rate held by the anomaly checks makes the run partial",
+			currencies: []string{"USD", "TRY"},
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+					{Currency: "TRY", Rate: decimal.NewFromFloat(4.97), Date: now},
+				},
+				hold: []string{"TRY"},
+			},
+			expected: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+			},
+			expectErr: "rates withheld by the anomaly checks for currency 'TRY'",
+			expectedRun: models.FetchRun{
+				Status:    models.FetchRunPartial,
+				SourceURL: "https://rates.example/none",
+				SaveResult: models.SaveResult{
+					Inserted: 1,
+					Held:     1,
+					Withheld: []models.ExchangeRate{{Currency: "TRY", Rate: decimal.NewFromFloat(4.97), Date: now}},
+				},
+				CurrencyErrors: map[string]string{"TRY": "rates withheld by the anomaly checks for currency 'TRY'"},
+			},
+			expectedOutcome: outcomePartial,
+		},
+		{
+			name:       "run with every rate held by the anomaly checks fails",
+			currencies: []string{"ISK"},
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "ISK", Rate: decimal.NewFromFloat(143.1), Date: now},
+				},
+				hold: []string{"ISK"},
+			},
+			expectErr: "rates withheld by the anomaly checks for currency 'ISK'",
+			expectedRun: models.FetchRun{
+				Status:    models.FetchRunFailed,
+				SourceURL: "https://rates.example/none",
+				SaveResult: models.SaveResult{
+					Held:     1,
+					Withheld: []models.ExchangeRate{{Currency: "ISK", Rate: decimal.NewFromFloat(143.1), Date: now}},
+				},
+				CurrencyErrors: map[string]string{"ISK": "rates withheld by the anomaly checks for currency 'ISK'"},
+			},
+			expectedOutcome: outcomeFailure,
+		},
+		{
+			name:       "discrepancies between reconciled sources are stored",
+			currencies: []string{"USD"},
+			mockFetcher: &mockFetcher{
+				latestRates: []models.ExchangeRate{
+					{Currency: "USD", Rate: decimal.NewFromFloat(1.1801), Date: now, Source: "ecb"},
+				},
+				diverging: []models.RateDiscrepancy{
+					{
+						Currency: "USD",
+						Date:     now,
+						Quotes: map[string]decimal.Decimal{
+							"banklv": decimal.NewFromFloat(1.184),
+							"ecb":    decimal.NewFromFloat(1.1801),
+						},
+						Chosen:       decimal.NewFromFloat(1.1801),
+						ChosenSource: "ecb",
+						Policy:       "median",
+					},
+				},
+			},
+			expected: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.NewFromFloat(1.1801), Date: now, Source: "ecb"},
+			},
+			expectedRun: models.FetchRun{
+				Status:     models.FetchRunSucceeded,
+				SourceURL:  "https://rates.example/ecb",
+				SaveResult: models.SaveResult{Inserted: 1},
+			},
+			expectedOutcome: outcomeSuccess,
 		},
 	}
 
@@ -120,10 +413,87 @@ func TestExecuteFetch(t *testing.T) {
 
 			ctx := t.Context()
 
-			rates, err := executeFetch(ctx, tt.mockFetcher, tt.mockFetcher, tt.currencies)
-			require.NoError(t, err)
+			failMode := tt.failMode
+			if failMode == "" {
+				failMode = failModeBestEffort
+			}
+
+			summary, err := executeFetch(ctx, tt.mockFetcher, tt.mockFetcher, tt.currencies, failMode)
+			if tt.expectErr != "" {
+				require.ErrorContains(t, err, tt.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.expectedOutcome, summary.Outcome)
+			require.Equal(t, failMode, summary.FailMode)
+			require.ElementsMatch(t, tt.expected, summary.Rates)
+			require.ElementsMatch(t, tt.expected, tt.mockFetcher.saved, "only the reported rates are stored")
+			require.Equal(t, int32(1), tt.mockFetcher.calls.Load(), "feed must be fetched once per run")
+
+			// The run is journaled before the fetch and completed after it
+			require.Len(t, tt.mockFetcher.started, 1)
+			require.Len(t, tt.mockFetcher.finished, 1)
 
-			require.ElementsMatch(t, tt.expected, rates)
+			started, run := tt.mockFetcher.started[0], tt.mockFetcher.finished[0]
+			require.Equal(t, models.FetchRunKindFetch, started.Kind)
+			require.Equal(t, tt.currencies, started.Currencies)
+			require.Equal(t, started.ID, run.ID)
+			require.NotNil(t, run.FinishedAt)
+
+			require.Equal(t, tt.expectedRun.Status, run.Status)
+			require.Equal(t, tt.expectedRun.SourceURL, run.SourceURL)
+			require.Equal(t, tt.expectedRun.SaveResult, run.SaveResult)
+			require.Equal(t, tt.expectedRun.CurrencyErrors, run.CurrencyErrors)
+			require.Equal(t, tt.expectedRun.Quarantined, run.Quarantined)
+			require.Equal(t, tt.mockFetcher.rejected, tt.mockFetcher.quarantined)
+			require.Equal(t, tt.mockFetcher.diverging, tt.mockFetcher.flagged)
+
+			// A currency with every rate held is not counted as fetched successfully
+			for _, currency := range tt.mockFetcher.hold {
+				require.Equal(t, float64(0), testutil.ToFloat64(
+					metrics.RateFetches.WithLabelValues("none", currency, metrics.ResultSuccess)))
+				require.Equal(t, float64(1), testutil.ToFloat64(
+					metrics.RateFetches.WithLabelValues("none", currency, metrics.ResultWithheld)))
+			}
 		})
 	}
 }
+
+// mockNotifier keeps the runs it is told about
+type mockNotifier struct {
+	runs []string
+	err  error
+}
+
+func (m *mockNotifier) Notify(ctx context.Context, runID string) error {
+	m.runs = append(m.runs, runID)
+
+	return m.err
+}
+
+func (m *mockNotifier) NotifyApproval(ctx context.Context, anomaly models.RateAnomaly) error {
+	m.runs = append(m.runs, anomaly.RunID)
+
+	return m.err
+}
+
+func TestNotifyingWriter(t *testing.T) {
+	t.Parallel()
+
+	notifier := &mockNotifier{err: errors.New("subscriptions unavailable")}
+	writer := notifyingWriter{ExchangeRateWriter: &mockFetcher{}, logger: slog.Default(), notifier: notifier}
+
+	rates := []models.ExchangeRate{{Currency: "USD", Rate: decimal.RequireFromString("1.18"), Date: time.Now()}}
+
+	// A failed notification does not fail the save
+	result, err := writer.SaveRates(t.Context(), "run-1", rates)
+	require.NoError(t, err)
+	require.Equal(t, models.SaveResult{Inserted: 1}, result)
+
+	// Saving nothing new notifies no one
+	_, err = writer.SaveRates(t.Context(), "run-2", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"run-1"}, notifier.runs)
+}
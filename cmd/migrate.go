@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/migrate"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/migrations"
+	"github.com/spf13/cobra"
+)
+
+type SchemaMigrator interface {
+	Up(ctx context.Context) ([]migrate.Migration, error)
+	Down(ctx context.Context, steps int) ([]migrate.Migration, error)
+	Status(ctx context.Context) ([]migrate.Status, error)
+	Version(ctx context.Context) (uint64, bool, error)
+}
+
+// migrateTimeout bounds a single migrate invocation, including waiting for the advisory lock
+const migrateTimeout = 10 * time.Minute
+
+func NewMigrateCmd(deps *Deps) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the database schema",
+	}
+
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), migrateTimeout)
+			defer cancel()
+
+			applied, err := deps.Migrator.Up(ctx)
+			for _, m := range applied {
+				fmt.Printf("Applied %d_%s\n", m.Version, m.Name)
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to migrate up: %w", err)
+			}
+
+			if len(applied) == 0 {
+				fmt.Println("No pending migrations")
+			}
+
+			return nil
+		},
+	}
+
+	var steps int
+
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Revert the most recently applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if steps < 1 {
+				return fmt.Errorf("steps must be at least 1, got %d", steps)
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), migrateTimeout)
+			defer cancel()
+
+			reverted, err := deps.Migrator.Down(ctx, steps)
+			for _, m := range reverted {
+				fmt.Printf("Reverted %d_%s\n", m.Version, m.Name)
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to migrate down: %w", err)
+			}
+
+			if len(reverted) == 0 {
+				fmt.Println("No applied migrations")
+			}
+
+			return nil
+		},
+	}
+
+	down.Flags().IntVarP(&steps, "steps", "n", 1, "Number of migrations to revert")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "List migrations and whether they are applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), migrateTimeout)
+			defer cancel()
+
+			statuses, err := deps.Migrator.Status(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to read migration status: %w", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT") // nolint:errcheck // Printing to stdout
+
+			for _, s := range statuses {
+				state, appliedAt := "pending", ""
+
+				if s.AppliedAt != nil {
+					state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+				}
+
+				if s.Dirty {
+					state = "dirty"
+				}
+
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt) // nolint:errcheck // Printing to stdout
+			}
+
+			return w.Flush()
+		},
+	}
+
+	version := &cobra.Command{
+		Use:   "version",
+		Short: "Print the current schema version",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), migrateTimeout)
+			defer cancel()
+
+			v, dirty, err := deps.Migrator.Version(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to read schema version: %w", err)
+			}
+
+			if dirty {
+				fmt.Printf("%d (dirty)\n", v)
+
+				return nil
+			}
+
+			fmt.Println(v)
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(up, down, status, version)
+
+	return cmd
+}
+
+// newMigrator builds a migrator running the driver's embedded migrations
+func newMigrator(logger *slog.Logger, driver string, db *sql.DB) (*migrate.Migrator, error) {
+	dialect, err := migrate.DialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys, err := migrations.ForDriver(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(logger, db, fsys, dialect)
+}
+
+// autoMigrate applies pending migrations before the server starts accepting requests
+func autoMigrate(ctx context.Context, logger *slog.Logger, migrator SchemaMigrator) error {
+	ctx, cancel := context.WithTimeout(ctx, migrateTimeout)
+	defer cancel()
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("schema up to date", slog.Int("applied", len(applied)))
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/scheduler"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+func NewScheduleCmd(logger *slog.Logger, cfg *config.Config, deps *Deps) *cobra.Command {
+	var runOnStart bool
+
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Periodically fetch currency rates on a cron schedule",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fetcherSvc, err := newRateFetcher(logger, cfg.Fetcher)
+			if err != nil {
+				return err
+			}
+
+			fetchScheduler, err := newFetchScheduler(logger, cfg.Scheduler, fetcherSvc, deps.RateWriter)
+			if err != nil {
+				return fmt.Errorf("failed to create scheduler: %w", err)
+			}
+
+			// The fetch counters are kept by the process fetching, serve has none of them to export
+			if cfg.Scheduler.MetricsPort > 0 {
+				metricsServer, err := serveMetrics(logger, cfg.Scheduler.MetricsPort)
+				if err != nil {
+					return err
+				}
+
+				defer func() {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+
+					if err := metricsServer.Shutdown(ctx); err != nil {
+						logger.Error("Metrics server shutdown failed", "error", err)
+					}
+				}()
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			webhooksDone := make(chan struct{})
+
+			go func() {
+				defer close(webhooksDone)
+
+				_ = deps.Webhooks.Run(ctx)
+			}()
+
+			// The last attempts are recorded before the process exits
+			defer func() {
+				stop()
+				<-webhooksDone
+			}()
+
+			if runOnStart {
+				fetchScheduler.Trigger(ctx)
+			}
+
+			if err := fetchScheduler.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				return fmt.Errorf("scheduler stopped: %w", err)
+			}
+
+			logger.Info("Scheduler stopped")
+
+			return nil
+		},
+	}
+
+	// ------------ Flags --------------------
+
+	addSchedulerFlags(cmd, &cfg.Scheduler)
+	addSourceFlag(cmd, &cfg.Fetcher)
+	cmd.Flags().StringSliceVarP(&cfg.Scheduler.Currencies, "currencies", "c", cfg.Scheduler.Currencies, "Comma-separated list of currency codes to fetch")
+	cmd.Flags().BoolVar(&runOnStart, "run-on-start", false, "Fetch once immediately before waiting for the first scheduled run")
+	cmd.Flags().IntVar(&cfg.Scheduler.MetricsPort, "metrics-port", cfg.Scheduler.MetricsPort, "Port /metrics is served on, 0 disables it")
+
+	return cmd
+}
+
+// serveMetrics serves /metrics on port until the returned server is shut down
+func serveMetrics(logger *slog.Logger, port int) (*http.Server, error) {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return nil, fmt.Errorf("listen for metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Handler:      mux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+
+	go func() {
+		logger.Info(fmt.Sprintf("Metrics served on :%d", port))
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", "error", err)
+		}
+	}()
+
+	return server, nil
+}
+
+func addSchedulerFlags(cmd *cobra.Command, cfg *config.SchedulerConfig) {
+	cmd.Flags().StringVar(&cfg.Cron, "cron", cfg.Cron, "Cron expression of the fetch schedule")
+	cmd.Flags().StringVar(&cfg.TimeZone, "timezone", cfg.TimeZone, "Time zone the cron expression is evaluated in")
+	cmd.Flags().DurationVar(&cfg.Jitter, "jitter", cfg.Jitter, "Maximum random delay added to every scheduled run")
+	cmd.Flags().IntVar(&cfg.MaxAttempts, "max-attempts", cfg.MaxAttempts, "Attempts per scheduled run before giving up")
+	cmd.Flags().DurationVar(&cfg.RetryDelay, "retry-delay", cfg.RetryDelay, "Delay before the first retry, doubled after every failure")
+}
+
+// newFetchScheduler builds a scheduler that fetches cfg.Currencies from fetcherSvc into writerSvc on every tick
+func newFetchScheduler(
+	logger *slog.Logger,
+	cfg config.SchedulerConfig,
+	fetcherSvc ExchangeRateFetcher,
+	writerSvc ExchangeRateWriter,
+) (*scheduler.Scheduler, error) {
+	return scheduler.New(logger, cfg, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		defer cancel()
+
+		// Only a failed run is retried, a partial one has stored the other currencies and would fetch them again
+		summary, err := executeFetch(ctx, fetcherSvc, writerSvc, cfg.Currencies, failModeBestEffort)
+		if summary.Outcome == outcomeFailure {
+			return err
+		}
+
+		if err != nil {
+			logger.Warn("Scheduled fetch completed with errors",
+				slog.String("run_id", summary.ID),
+				slog.String("outcome", summary.Outcome),
+				slog.Int("rates", len(summary.Rates)),
+				slog.Any("error", err),
+			)
+
+			return nil
+		}
+
+		logger.Info("Scheduled fetch completed", slog.String("run_id", summary.ID), slog.Int("rates", len(summary.Rates)))
+
+		return nil
+	})
+}
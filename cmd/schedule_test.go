@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchSchedulerRetries(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	tests := []struct {
+		name          string
+		latestRates   []models.ExchangeRate
+		expectedCalls int32
+		expectedRuns  []string
+	}{
+		{
+			name: "partial run is not retried",
+			latestRates: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.NewFromFloat(1.15), Date: now},
+			},
+			expectedCalls: 1,
+			expectedRuns:  []string{models.FetchRunPartial},
+		},
+		{
+			name:          "failed run is retried",
+			expectedCalls: 3,
+			expectedRuns:  []string{models.FetchRunFailed, models.FetchRunFailed, models.FetchRunFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockFetcher{latestRates: tt.latestRates}
+
+			fetchScheduler, err := newFetchScheduler(slog.Default(), config.SchedulerConfig{
+				Cron:        "0 16 * * *",
+				TimeZone:    "UTC",
+				MaxAttempts: 3,
+				RetryDelay:  time.Millisecond,
+				Currencies:  []string{"USD", "GBP"},
+			}, mock, mock)
+			require.NoError(t, err)
+
+			require.True(t, fetchScheduler.Trigger(t.Context()))
+			require.Equal(t, tt.expectedCalls, mock.calls.Load())
+
+			statuses := make([]string, 0, len(mock.finished))
+			for _, run := range mock.finished {
+				statuses = append(statuses, run.Status)
+			}
+
+			require.Equal(t, tt.expectedRuns, statuses)
+		})
+	}
+}
@@ -13,27 +13,175 @@ import (
 
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/api"
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/health"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/middleware"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/scheduler"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/stream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-func NewServeCmd(logger *slog.Logger, cfg *config.ServerConfig, rateReader api.RateReader) *cobra.Command {
+// HealthReader is what the readiness checks and the rate freshness metrics need from the database
+type HealthReader interface {
+	health.Pinger
+	health.NewestRateReader
+	metrics.LatestRatesReader
+}
+
+// AdminReader is what the admin endpoints need from the database
+type AdminReader interface {
+	api.FetchRunReader
+	api.QuarantineReader
+	api.DiscrepancyReader
+	api.AnomalyStore
+	api.SubscriptionStore
+}
+
+// ApprovalNotifier tells webhook subscribers about a held rate stored once its anomaly was approved
+type ApprovalNotifier interface {
+	NotifyApproval(ctx context.Context, anomaly models.RateAnomaly) error
+}
+
+// notifyingAdmin notifies webhook subscribers once a held rate is approved
+type notifyingAdmin struct {
+	AdminReader
+	logger   *slog.Logger
+	notifier ApprovalNotifier
+}
+
+func (a notifyingAdmin) ApproveAnomaly(ctx context.Context, id int64) (models.RateAnomaly, error) {
+	anomaly, err := a.AdminReader.ApproveAnomaly(ctx, id)
+	if err != nil {
+		return anomaly, err
+	}
+
+	// The rate is stored by now, failing to notify about it does not fail the approval
+	if err := a.notifier.NotifyApproval(ctx, anomaly); err != nil {
+		a.logger.Error("failed to notify webhook subscribers",
+			slog.Int64("anomaly_id", anomaly.ID), slog.String("run_id", anomaly.RunID), slog.Any("error", err))
+	}
+
+	return anomaly, nil
+}
+
+// WebhookSender keeps sending the webhook deliveries that are due until ctx is done
+type WebhookSender interface {
+	Run(ctx context.Context) error
+}
+
+// RateStream publishes the rates stored by any process to the event stream's clients until ctx is done
+type RateStream interface {
+	api.RateStreamer
+	Run(ctx context.Context) error
+}
+
+func newRateStream(logger *slog.Logger, cfg config.StreamConfig, store stream.Store) (*stream.Broker, error) {
+	if cfg.PollInterval <= 0 {
+		return nil, fmt.Errorf("stream poll interval must be positive, got %s", cfg.PollInterval)
+	}
+
+	if cfg.Heartbeat <= 0 {
+		return nil, fmt.Errorf("stream heartbeat must be positive, got %s", cfg.Heartbeat)
+	}
+
+	return stream.NewBroker(logger, store, cfg.PollInterval), nil
+}
+
+func NewServeCmd(logger *slog.Logger, cfg *config.Config, deps *Deps) *cobra.Command {
+	var schedule bool
+
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start the currency service HTTP server",
 		Run: func(cmd *cobra.Command, args []string) {
-			apiController := api.NewAPI(logger, rateReader)
+			roundingMode, err := api.ParseRoundingMode(cfg.Conversion.RoundingMode)
+			if err != nil {
+				logger.Error("Invalid conversion rounding mode", "error", err)
+
+				os.Exit(1)
+			}
+
+			if cfg.Database.AutoMigrate {
+				if err := autoMigrate(cmd.Context(), logger, deps.Migrator); err != nil {
+					logger.Error("Failed to migrate database schema", "error", err)
+
+					os.Exit(1)
+				}
+			}
+
+			apiController := api.NewAPI(logger, deps.RateReader,
+				api.WithRounding(roundingMode, cfg.Conversion.Precision),
+				api.WithFetchRunReader(deps.Admin),
+				api.WithQuarantineReader(deps.Admin),
+				api.WithDiscrepancyReader(deps.Admin),
+				api.WithAnomalyStore(deps.Admin),
+				api.WithSubscriptionStore(deps.Admin, deps.Targets),
+				api.WithRateStream(deps.Rates, cfg.Stream.Heartbeat),
+			)
 
 			mux := http.NewServeMux()
 
 			mux.HandleFunc("GET /api/v1/rates/latest", apiController.LatestRateHandler)
+			mux.HandleFunc("GET /api/v1/rates/stream", apiController.RateStreamHandler)
 			mux.HandleFunc("GET /api/v1/rates/history/{currency}", apiController.HistoryRateHandler)
+			mux.HandleFunc("GET /api/v1/rates/history/{currency}/{date}/revisions", apiController.RateRevisionsHandler)
+			mux.HandleFunc("GET /api/v1/rates/{date}", apiController.AsOfRateHandler)
+			mux.HandleFunc("GET /api/v1/convert", apiController.ConvertHandler)
+			mux.HandleFunc("GET /api/v1/admin/fetch-runs", apiController.FetchRunsHandler)
+			mux.HandleFunc("GET /api/v1/admin/quarantine", apiController.QuarantineHandler)
+			mux.HandleFunc("GET /api/v1/admin/discrepancies", apiController.DiscrepanciesHandler)
+			mux.HandleFunc("GET /api/v1/admin/anomalies", apiController.AnomaliesHandler)
+			mux.HandleFunc("POST /api/v1/admin/anomalies/{id}/approve", apiController.ApproveAnomalyHandler)
+			mux.HandleFunc("POST /api/v1/subscriptions", apiController.CreateSubscriptionHandler)
+			mux.HandleFunc("GET /api/v1/subscriptions", apiController.SubscriptionsHandler)
+			mux.HandleFunc("DELETE /api/v1/subscriptions/{id}", apiController.DeleteSubscriptionHandler)
+			mux.HandleFunc("GET /api/v1/subscriptions/{id}/deliveries", apiController.DeliveriesHandler)
+
+			prometheus.MustRegister(
+				metrics.NewFreshnessCollector(logger, deps.Health),
+				metrics.NewDBStatsCollector(deps.DBStats),
+			)
+			mux.Handle("GET /metrics", promhttp.Handler())
+
+			checks := []health.Check{
+				health.DatabaseCheck(deps.Health),
+				health.FreshnessCheck(deps.Health, cfg.Health.Staleness, time.Now),
+			}
+
+			var fetchScheduler *scheduler.Scheduler
+
+			if schedule {
+				fetcherSvc, err := newRateFetcher(logger, cfg.Fetcher)
+				if err != nil {
+					logger.Error("Failed to create rate fetcher", "error", err)
+
+					os.Exit(1)
+				}
+
+				fetchScheduler, err = newFetchScheduler(logger, cfg.Scheduler, fetcherSvc, deps.RateWriter)
+				if err != nil {
+					logger.Error("Failed to create scheduler", "error", err)
+
+					os.Exit(1)
+				}
+
+				// The sources are only fetched from, and their circuits only matter, when this process schedules
+				checks = append(checks, health.SourcesCheck(fetcherSvc))
+			}
+
+			healthController := health.NewHandler(logger, cfg.Health.CheckTimeout, checks...)
 
-			handler := middleware.LoggingMiddleware(logger, mux)
+			mux.HandleFunc("GET /healthz", healthController.LivenessHandler)
+			mux.HandleFunc("GET /readyz", healthController.ReadinessHandler)
+
+			handler := middleware.LoggingMiddleware(logger, middleware.MetricsMiddleware(mux))
 
 			server := &http.Server{
-				Addr:         ":" + strconv.Itoa(cfg.Port),
+				Addr:         ":" + strconv.Itoa(cfg.Server.Port),
 				Handler:      handler,
 				ReadTimeout:  15 * time.Second,
 				WriteTimeout: 15 * time.Second,
@@ -45,7 +193,7 @@ func NewServeCmd(logger *slog.Logger, cfg *config.ServerConfig, rateReader api.R
 			signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
 			go func() {
-				logger.Info(fmt.Sprintf("Server starting on :%d", cfg.Port))
+				logger.Info(fmt.Sprintf("Server starting on :%d", cfg.Server.Port))
 				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 
 					logger.Error("Server failed to start", "error", err)
@@ -54,10 +202,44 @@ func NewServeCmd(logger *slog.Logger, cfg *config.ServerConfig, rateReader api.R
 				}
 			}()
 
+			schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+			schedulerDone := make(chan struct{})
+			webhooksDone := make(chan struct{})
+			ratesDone := make(chan struct{})
+
+			// Stopping the broker closes the open streams, so the server can shut down
+			go func() {
+				defer close(ratesDone)
+
+				_ = deps.Rates.Run(schedulerCtx)
+			}()
+
+			// Deliveries of fetches made elsewhere are retried here too
+			go func() {
+				defer close(webhooksDone)
+
+				_ = deps.Webhooks.Run(schedulerCtx)
+			}()
+
+			if fetchScheduler != nil {
+				go func() {
+					defer close(schedulerDone)
+
+					_ = fetchScheduler.Run(schedulerCtx)
+				}()
+			} else {
+				close(schedulerDone)
+			}
+
 			<-stop
 
 			logger.Info("Server shutting down...")
 
+			stopScheduler()
+			<-schedulerDone
+			<-webhooksDone
+			<-ratesDone
+
 			// Create a context with a timeout for the shutdown process
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
@@ -72,11 +254,17 @@ func NewServeCmd(logger *slog.Logger, cfg *config.ServerConfig, rateReader api.R
 
 	// ----------------------- Flags -----------------------
 
-	cmd.Flags().IntVarP(&cfg.Port, "port", "p", cfg.Port, "port to listen on")
+	cmd.Flags().IntVarP(&cfg.Server.Port, "port", "p", cfg.Server.Port, "port to listen on")
 
 	if err := viper.BindPFlag("server_port", cmd.Flags().Lookup("port")); err != nil {
 		logger.Error("Failed to bind server port flag", "error", err)
 	}
 
+	cmd.Flags().BoolVar(&cfg.Database.AutoMigrate, "auto-migrate", cfg.Database.AutoMigrate, "Apply pending schema migrations before starting")
+	cmd.Flags().DurationVar(&cfg.Health.Staleness, "staleness", cfg.Health.Staleness, "Maximum age of the newest stored rate before /readyz fails")
+	cmd.Flags().BoolVar(&schedule, "schedule", false, "Also run the fetch scheduler in this process")
+	addSchedulerFlags(cmd, &cfg.Scheduler)
+	addSourceFlag(cmd, &cfg.Fetcher)
+
 	return cmd
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// mockApprovals approves the anomalies it holds
+type mockApprovals struct {
+	AdminReader
+
+	held map[int64]models.RateAnomaly
+}
+
+func (m *mockApprovals) ApproveAnomaly(ctx context.Context, id int64) (models.RateAnomaly, error) {
+	anomaly, ok := m.held[id]
+	if !ok {
+		return models.RateAnomaly{}, models.ErrAnomalyNotFound
+	}
+
+	anomaly.Status = models.AnomalyApproved
+
+	return anomaly, nil
+}
+
+func TestNotifyingAdmin(t *testing.T) {
+	t.Parallel()
+
+	notifier := &mockNotifier{err: errors.New("subscriptions unavailable")}
+	admin := notifyingAdmin{
+		AdminReader: &mockApprovals{held: map[int64]models.RateAnomaly{7: {ID: 7, RunID: "run-1", Status: models.AnomalyHeld}}},
+		logger:      slog.Default(),
+		notifier:    notifier,
+	}
+
+	// A failed notification does not fail the approval
+	anomaly, err := admin.ApproveAnomaly(t.Context(), 7)
+	require.NoError(t, err)
+	require.Equal(t, models.AnomalyApproved, anomaly.Status)
+
+	// A failed approval stored nothing to notify about
+	_, err = admin.ApproveAnomaly(t.Context(), 8)
+	require.ErrorIs(t, err, models.ErrAnomalyNotFound)
+
+	require.Equal(t, []string{"run-1"}, notifier.runs)
+}
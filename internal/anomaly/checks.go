@@ -0,0 +1,260 @@
+// Package anomaly checks fetched rates against their currency's recent history before they are stored, so a
+// parsing glitch or a stale feed does not reach the API unnoticed.
+package anomaly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Action is what happens to a rate exceeding a threshold, in increasing order of severity
+type Action int
+
+const (
+	actionNone   Action = iota
+	ActionFlag          // stored and recorded for review
+	ActionHold          // recorded, stored once approved
+	ActionReject        // recorded, never stored
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionFlag:
+		return "flag"
+	case ActionHold:
+		return "hold"
+	case ActionReject:
+		return "reject"
+	default:
+		return "none"
+	}
+}
+
+// status is the anomaly status a rate gets for the action
+func (a Action) status() string {
+	switch a {
+	case ActionHold:
+		return models.AnomalyHeld
+	case ActionReject:
+		return models.AnomalyRejected
+	default:
+		return models.AnomalyFlagged
+	}
+}
+
+// Limit holds the thresholds of one check. A value above a threshold gets its action, the most severe one wins.
+// A zero threshold is disabled.
+type Limit struct {
+	Flag   float64 `json:"flag"`
+	Hold   float64 `json:"hold"`
+	Reject float64 `json:"reject"`
+}
+
+// action returns the action for value and the threshold it exceeded
+func (l Limit) action(value float64) (Action, float64) {
+	switch {
+	case l.Reject > 0 && value > l.Reject:
+		return ActionReject, l.Reject
+	case l.Hold > 0 && value > l.Hold:
+		return ActionHold, l.Hold
+	case l.Flag > 0 && value > l.Flag:
+		return ActionFlag, l.Flag
+	default:
+		return actionNone, 0
+	}
+}
+
+func (l Limit) validate() error {
+	if l.Flag < 0 || l.Hold < 0 || l.Reject < 0 {
+		return errors.New("thresholds must not be negative")
+	}
+
+	return nil
+}
+
+// Thresholds configure the checks of one currency
+type Thresholds struct {
+	Change    Limit `json:"change"`    // day-over-day change as a fraction of the previous rate, 0.05 is 5 %
+	ZScore    Limit `json:"zscore"`    // distance from the mean of the window in standard deviations
+	Unchanged Limit `json:"unchanged"` // consecutive rates with the same value, the new one included
+}
+
+// DefaultThresholds suit the ECB reference rates, which rarely move more than 2 % a day. A shifted decimal
+// point changes a rate by 90 % or 900 % and is rejected outright.
+var DefaultThresholds = Thresholds{
+	Change:    Limit{Flag: 0.03, Hold: 0.1, Reject: 0.5},
+	ZScore:    Limit{Flag: 4, Hold: 8},
+	Unchanged: Limit{Flag: 5},
+}
+
+// minZScoreSamples is how many previous rates the z-score needs to mean anything
+const minZScoreSamples = 10
+
+// Config configures the anomaly checks
+type Config struct {
+	Window     int                   // previous rates the z-score is computed over
+	Default    Thresholds            // thresholds of the currencies without their own
+	Currencies map[string]Thresholds // thresholds by currency
+}
+
+func (c Config) thresholds(currency string) Thresholds {
+	if t, ok := c.Currencies[currency]; ok {
+		return t
+	}
+
+	return c.Default
+}
+
+// thresholdOverride replaces the limits of the checks it names
+type thresholdOverride struct {
+	Change    *Limit `json:"change"`
+	ZScore    *Limit `json:"zscore"`
+	Unchanged *Limit `json:"unchanged"`
+}
+
+func (o thresholdOverride) apply(t Thresholds) (Thresholds, error) {
+	for _, override := range []struct {
+		limit  *Limit
+		target *Limit
+	}{{o.Change, &t.Change}, {o.ZScore, &t.ZScore}, {o.Unchanged, &t.Unchanged}} {
+		if override.limit == nil {
+			continue
+		}
+
+		if err := override.limit.validate(); err != nil {
+			return t, err
+		}
+
+		*override.target = *override.limit
+	}
+
+	return t, nil
+}
+
+// ParseConfig builds the configuration from DefaultThresholds and overrides given as JSON by currency, e.g.
+// {"TRY": {"change": {"flag": 0.05, "hold": 0.2, "reject": 0.5}}}. Checks an override leaves out keep their
+// limits, the "*" entry overrides the defaults of every currency.
+func ParseConfig(window int, overrides string) (Config, error) {
+	cfg := Config{Window: window, Default: DefaultThresholds, Currencies: map[string]Thresholds{}}
+
+	if window < minZScoreSamples {
+		return cfg, fmt.Errorf("anomaly window %d is too short, at least %d rates are needed", window, minZScoreSamples)
+	}
+
+	if strings.TrimSpace(overrides) == "" {
+		return cfg, nil
+	}
+
+	var parsed map[string]thresholdOverride
+	if err := json.Unmarshal([]byte(overrides), &parsed); err != nil {
+		return cfg, fmt.Errorf("parse anomaly thresholds: %w", err)
+	}
+
+	if all, ok := parsed["*"]; ok {
+		var err error
+		if cfg.Default, err = all.apply(cfg.Default); err != nil {
+			return cfg, fmt.Errorf("anomaly thresholds for *: %w", err)
+		}
+	}
+
+	for currency, override := range parsed {
+		if currency == "*" {
+			continue
+		}
+
+		if len(currency) != 3 {
+			return cfg, fmt.Errorf("anomaly thresholds: invalid currency %q", currency)
+		}
+
+		t, err := override.apply(cfg.Default)
+		if err != nil {
+			return cfg, fmt.Errorf("anomaly thresholds for %s: %w", currency, err)
+		}
+
+		cfg.Currencies[strings.ToUpper(currency)] = t
+	}
+
+	return cfg, nil
+}
+
+// evaluate checks a new rate against the previous ones, oldest first. It returns the most severe action any
+// check calls for and why, or an error when the previous rate cannot be compared with.
+func (t Thresholds) evaluate(rate decimal.Decimal, prior []decimal.Decimal) (Action, []string, error) {
+	if len(prior) == 0 {
+		return actionNone, nil, nil
+	}
+
+	worst := actionNone
+
+	var reasons []string
+
+	exceeds := func(limit Limit, value float64, describe func(threshold float64) string) {
+		action, threshold := limit.action(value)
+		if action == actionNone {
+			return
+		}
+
+		worst = max(worst, action)
+		reasons = append(reasons, describe(threshold))
+	}
+
+	previous := prior[len(prior)-1]
+	if !previous.IsPositive() {
+		return actionNone, nil, fmt.Errorf("previous rate %s is not positive", previous)
+	}
+
+	change := rate.Sub(previous).Abs().Div(previous).InexactFloat64()
+
+	exceeds(t.Change, change, func(threshold float64) string {
+		return fmt.Sprintf("changed %.2f %% from %s, above %.2f %%", change*100, previous, threshold*100)
+	})
+
+	if len(prior) >= minZScoreSamples {
+		if z, ok := zScore(rate, prior); ok {
+			exceeds(t.ZScore, z, func(threshold float64) string {
+				return fmt.Sprintf("z-score %.1f over the last %d rates, above %g", z, len(prior), threshold)
+			})
+		}
+	}
+
+	unchanged := 1
+	for i := len(prior) - 1; i >= 0 && prior[i].Equal(rate); i-- {
+		unchanged++
+	}
+
+	exceeds(t.Unchanged, float64(unchanged), func(threshold float64) string {
+		return fmt.Sprintf("unchanged for %d consecutive rates, above %g", unchanged, threshold)
+	})
+
+	return worst, reasons, nil
+}
+
+// zScore returns how many standard deviations rate is away from the mean of prior. It is undefined when every
+// previous rate is the same.
+func zScore(rate decimal.Decimal, prior []decimal.Decimal) (float64, bool) {
+	var sum float64
+	for _, p := range prior {
+		sum += p.InexactFloat64()
+	}
+
+	mean := sum / float64(len(prior))
+
+	var squares float64
+	for _, p := range prior {
+		d := p.InexactFloat64() - mean
+		squares += d * d
+	}
+
+	sd := math.Sqrt(squares / float64(len(prior)))
+	if sd == 0 {
+		return 0, false
+	}
+
+	return math.Abs(rate.InexactFloat64()-mean) / sd, true
+}
@@ -0,0 +1,222 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Store is what the guard needs from the database
+type Store interface {
+	SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error)
+	// RecentRates returns up to limit stored rates of a currency dated on or before through, newest first
+	RecentRates(ctx context.Context, currency string, through time.Time, limit int) ([]models.ExchangeRate, error)
+	RecordAnomalies(ctx context.Context, runID string, anomalies []models.RateAnomaly) error
+}
+
+// Guard checks rates against their currency's recent history before saving them. Rates that stand out are
+// recorded as anomalies and, depending on the thresholds they exceed, stored anyway, held for approval or rejected.
+type Guard struct {
+	logger *slog.Logger
+	store  Store
+	cfg    Config
+}
+
+func NewGuard(logger *slog.Logger, store Store, cfg Config) *Guard {
+	return &Guard{
+		logger: logger.With(slog.String("component", "anomaly-guard")),
+		store:  store,
+		cfg:    cfg,
+	}
+}
+
+// SaveRates checks rates and saves the ones not held or rejected. The anomalies are recorded first, a rate is
+// never held back without a trace. The result counts the flagged, held and rejected rates too and lists the
+// rates withheld.
+func (g *Guard) SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	var (
+		accepted  []models.ExchangeRate
+		anomalies []models.RateAnomaly
+	)
+
+	for _, currency := range currencies(rates) {
+		ok, found, err := g.check(ctx, currency, rates)
+		if err != nil {
+			return models.SaveResult{}, err
+		}
+
+		accepted = append(accepted, ok...)
+		anomalies = append(anomalies, found...)
+	}
+
+	if err := g.store.RecordAnomalies(ctx, runID, anomalies); err != nil {
+		return models.SaveResult{}, fmt.Errorf("record rate anomalies: %w", err)
+	}
+
+	result, err := g.store.SaveRates(ctx, runID, accepted)
+	if err != nil {
+		return models.SaveResult{}, err
+	}
+
+	for _, anomaly := range anomalies {
+		switch anomaly.Status {
+		case models.AnomalyFlagged:
+			result.Flagged++
+
+			continue
+		case models.AnomalyHeld:
+			result.Held++
+		case models.AnomalyRejected:
+			result.Rejected++
+		}
+
+		result.Withheld = append(result.Withheld, models.ExchangeRate{
+			Currency: anomaly.Currency,
+			Date:     anomaly.Date,
+			Rate:     anomaly.Rate,
+			Source:   anomaly.Source,
+		})
+	}
+
+	return result, nil
+}
+
+// check evaluates the rates of one currency in date order, each against the stored history and the rates of the
+// batch accepted before it. It returns the rates to store and the anomalies found.
+func (g *Guard) check(
+	ctx context.Context,
+	currency string,
+	rates []models.ExchangeRate,
+) (accepted []models.ExchangeRate, anomalies []models.RateAnomaly, err error) {
+	batch := slices.DeleteFunc(slices.Clone(rates), func(r models.ExchangeRate) bool { return r.Currency != currency })
+	slices.SortStableFunc(batch, func(a, b models.ExchangeRate) int { return a.Date.Compare(b.Date) })
+
+	stored, err := g.store.RecentRates(ctx, currency, batch[len(batch)-1].Date, g.cfg.Window+len(batch))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read recent %s rates: %w", currency, err)
+	}
+
+	history := newSeries(stored)
+	thresholds := g.cfg.thresholds(currency)
+
+	for _, rate := range batch {
+		// Rates stored before passed the checks or were approved
+		if current, ok := history.at(rate.Date); ok && current.Equal(rate.Rate) {
+			accepted = append(accepted, rate)
+
+			continue
+		}
+
+		action, reasons, err := thresholds.evaluate(rate.Rate, history.before(rate.Date, g.cfg.Window))
+		if err != nil {
+			return nil, nil, fmt.Errorf("check %s rate on %s: %w", currency, rate.Date.Format(time.DateOnly), err)
+		}
+		if action == actionNone {
+			accepted = append(accepted, rate)
+			history.set(rate)
+
+			continue
+		}
+
+		reason := strings.Join(reasons, "; ")
+
+		g.logger.Warn("rate anomaly",
+			slog.String("currency", currency),
+			slog.Time("date", rate.Date),
+			slog.String("rate", rate.Rate.String()),
+			slog.String("action", action.String()),
+			slog.String("reason", reason))
+		metrics.RateAnomalies.WithLabelValues(currency, action.String()).Inc()
+
+		anomalies = append(anomalies, models.RateAnomaly{
+			Currency: currency,
+			Date:     rate.Date,
+			Rate:     rate.Rate,
+			Source:   rate.Source,
+			Status:   action.status(),
+			Reason:   reason,
+		})
+
+		// Held and rejected rates do not become the history later rates are compared with
+		if action == ActionFlag {
+			accepted = append(accepted, rate)
+			history.set(rate)
+		}
+	}
+
+	return accepted, anomalies, nil
+}
+
+// currencies returns the currencies of rates in the order they first appear
+func currencies(rates []models.ExchangeRate) []string {
+	var seen []string
+	for _, rate := range rates {
+		if !slices.Contains(seen, rate.Currency) {
+			seen = append(seen, rate.Currency)
+		}
+	}
+
+	return seen
+}
+
+// series is the history of one currency, oldest first with one rate per day
+type series struct {
+	rates []models.ExchangeRate
+}
+
+// newSeries builds a series out of rates given newest first
+func newSeries(newestFirst []models.ExchangeRate) *series {
+	rates := slices.Clone(newestFirst)
+	slices.Reverse(rates)
+
+	return &series{rates: rates}
+}
+
+func (s *series) find(date time.Time) (int, bool) {
+	day := date.Format(time.DateOnly)
+
+	return slices.BinarySearchFunc(s.rates, day, func(r models.ExchangeRate, day string) int {
+		return strings.Compare(r.Date.Format(time.DateOnly), day)
+	})
+}
+
+// at returns the rate of the day
+func (s *series) at(date time.Time) (decimal.Decimal, bool) {
+	i, ok := s.find(date)
+	if !ok {
+		return decimal.Decimal{}, false
+	}
+
+	return s.rates[i].Rate, true
+}
+
+// before returns up to n rates dated before the day, oldest first
+func (s *series) before(date time.Time, n int) []decimal.Decimal {
+	end, _ := s.find(date)
+
+	values := make([]decimal.Decimal, 0, n)
+	for _, rate := range s.rates[max(0, end-n):end] {
+		values = append(values, rate.Rate)
+	}
+
+	return values
+}
+
+// set adds the rate of a day or replaces it
+func (s *series) set(rate models.ExchangeRate) {
+	i, ok := s.find(rate.Date)
+	if ok {
+		s.rates[i] = rate
+
+		return
+	}
+
+	s.rates = slices.Insert(s.rates, i, rate)
+}
@@ -0,0 +1,221 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore keeps rates in memory
+type mockStore struct {
+	rates     []models.ExchangeRate
+	anomalies []models.RateAnomaly
+}
+
+func (m *mockStore) SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	m.rates = append(m.rates, rates...)
+
+	return models.SaveResult{Inserted: len(rates)}, nil
+}
+
+func (m *mockStore) RecentRates(ctx context.Context, currency string, through time.Time, limit int) ([]models.ExchangeRate, error) {
+	var recent []models.ExchangeRate
+	for _, rate := range slices.Backward(m.rates) {
+		if rate.Currency == currency && !rate.Date.After(through) && len(recent) < limit {
+			recent = append(recent, rate)
+		}
+	}
+
+	return recent, nil
+}
+
+func (m *mockStore) RecordAnomalies(ctx context.Context, runID string, anomalies []models.RateAnomaly) error {
+	m.anomalies = append(m.anomalies, anomalies...)
+
+	return nil
+}
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// history returns one USD rate a day, wobbling between 1.16 and 1.208
+func history(days int) []models.ExchangeRate {
+	rates := make([]models.ExchangeRate, 0, days)
+	for i := range days {
+		rates = append(rates, models.ExchangeRate{
+			Currency: "USD",
+			Rate:     decimal.RequireFromString(fmt.Sprintf("1.%03d", 160+(i*7)%13*4)),
+			Date:     start.AddDate(0, 0, i),
+		})
+	}
+
+	return rates
+}
+
+func usd(day int, rate string) models.ExchangeRate {
+	return models.ExchangeRate{Currency: "USD", Rate: decimal.RequireFromString(rate), Date: start.AddDate(0, 0, day), Source: "banklv"}
+}
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		stored         []models.ExchangeRate
+		rates          []models.ExchangeRate
+		expectedStored []models.ExchangeRate
+		expectedStatus []string
+		expectedReason string
+		expectedResult models.SaveResult
+	}{
+		{
+			name:           "Ordinary move",
+			stored:         history(30),
+			rates:          []models.ExchangeRate{usd(30, "1.19")},
+			expectedStored: []models.ExchangeRate{usd(30, "1.19")},
+			expectedResult: models.SaveResult{Inserted: 1},
+		},
+		{
+			name:           "Shifted decimal point is rejected",
+			stored:         history(30),
+			rates:          []models.ExchangeRate{usd(30, "11.9")},
+			expectedStatus: []string{models.AnomalyRejected},
+			expectedReason: "changed 898.32 % from 1.192, above 50.00 %; z-score 709.5 over the last 30 rates",
+			expectedResult: models.SaveResult{Rejected: 1, Withheld: []models.ExchangeRate{usd(30, "11.9")}},
+		},
+		{
+			name:           "Large move is held",
+			stored:         history(30),
+			rates:          []models.ExchangeRate{usd(30, "1.34")},
+			expectedStatus: []string{models.AnomalyHeld},
+			expectedReason: "changed 12.42 % from 1.192, above 10.00 %; z-score 10.4 over the last 30 rates, above 8",
+			expectedResult: models.SaveResult{Held: 1, Withheld: []models.ExchangeRate{usd(30, "1.34")}},
+		},
+		{
+			name:           "Moderate move is flagged and stored",
+			stored:         history(30),
+			rates:          []models.ExchangeRate{usd(30, "1.23")},
+			expectedStored: []models.ExchangeRate{usd(30, "1.23")},
+			expectedStatus: []string{models.AnomalyFlagged},
+			expectedReason: "changed 3.19 % from 1.192, above 3.00 %",
+			expectedResult: models.SaveResult{Inserted: 1, Flagged: 1},
+		},
+		{
+			name:           "Stale value is flagged",
+			stored:         []models.ExchangeRate{usd(0, "1.18"), usd(1, "1.18"), usd(2, "1.18"), usd(3, "1.18"), usd(4, "1.18")},
+			rates:          []models.ExchangeRate{usd(5, "1.18")},
+			expectedStored: []models.ExchangeRate{usd(5, "1.18")},
+			expectedStatus: []string{models.AnomalyFlagged},
+			expectedReason: "unchanged for 6 consecutive rates, above 5",
+			expectedResult: models.SaveResult{Inserted: 1, Flagged: 1},
+		},
+		{
+			name:           "Stored rates are not checked again",
+			stored:         []models.ExchangeRate{usd(0, "1.18"), usd(1, "11.8")},
+			rates:          []models.ExchangeRate{usd(1, "11.8")},
+			expectedStored: []models.ExchangeRate{usd(1, "11.8")},
+			expectedResult: models.SaveResult{Inserted: 1},
+		},
+		{
+			name:           "First rate of a currency",
+			rates:          []models.ExchangeRate{usd(0, "1.18")},
+			expectedStored: []models.ExchangeRate{usd(0, "1.18")},
+			expectedResult: models.SaveResult{Inserted: 1},
+		},
+		{
+			name:   "Rates of a batch are checked against each other",
+			stored: []models.ExchangeRate{usd(0, "1.18")},
+			rates:  []models.ExchangeRate{usd(3, "1.19"), usd(2, "0.118"), usd(1, "1.18")},
+			// The rejected rate is not what the next day is compared with
+			expectedStored: []models.ExchangeRate{usd(1, "1.18"), usd(3, "1.19")},
+			expectedStatus: []string{models.AnomalyRejected},
+			expectedReason: "changed 90.00 % from 1.18, above 50.00 %",
+			expectedResult: models.SaveResult{Inserted: 2, Rejected: 1, Withheld: []models.ExchangeRate{usd(2, "0.118")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &mockStore{rates: slices.Clone(tt.stored)}
+			guard := NewGuard(slog.Default(), store, Config{Window: 30, Default: DefaultThresholds})
+
+			result, err := guard.SaveRates(t.Context(), "run-1", tt.rates)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedResult, result)
+
+			if tt.expectedStored == nil {
+				require.Len(t, store.rates, len(tt.stored), "nothing stored")
+			} else {
+				require.Equal(t, tt.expectedStored, store.rates[len(tt.stored):])
+			}
+
+			var statuses []string
+			for _, anomaly := range store.anomalies {
+				statuses = append(statuses, anomaly.Status)
+			}
+
+			require.Equal(t, tt.expectedStatus, statuses)
+
+			if tt.expectedReason != "" {
+				require.Contains(t, store.anomalies[0].Reason, tt.expectedReason)
+				require.Equal(t, "USD", store.anomalies[0].Currency)
+				require.Equal(t, "banklv", store.anomalies[0].Source)
+			}
+		})
+	}
+}
+
+func TestGuardPerCurrencyThresholds(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig(30, `{"try": {"change": {"flag": 0.05, "hold": 0.2}}, "*": {"unchanged": {"flag": 0}}}`)
+	require.NoError(t, err)
+
+	require.Equal(t, Limit{Flag: 0.05, Hold: 0.2}, cfg.thresholds("TRY").Change)
+	require.Equal(t, DefaultThresholds.ZScore, cfg.thresholds("TRY").ZScore)
+	require.Equal(t, Limit{}, cfg.thresholds("TRY").Unchanged, "the * override applies to every currency")
+	require.Equal(t, DefaultThresholds.Change, cfg.thresholds("USD").Change)
+	require.Equal(t, Limit{}, cfg.thresholds("USD").Unchanged)
+
+	// A 30 % devaluation is held but not rejected
+	store := &mockStore{rates: []models.ExchangeRate{
+		{Currency: "TRY", Rate: decimal.RequireFromString("51.4941"), Date: start},
+	}}
+	guard := NewGuard(slog.Default(), store, cfg)
+
+	result, err := guard.SaveRates(t.Context(), "", []models.ExchangeRate{
+		{Currency: "TRY", Rate: decimal.RequireFromString("66.9423"), Date: start.AddDate(0, 0, 1)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Held)
+	require.Len(t, result.Withheld, 1)
+
+	for _, raw := range []string{`{`, `{"TRY": {"change": {"flag": -1}}}`, `{"DOLLAR": {}}`} {
+		_, err := ParseConfig(30, raw)
+		require.Error(t, err, raw)
+	}
+
+	_, err = ParseConfig(3, "")
+	require.EqualError(t, err, "anomaly window 3 is too short, at least 10 rates are needed")
+}
+
+func TestGuardNonPositiveHistory(t *testing.T) {
+	t.Parallel()
+
+	// A rate stored before the feeds were validated cannot be compared with, nothing is stored
+	store := &mockStore{rates: []models.ExchangeRate{usd(0, "0")}}
+	guard := NewGuard(slog.Default(), store, Config{Window: 30, Default: DefaultThresholds})
+
+	_, err := guard.SaveRates(t.Context(), "run-1", []models.ExchangeRate{usd(1, "1.18")})
+	require.EqualError(t, err, "check USD rate on 2026-01-02: previous rate 0 is not positive")
+	require.Len(t, store.rates, 1)
+	require.Empty(t, store.anomalies)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// AnomalyStore lists the rates the anomaly checks singled out and approves the held ones
+type AnomalyStore interface {
+	ListAnomalies(ctx context.Context, q models.AnomalyQuery) ([]models.RateAnomaly, error)
+	ApproveAnomaly(ctx context.Context, id int64) (models.RateAnomaly, error)
+}
+
+// WithAnomalyStore enables the rate anomaly endpoints
+func WithAnomalyStore(store AnomalyStore) Option {
+	return func(a *API) {
+		a.anomalies = store
+	}
+}
+
+// AnomaliesResponse represents the API response for rate anomalies
+type AnomaliesResponse struct {
+	Anomalies []models.RateAnomaly `json:"anomalies"`
+}
+
+// AnomaliesHandler lists rate anomalies, most recent first.
+// Supported query parameters: status (e.g. held), currency and limit.
+func (a *API) AnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+	if a.anomalies == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("anomaly store not configured"), "anomalies not available")
+
+		return
+	}
+
+	params := r.URL.Query()
+	q := models.AnomalyQuery{
+		Status:   params.Get("status"),
+		Currency: strings.ToUpper(params.Get("currency")),
+	}
+
+	if q.Status != "" && !slices.Contains(models.AnomalyStatuses, q.Status) {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("unknown status %q", q.Status),
+			"invalid status, expected one of "+strings.Join(models.AnomalyStatuses, ", "))
+
+		return
+	}
+
+	if q.Currency != "" && len(q.Currency) != 3 {
+		a.errorResponse(w, http.StatusBadRequest, errors.New("invalid currency format: must be 3 characters"), "invalid currency format")
+
+		return
+	}
+
+	var err error
+
+	q.Limit, err = parseLimit(r, defaultAdminListLimit, maxAdminListLimit)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	anomalies, err := a.anomalies.ListAnomalies(r.Context(), q)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("list rate anomalies: %w", err), "failed to fetch anomalies")
+
+		return
+	}
+
+	if anomalies == nil {
+		anomalies = []models.RateAnomaly{}
+	}
+
+	a.jsonResponse(w, http.StatusOK, AnomaliesResponse{Anomalies: anomalies})
+}
+
+// ApproveAnomalyHandler stores a held rate and returns its anomaly, now approved
+func (a *API) ApproveAnomalyHandler(w http.ResponseWriter, r *http.Request) {
+	if a.anomalies == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("anomaly store not configured"), "anomalies not available")
+
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("parse anomaly id %q", r.PathValue("id")), "invalid anomaly id")
+
+		return
+	}
+
+	anomaly, err := a.anomalies.ApproveAnomaly(r.Context(), id)
+
+	switch {
+	case errors.Is(err, models.ErrAnomalyNotFound):
+		a.errorResponse(w, http.StatusNotFound, err, "anomaly not found")
+	case errors.Is(err, models.ErrAnomalyNotHeld):
+		a.errorResponse(w, http.StatusConflict, err, "only held rates can be approved")
+	case err != nil:
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("approve rate anomaly %d: %w", id, err), "failed to approve anomaly")
+	default:
+		a.jsonResponse(w, http.StatusOK, anomaly)
+	}
+}
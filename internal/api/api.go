@@ -2,24 +2,56 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
 )
 
 type API struct {
-	logger     *slog.Logger
-	rateReader RateReader
+	logger        *slog.Logger
+	rateReader    RateReader
+	fetchRuns     FetchRunReader
+	quarantine    QuarantineReader
+	discrepancies DiscrepancyReader
+	anomalies     AnomalyStore
+	subscriptions SubscriptionStore
+	targets       TargetChecker
+	stream        RateStreamer
+
+	heartbeat time.Duration
+
+	roundingMode RoundingMode
+	precision    int32
+}
+
+// Option configures optional API behaviour.
+type Option func(*API)
+
+// WithRounding sets the rounding mode and number of decimal places used for converted amounts.
+func WithRounding(mode RoundingMode, precision int32) Option {
+	return func(a *API) {
+		a.roundingMode = mode
+		a.precision = precision
+	}
 }
 
-func NewAPI(logger *slog.Logger, rateReader RateReader) *API {
+func NewAPI(logger *slog.Logger, rateReader RateReader, opts ...Option) *API {
 	a := &API{
-		logger:     logger,
-		rateReader: rateReader,
+		logger:       logger,
+		rateReader:   rateReader,
+		roundingMode: RoundHalfEven,
+		precision:    2,
+	}
+
+	for _, opt := range opts {
+		opt(a)
 	}
 
 	a.logger = a.logger.With(slog.String("component", "API"))
@@ -29,21 +61,55 @@ func NewAPI(logger *slog.Logger, rateReader RateReader) *API {
 
 // LatestRatesResponse represents the API response for latest rates
 type LatestRatesResponse struct {
-	Rates []models.ExchangeRate `json:"rates"`
+	Base    string                `json:"base,omitempty"`
+	KnownAt *time.Time            `json:"known_at,omitempty"`
+	Rates   []models.ExchangeRate `json:"rates"`
 }
 
 // HistoricalRatesResponse represents the API response for historical rates
 type HistoricalRatesResponse struct {
-	Currency string                `json:"currency"`
-	History  []models.ExchangeRate `json:"history"`
+	Currency   string                `json:"currency"`
+	Base       string                `json:"base,omitempty"`
+	KnownAt    *time.Time            `json:"known_at,omitempty"`
+	History    []models.ExchangeRate `json:"history"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// AsOfRatesResponse represents the API response for rates as of a given date
+type AsOfRatesResponse struct {
+	Date    time.Time             `json:"date"`
+	KnownAt *time.Time            `json:"known_at,omitempty"`
+	Rates   []models.ExchangeRate `json:"rates"`
+}
+
+// RateRevisionsResponse represents the API response for the revisions of a single rate
+type RateRevisionsResponse struct {
+	Currency  string                `json:"currency"`
+	Date      time.Time             `json:"date"`
+	Revisions []models.RateRevision `json:"revisions"`
 }
 
 // RateReader defines the interface for reading exchange rates
 type RateReader interface {
-	GetLatestRates(ctx context.Context) ([]models.ExchangeRate, error)
-	GetHistoricalRates(ctx context.Context, currency string) ([]models.ExchangeRate, error)
+	// GetLatestRatesKnownAt and GetRatesAsOfKnownAt ignore rates stored after knownAt, the zero time reads the
+	// current ones. HistoryQuery carries the same bound for history reads.
+	GetLatestRatesKnownAt(ctx context.Context, knownAt time.Time) ([]models.ExchangeRate, error)
+	GetRatesAsOfKnownAt(ctx context.Context, date, knownAt time.Time) ([]models.ExchangeRate, error)
+	GetHistoricalRatesRange(ctx context.Context, currency string, q models.HistoryQuery) ([]models.ExchangeRate, error)
+	GetRateRevisions(ctx context.Context, currency string, date time.Time) ([]models.RateRevision, error)
 }
 
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// Page sizes of the admin endpoints listing fetch runs, quarantined items, discrepancies, anomalies and deliveries
+const (
+	defaultAdminListLimit = 50
+	maxAdminListLimit     = 1000
+)
+
 func (a *API) jsonResponse(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -60,8 +126,65 @@ func (a *API) errorResponse(w http.ResponseWriter, status int, err error, userMs
 	a.jsonResponse(w, status, map[string]string{"error": userMsg})
 }
 
+func (a *API) rebaseErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, errBaseRateMissing) {
+		a.errorResponse(w, http.StatusUnprocessableEntity, err, err.Error())
+
+		return
+	}
+
+	a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("rebase rates: %w", err), "failed to rebase rates")
+}
+
+// parseKnownAt reads the optional known_at query parameter, an RFC 3339 timestamp. The zero time means the
+// current rates are served.
+func parseKnownAt(r *http.Request) (time.Time, error) {
+	raw := r.URL.Query().Get("known_at")
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	knownAt, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New("invalid known_at, expected an RFC 3339 timestamp")
+	}
+
+	return knownAt.UTC(), nil
+}
+
+// echoKnownAt returns the known_at instant to include in a response, nil when the current rates were served
+func echoKnownAt(knownAt time.Time) *time.Time {
+	if knownAt.IsZero() {
+		return nil
+	}
+
+	return &knownAt
+}
+
+// LatestRateHandler returns the latest rate of every currency, optionally rebased onto the currency
+// given in the base query parameter and as known at the instant given in known_at. Like the other rate
+// handlers it answers in the format negotiated from Accept or the format query parameter.
 func (a *API) LatestRateHandler(w http.ResponseWriter, r *http.Request) {
-	rates, err := a.rateReader.GetLatestRates(r.Context())
+	format, ok := a.outputFormat(w, r)
+	if !ok {
+		return
+	}
+
+	base, err := parseBase(r)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	knownAt, err := parseKnownAt(r)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	rates, err := a.rateReader.GetLatestRatesKnownAt(r.Context(), knownAt)
 	if err != nil {
 		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf(
 			"get latest rates: %w", err,
@@ -75,12 +198,32 @@ func (a *API) LatestRateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.jsonResponse(w, http.StatusOK, LatestRatesResponse{
-		Rates: rates,
+	if base != "" && base != baseCurrency {
+		rates, err = a.rebaseLatest(r.Context(), base, rates, knownAt)
+		if err != nil {
+			a.rebaseErrorResponse(w, err)
+
+			return
+		}
+	}
+
+	a.ratesResponse(w, format, http.StatusOK, LatestRatesResponse{
+		Base:    base,
+		KnownAt: echoKnownAt(knownAt),
+		Rates:   rates,
 	})
 }
 
+// HistoryRateHandler returns a page of a currency's rates in ascending date order.
+// Supported query parameters: from and to (YYYY-MM-DD, inclusive), limit, the opaque cursor
+// returned as next_cursor by the previous page, base to rebase the quotes and known_at to read them as
+// stored at that instant.
 func (a *API) HistoryRateHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := a.outputFormat(w, r)
+	if !ok {
+		return
+	}
+
 	currency := r.PathValue("currency")
 
 	// ISO 4217
@@ -90,7 +233,38 @@ func (a *API) HistoryRateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rates, err := a.rateReader.GetHistoricalRates(r.Context(), currency)
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	base, err := parseBase(r)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	rebase := base != "" && base != baseCurrency
+	if rebase && base == currency {
+		a.errorResponse(w, http.StatusBadRequest, errors.New("currency equals base"), "currency must differ from base")
+
+		return
+	}
+
+	// EUR is not stored, when rebasing its history is derived from the base currency's quotes
+	lookup := currency
+	if rebase && currency == baseCurrency {
+		lookup = base
+	}
+
+	// Ask for one extra row to find out whether another page exists
+	limit := q.Limit
+	q.Limit++
+
+	rates, err := a.rateReader.GetHistoricalRatesRange(r.Context(), lookup, q)
 	if err != nil {
 		a.errorResponse(
 			w,
@@ -101,18 +275,246 @@ func (a *API) HistoryRateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(rates) == 0 {
+		// A range or cursor past every row is an empty page, only a currency without any rates is not found
+		known, err := a.hasHistory(r.Context(), lookup, q)
+		if err != nil {
+			a.errorResponse(
+				w,
+				http.StatusInternalServerError,
+				fmt.Errorf("get historical rates: %w", err),
+				"failed to fetch historical rates")
+
+			return
+		}
+
+		if !known {
+			a.errorResponse(
+				w,
+				http.StatusNotFound,
+				errors.New("no rates found"),
+				"no rates found for currency: "+currency)
+
+			return
+		}
+
+		rates = []models.ExchangeRate{}
+	}
+
+	var nextCursor string
+	if len(rates) > limit {
+		rates = rates[:limit]
+		nextCursor = encodeCursor(rates[limit-1].Date)
+
+		// Formats other than JSON have no place for next_cursor
+		w.Header().Set("Link", nextPageLink(r, nextCursor))
+	}
+
+	switch {
+	case rebase && lookup != currency:
+		rates, err = a.invertRates(rates)
+	case rebase:
+		rates, err = a.rebaseRates(r.Context(), base, rates, q.KnownAt)
+	}
+
+	if err != nil {
+		a.rebaseErrorResponse(w, err)
+
+		return
+	}
+
+	a.ratesResponse(w, format, http.StatusOK, HistoricalRatesResponse{
+		Currency:   currency,
+		Base:       base,
+		KnownAt:    echoKnownAt(q.KnownAt),
+		History:    rates,
+		NextCursor: nextCursor,
+	})
+}
+
+// hasHistory reports whether currency has any rates known at the query's known_at, regardless of its range and
+// cursor. A query without either has already read them all.
+func (a *API) hasHistory(ctx context.Context, currency string, q models.HistoryQuery) (bool, error) {
+	if q.From.IsZero() && q.To.IsZero() && q.After.IsZero() {
+		return false, nil
+	}
+
+	rates, err := a.rateReader.GetHistoricalRatesRange(ctx, currency, models.HistoryQuery{Limit: 1, KnownAt: q.KnownAt})
+	if err != nil {
+		return false, err
+	}
+
+	return len(rates) > 0, nil
+}
+
+// parseHistoryQuery reads the range and pagination parameters of a history request
+func parseHistoryQuery(r *http.Request) (models.HistoryQuery, error) {
+	params := r.URL.Query()
+	var q models.HistoryQuery
+
+	var err error
+
+	if raw := params.Get("from"); raw != "" {
+		if q.From, err = time.Parse(time.DateOnly, raw); err != nil {
+			return q, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+	}
+
+	if raw := params.Get("to"); raw != "" {
+		if q.To, err = time.Parse(time.DateOnly, raw); err != nil {
+			return q, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return q, errors.New("from date must not be after to date")
+	}
+
+	if q.Limit, err = parseLimit(r, defaultHistoryLimit, maxHistoryLimit); err != nil {
+		return q, err
+	}
+
+	if raw := params.Get("cursor"); raw != "" {
+		if q.After, err = decodeCursor(raw); err != nil {
+			return q, errors.New("invalid cursor")
+		}
+	}
+
+	if q.KnownAt, err = parseKnownAt(r); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// parseLimit reads the optional limit query parameter, between 1 and maxLimit and def when it is not given
+func parseLimit(r *http.Request, def, maxLimit int) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return def, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, fmt.Errorf("invalid limit, expected 1-%d", maxLimit)
+	}
+
+	return limit, nil
+}
+
+// nextPageLink returns a Link header value pointing at the page after cursor
+func nextPageLink(r *http.Request, cursor string) string {
+	next := *r.URL
+
+	params := next.Query()
+	params.Set("cursor", cursor)
+	next.RawQuery = params.Encode()
+
+	return "<" + next.RequestURI() + `>; rel="next"`
+}
+
+// encodeCursor turns the date of the last returned row into an opaque pagination token
+func encodeCursor(date time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(date.UTC().Format(time.RFC3339)))
+}
+
+func decodeCursor(cursor string) (time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decode cursor: %w", err)
+	}
+
+	return time.Parse(time.RFC3339, string(raw))
+}
+
+// AsOfRateHandler returns the most recent rate of every currency on or before the requested date, as known
+// at the instant given in known_at when set. Each rate carries the date it was actually published, so weekend
+// and holiday requests fall back to the previous business day.
+func (a *API) AsOfRateHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := a.outputFormat(w, r)
+	if !ok {
+		return
+	}
+
+	date, err := time.Parse(time.DateOnly, r.PathValue("date"))
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("parse date: %w", err), "invalid date format, expected YYYY-MM-DD")
+
+		return
+	}
+
+	knownAt, err := parseKnownAt(r)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	rates, err := a.rateReader.GetRatesAsOfKnownAt(r.Context(), date, knownAt)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf(
+			"get rates as of %s: %w", date.Format(time.DateOnly), err,
+		), "failed to fetch rates")
+
+		return
+	}
+
 	if len(rates) == 0 {
 		a.errorResponse(
 			w,
 			http.StatusNotFound,
 			errors.New("no rates found"),
-			"no rates found for currency: "+currency)
+			"no rates found on or before "+date.Format(time.DateOnly))
+
+		return
+	}
+
+	a.ratesResponse(w, format, http.StatusOK, AsOfRatesResponse{
+		Date:    date,
+		KnownAt: echoKnownAt(knownAt),
+		Rates:   rates,
+	})
+}
+
+// RateRevisionsHandler returns every value a currency's rate for a day has had, oldest first. The last revision
+// is the value currently served, its revised_at is when it took effect.
+func (a *API) RateRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	currency := r.PathValue("currency")
+
+	// ISO 4217
+	if len(currency) != 3 {
+		a.errorResponse(w, http.StatusBadRequest, errors.New("invalid currency format: must be 3 characters"), "invalid currency format")
+
+		return
+	}
+
+	date, err := time.Parse(time.DateOnly, r.PathValue("date"))
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("parse date: %w", err), "invalid date format, expected YYYY-MM-DD")
+
+		return
+	}
+
+	revisions, err := a.rateReader.GetRateRevisions(r.Context(), currency, date)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("get rate revisions: %w", err), "failed to fetch rate revisions")
+
+		return
+	}
+
+	if len(revisions) == 0 {
+		a.errorResponse(
+			w,
+			http.StatusNotFound,
+			errors.New("no revisions found"),
+			"no revisions found for "+currency+" on "+date.Format(time.DateOnly))
 
 		return
 	}
 
-	a.jsonResponse(w, http.StatusOK, HistoricalRatesResponse{
-		Currency: currency,
-		History:  rates,
+	a.jsonResponse(w, http.StatusOK, RateRevisionsResponse{
+		Currency:  currency,
+		Date:      date,
+		Revisions: revisions,
 	})
 }
@@ -1,15 +1,28 @@
 package api
 
 import (
+	"bufio"
+	"cmp"
 	"context"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/fetcher"
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/stream"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/webhook"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
@@ -19,14 +32,90 @@ type mockRateReader struct {
 	latestErr       error
 	historicalRates []models.ExchangeRate
 	historicalErr   error
+	revisions       []models.RateRevision
+	revisionsErr    error
+
+	// knownAt records the known_at bound of every read
+	knownAt []time.Time
 }
 
-func (m *mockRateReader) GetLatestRates(ctx context.Context) ([]models.ExchangeRate, error) {
+func (m *mockRateReader) GetLatestRatesKnownAt(ctx context.Context, knownAt time.Time) ([]models.ExchangeRate, error) {
+	m.knownAt = append(m.knownAt, knownAt)
+
 	return m.latestRates, m.latestErr
 }
 
-func (m *mockRateReader) GetHistoricalRates(ctx context.Context, currency string) ([]models.ExchangeRate, error) {
-	return m.historicalRates, m.historicalErr
+// GetRatesAsOfKnownAt derives the as-of view from historicalRates the same way the repository does
+func (m *mockRateReader) GetRatesAsOfKnownAt(ctx context.Context, date, knownAt time.Time) ([]models.ExchangeRate, error) {
+	m.knownAt = append(m.knownAt, knownAt)
+
+	if m.historicalErr != nil {
+		return nil, m.historicalErr
+	}
+
+	latest := map[string]models.ExchangeRate{}
+	for _, rate := range m.historicalRates {
+		if rate.Date.After(date) {
+			continue
+		}
+
+		if prev, ok := latest[rate.Currency]; !ok || rate.Date.After(prev.Date) {
+			latest[rate.Currency] = rate
+		}
+	}
+
+	rates := make([]models.ExchangeRate, 0, len(latest))
+	for _, rate := range latest {
+		rates = append(rates, rate)
+	}
+
+	slices.SortFunc(rates, func(a, b models.ExchangeRate) int {
+		return strings.Compare(a.Currency, b.Currency)
+	})
+
+	return rates, nil
+}
+
+// GetHistoricalRatesRange applies the query bounds to historicalRates the same way the repository does
+func (m *mockRateReader) GetHistoricalRatesRange(ctx context.Context, currency string, q models.HistoryQuery) ([]models.ExchangeRate, error) {
+	m.knownAt = append(m.knownAt, q.KnownAt)
+
+	if m.historicalErr != nil {
+		return nil, m.historicalErr
+	}
+
+	var rates []models.ExchangeRate
+	for _, rate := range m.historicalRates {
+		if rate.Currency != currency ||
+			(!q.From.IsZero() && rate.Date.Before(q.From)) ||
+			(!q.To.IsZero() && rate.Date.After(q.To)) ||
+			(!q.After.IsZero() && !rate.Date.After(q.After)) {
+			continue
+		}
+
+		rates = append(rates, rate)
+
+		if q.Limit > 0 && len(rates) == q.Limit {
+			break
+		}
+	}
+
+	return rates, nil
+}
+
+func (m *mockRateReader) GetRateRevisions(ctx context.Context, currency string, date time.Time) ([]models.RateRevision, error) {
+	if m.revisionsErr != nil {
+		return nil, m.revisionsErr
+	}
+
+	var revisions []models.RateRevision
+	for _, rev := range m.revisions {
+		if rev.Currency == currency && rev.Date.Equal(date) {
+			revisions = append(revisions, rev)
+		}
+	}
+
+	return revisions, nil
 }
 
 func TestLatestRateHandler(t *testing.T) {
@@ -202,3 +291,2058 @@ func TestHistoryRateHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertHandler(t *testing.T) {
+	t.Parallel()
+
+	day1 := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	latest := []models.ExchangeRate{
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86230000"), Date: day2},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.18010000"), Date: day2},
+	}
+
+	// A zero rate stored before rates were validated
+	day0 := time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC)
+
+	history := []models.ExchangeRate{
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86000000"), Date: day0},
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86580000"), Date: day1},
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86230000"), Date: day2},
+		{Currency: "USD", Rate: decimal.Zero, Date: day0},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.18400000"), Date: day1},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.18010000"), Date: day2},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		opts           []Option
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success - Latest cross rate",
+			query:          "from=USD&to=GBP&amount=125.50",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"from": "USD",
+				"to": "GBP",
+				"amount": "125.5",
+				"rate": "0.7307007881",
+				"date": "2026-02-03T00:00:00Z",
+				"result": "91.7"
+			}`,
+		},
+		{
+			name:           "Success - Historical date",
+			query:          "from=usd&to=gbp&amount=125.50&date=2026-02-02",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"from": "USD",
+				"to": "GBP",
+				"amount": "125.5",
+				"rate": "0.73125",
+				"date": "2026-02-02T00:00:00Z",
+				"result": "91.77"
+			}`,
+		},
+		{
+			name:           "Success - From EUR with configured rounding",
+			query:          "from=EUR&to=USD&amount=10.005",
+			opts:           []Option{WithRounding(RoundDown, 1)},
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"from": "EUR",
+				"to": "USD",
+				"amount": "10.005",
+				"rate": "1.1801",
+				"date": "2026-02-03T00:00:00Z",
+				"result": "11.8"
+			}`,
+		},
+		{
+			name:           "Error - Invalid Currency Format",
+			query:          "from=US&to=GBP&amount=1",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid currency format"}`,
+		},
+		{
+			name:           "Error - Invalid Amount",
+			query:          "from=USD&to=GBP&amount=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid amount"}`,
+		},
+		{
+			name:           "Error - Invalid Date",
+			query:          "from=USD&to=GBP&amount=1&date=03.02.2026",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid date format, expected YYYY-MM-DD"}`,
+		},
+		{
+			name:           "Success - Weekend falls back to previous business day",
+			query:          "from=GBP&to=USD&amount=100&date=2026-02-07",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"from": "GBP",
+				"to": "USD",
+				"amount": "100",
+				"rate": "1.3685492288",
+				"date": "2026-02-03T00:00:00Z",
+				"result": "136.85"
+			}`,
+		},
+		{
+			name:           "Error - No Rate On Date",
+			query:          "from=USD&to=GBP&amount=1&date=2026-01-01",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error": "rate not found for USD on or before 2026-01-01"}`,
+		},
+		{
+			name:           "Error - Stored Rate Not Positive",
+			query:          "from=USD&to=GBP&amount=1&date=2026-01-30",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to convert amount"}`,
+		},
+		{
+			name:           "Error - Fetch Failed",
+			query:          "from=USD&to=GBP&amount=1",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to fetch rates"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockRateReader{
+				latestRates:     latest,
+				latestErr:       tt.mockErr,
+				historicalRates: history,
+				historicalErr:   tt.mockErr,
+			}
+			api := NewAPI(slog.Default(), mock, tt.opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/convert?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.ConvertHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestAsOfRateHandler(t *testing.T) {
+	t.Parallel()
+
+	friday := time.Date(2026, 2, 6, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2026, 2, 9, 0, 0, 0, 0, time.UTC)
+
+	history := []models.ExchangeRate{
+		{Currency: "USD", Rate: decimal.RequireFromString("1.18"), Date: friday},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.19"), Date: monday},
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86"), Date: friday},
+	}
+
+	tests := []struct {
+		name           string
+		date           string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success - Weekend falls back to Friday",
+			date:           "2026-02-08",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"date": "2026-02-08T00:00:00Z",
+				"rates": [
+					{"currency": "GBP", "rate": "0.86", "date": "2026-02-06T00:00:00Z"},
+					{"currency": "USD", "rate": "1.18", "date": "2026-02-06T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "Success - Reports actual date per currency",
+			date:           "2026-02-09",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"date": "2026-02-09T00:00:00Z",
+				"rates": [
+					{"currency": "GBP", "rate": "0.86", "date": "2026-02-06T00:00:00Z"},
+					{"currency": "USD", "rate": "1.19", "date": "2026-02-09T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "Error - Invalid Date",
+			date:           "latest-ish",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid date format, expected YYYY-MM-DD"}`,
+		},
+		{
+			name:           "Error - Fetch Failed",
+			date:           "2026-02-09",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to fetch rates"}`,
+		},
+		{
+			name:           "Error - No Rates Before Date",
+			date:           "2026-01-01",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error": "no rates found on or before 2026-01-01"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockRateReader{
+				historicalRates: history,
+				historicalErr:   tt.mockErr,
+			}
+			api := NewAPI(slog.Default(), mock)
+
+			req := httptest.NewRequest(http.MethodGet, "/rates/"+tt.date, nil)
+			req.SetPathValue("date", tt.date)
+
+			rr := httptest.NewRecorder()
+
+			api.AsOfRateHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestHistoryRateHandlerPagination(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2026, 2, d, 0, 0, 0, 0, time.UTC) }
+
+	history := []models.ExchangeRate{
+		{Currency: "USD", Rate: decimal.RequireFromString("1.1"), Date: day(2)},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.2"), Date: day(3)},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.3"), Date: day(4)},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.4"), Date: day(5)},
+	}
+
+	tests := []struct {
+		name           string
+		currency       string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "First page has next cursor",
+			query:          "limit=2",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"currency": "USD",
+				"history": [
+					{"currency": "USD", "rate": "1.1", "date": "2026-02-02T00:00:00Z"},
+					{"currency": "USD", "rate": "1.2", "date": "2026-02-03T00:00:00Z"}
+				],
+				"next_cursor": "` + encodeCursor(day(3)) + `"
+			}`,
+		},
+		{
+			name:           "Last page has no cursor",
+			query:          "limit=2&cursor=" + encodeCursor(day(3)),
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"currency": "USD",
+				"history": [
+					{"currency": "USD", "rate": "1.3", "date": "2026-02-04T00:00:00Z"},
+					{"currency": "USD", "rate": "1.4", "date": "2026-02-05T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "Date range",
+			query:          "from=2026-02-03&to=2026-02-04",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"currency": "USD",
+				"history": [
+					{"currency": "USD", "rate": "1.2", "date": "2026-02-03T00:00:00Z"},
+					{"currency": "USD", "rate": "1.3", "date": "2026-02-04T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "Cursor past the last row is an empty page",
+			query:          "limit=2&cursor=" + encodeCursor(day(5)),
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"currency": "USD", "history": []}`,
+		},
+		{
+			name:           "Range without rates is an empty page",
+			query:          "from=2026-03-01&to=2026-03-31",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"currency": "USD", "history": []}`,
+		},
+		{
+			name:           "Rebased range without rates is an empty page",
+			query:          "from=2026-03-01&base=GBP",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"currency": "USD", "base": "GBP", "history": []}`,
+		},
+		{
+			name:           "Error - Unknown currency with a range",
+			currency:       "GBP",
+			query:          "from=2026-02-01",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error": "no rates found for currency: GBP"}`,
+		},
+		{
+			name:           "Error - From After To",
+			query:          "from=2026-02-05&to=2026-02-04",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "from date must not be after to date"}`,
+		},
+		{
+			name:           "Error - Invalid Limit",
+			query:          "limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid limit, expected 1-1000"}`,
+		},
+		{
+			name:           "Error - Invalid Cursor",
+			query:          "cursor=not-a-cursor",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid cursor"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			currency := cmp.Or(tt.currency, "USD")
+
+			api := NewAPI(slog.Default(), &mockRateReader{historicalRates: history})
+
+			req := httptest.NewRequest(http.MethodGet, "/history/"+currency+"?"+tt.query, nil)
+			req.SetPathValue("currency", currency)
+
+			rr := httptest.NewRecorder()
+
+			api.HistoryRateHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRebasedRates(t *testing.T) {
+	t.Parallel()
+
+	day1 := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	history := []models.ExchangeRate{
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86580000"), Date: day1},
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86230000"), Date: day2},
+		{Currency: "JPY", Rate: decimal.RequireFromString("183.59000000"), Date: day1},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.18400000"), Date: day1},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.18010000"), Date: day2},
+	}
+
+	// JPY lags a day behind, so its latest quote has to be rebased with the USD rate of day1
+	latest := []models.ExchangeRate{
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86230000"), Date: day2},
+		{Currency: "JPY", Rate: decimal.RequireFromString("183.59000000"), Date: day1},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.18010000"), Date: day2},
+	}
+
+	// Stored before rates were validated, nothing can be rebased on it
+	zeroUSD := []models.ExchangeRate{
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.86230000"), Date: day2},
+		{Currency: "USD", Rate: decimal.Zero, Date: day2},
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		currency       string
+		latestRates    []models.ExchangeRate
+		historicalRate []models.ExchangeRate
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Latest rebased on USD includes synthetic EUR",
+			url:            "/latest?base=usd",
+			latestRates:    latest,
+			historicalRate: history,
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"base": "USD",
+				"rates": [
+					{"currency": "EUR", "rate": "0.8473858148", "date": "2026-02-03T00:00:00Z"},
+					{"currency": "GBP", "rate": "0.7307007881", "date": "2026-02-03T00:00:00Z"},
+					{"currency": "JPY", "rate": "155.0591216216", "date": "2026-02-02T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "Latest with EUR base is unchanged",
+			url:            "/latest?base=EUR",
+			latestRates:    latest[:1],
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"base": "EUR",
+				"rates": [
+					{"currency": "GBP", "rate": "0.8623", "date": "2026-02-03T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "Latest base without a rate on a date",
+			url:            "/latest?base=GBP",
+			latestRates:    latest,
+			historicalRate: history[1:],
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error": "base currency has no rate: no GBP rate on 2026-02-02"}`,
+		},
+		{
+			name:           "Latest unknown base",
+			url:            "/latest?base=CHF",
+			latestRates:    latest,
+			historicalRate: history,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error": "base currency has no rate: no CHF rates stored"}`,
+		},
+		{
+			name:           "Latest invalid base",
+			url:            "/latest?base=DOLLAR",
+			latestRates:    latest,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid base currency format"}`,
+		},
+		{
+			name:           "History rebased on USD",
+			url:            "/history/GBP?base=USD",
+			currency:       "GBP",
+			historicalRate: history,
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"currency": "GBP",
+				"base": "USD",
+				"history": [
+					{"currency": "GBP", "rate": "0.73125", "date": "2026-02-02T00:00:00Z"},
+					{"currency": "GBP", "rate": "0.7307007881", "date": "2026-02-03T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "EUR history derived from base",
+			url:            "/history/EUR?base=USD",
+			currency:       "EUR",
+			historicalRate: history,
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"currency": "EUR",
+				"base": "USD",
+				"history": [
+					{"currency": "EUR", "rate": "0.8445945946", "date": "2026-02-02T00:00:00Z"},
+					{"currency": "EUR", "rate": "0.8473858148", "date": "2026-02-03T00:00:00Z"}
+				]
+			}`,
+		},
+		{
+			name:           "History base without a rate on a date",
+			url:            "/history/JPY?base=GBP",
+			currency:       "JPY",
+			historicalRate: history[1:],
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error": "base currency has no rate: no GBP rate on 2026-02-02"}`,
+		},
+		{
+			name:           "History currency equals base",
+			url:            "/history/USD?base=USD",
+			currency:       "USD",
+			historicalRate: history,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "currency must differ from base"}`,
+		},
+		{
+			name:           "Latest base with a rate that is not positive",
+			url:            "/latest?base=USD",
+			latestRates:    zeroUSD,
+			historicalRate: zeroUSD,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to rebase rates"}`,
+		},
+		{
+			name:           "EUR history from a base rate that is not positive",
+			url:            "/history/EUR?base=USD",
+			currency:       "EUR",
+			historicalRate: zeroUSD,
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to rebase rates"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(slog.Default(), &mockRateReader{
+				latestRates:     tt.latestRates,
+				historicalRates: tt.historicalRate,
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			if tt.currency != "" {
+				req.SetPathValue("currency", tt.currency)
+				api.HistoryRateHandler(rr, req)
+			} else {
+				api.LatestRateHandler(rr, req)
+			}
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRateRevisionsHandler(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, 2, 6, 0, 0, 0, 0, time.UTC)
+	published := time.Date(2026, 2, 6, 15, 0, 0, 0, time.UTC)
+
+	revisions := []models.RateRevision{
+		{
+			ID:        1,
+			Currency:  "USD",
+			Date:      date,
+			NewRate:   decimal.RequireFromString("1.18"),
+			Source:    "banklv",
+			RunID:     "run-1",
+			RevisedAt: published,
+		},
+		{
+			ID:        2,
+			Currency:  "USD",
+			Date:      date,
+			OldRate:   decimal.NewNullDecimal(decimal.RequireFromString("1.18")),
+			NewRate:   decimal.RequireFromString("1.1805"),
+			Source:    "ecb",
+			RunID:     "run-2",
+			RevisedAt: published.Add(time.Hour),
+		},
+	}
+
+	tests := []struct {
+		name           string
+		currency       string
+		date           string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success - Revisions oldest first",
+			currency:       "USD",
+			date:           "2026-02-06",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"currency": "USD",
+				"date": "2026-02-06T00:00:00Z",
+				"revisions": [
+					{
+						"id": 1, "currency": "USD", "date": "2026-02-06T00:00:00Z",
+						"old_rate": null, "new_rate": "1.18",
+						"source": "banklv", "run_id": "run-1", "revised_at": "2026-02-06T15:00:00Z"
+					},
+					{
+						"id": 2, "currency": "USD", "date": "2026-02-06T00:00:00Z",
+						"old_rate": "1.18", "new_rate": "1.1805",
+						"source": "ecb", "run_id": "run-2", "revised_at": "2026-02-06T16:00:00Z"
+					}
+				]
+			}`,
+		},
+		{
+			name:           "Error - No Revisions",
+			currency:       "USD",
+			date:           "2026-02-07",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error": "no revisions found for USD on 2026-02-07"}`,
+		},
+		{
+			name:           "Error - Invalid Currency",
+			currency:       "US",
+			date:           "2026-02-06",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid currency format"}`,
+		},
+		{
+			name:           "Error - Invalid Date",
+			currency:       "USD",
+			date:           "06-02-2026",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid date format, expected YYYY-MM-DD"}`,
+		},
+		{
+			name:           "Error - Fetch Failed",
+			currency:       "USD",
+			date:           "2026-02-06",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to fetch rate revisions"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockRateReader{
+				revisions:    revisions,
+				revisionsErr: tt.mockErr,
+			}
+			api := NewAPI(slog.Default(), mock)
+
+			req := httptest.NewRequest(http.MethodGet, "/rates/history/"+tt.currency+"/"+tt.date+"/revisions", nil)
+			req.SetPathValue("currency", tt.currency)
+			req.SetPathValue("date", tt.date)
+
+			rr := httptest.NewRecorder()
+
+			api.RateRevisionsHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestKnownAt(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+	knownAt := time.Date(2026, 2, 3, 15, 30, 0, 0, time.UTC)
+
+	rates := []models.ExchangeRate{
+		{Currency: "GBP", Rate: decimal.RequireFromString("0.8623"), Date: day},
+		{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: day},
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		handler         func(*API) http.HandlerFunc
+		expectedStatus  int
+		expectedBody    string
+		expectedKnownAt []time.Time
+	}{
+		{
+			name:           "Latest rebased reads every rate as known at",
+			url:            "/latest?base=USD&known_at=2026-02-03T17:30:00%2B02:00",
+			handler:        func(a *API) http.HandlerFunc { return a.LatestRateHandler },
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"base": "USD",
+				"known_at": "2026-02-03T15:30:00Z",
+				"rates": [
+					{"currency": "EUR", "rate": "0.8473858148", "date": "2026-02-03T00:00:00Z"},
+					{"currency": "GBP", "rate": "0.7307007881", "date": "2026-02-03T00:00:00Z"}
+				]
+			}`,
+			expectedKnownAt: []time.Time{knownAt, knownAt},
+		},
+		{
+			name:           "History",
+			url:            "/history/USD?known_at=2026-02-03T15:30:00Z",
+			handler:        func(a *API) http.HandlerFunc { return a.HistoryRateHandler },
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"currency": "USD",
+				"known_at": "2026-02-03T15:30:00Z",
+				"history": [{"currency": "USD", "rate": "1.1801", "date": "2026-02-03T00:00:00Z"}]
+			}`,
+			expectedKnownAt: []time.Time{knownAt},
+		},
+		{
+			name:           "As of",
+			url:            "/rates/2026-02-03?known_at=2026-02-03T15:30:00Z",
+			handler:        func(a *API) http.HandlerFunc { return a.AsOfRateHandler },
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"date": "2026-02-03T00:00:00Z",
+				"known_at": "2026-02-03T15:30:00Z",
+				"rates": [
+					{"currency": "GBP", "rate": "0.8623", "date": "2026-02-03T00:00:00Z"},
+					{"currency": "USD", "rate": "1.1801", "date": "2026-02-03T00:00:00Z"}
+				]
+			}`,
+			expectedKnownAt: []time.Time{knownAt},
+		},
+		{
+			name:           "Without known_at the current rates are read",
+			url:            "/rates/2026-02-03",
+			handler:        func(a *API) http.HandlerFunc { return a.AsOfRateHandler },
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"date": "2026-02-03T00:00:00Z",
+				"rates": [
+					{"currency": "GBP", "rate": "0.8623", "date": "2026-02-03T00:00:00Z"},
+					{"currency": "USD", "rate": "1.1801", "date": "2026-02-03T00:00:00Z"}
+				]
+			}`,
+			expectedKnownAt: []time.Time{{}},
+		},
+		{
+			name:           "Error - Invalid known_at",
+			url:            "/latest?known_at=2026-02-03",
+			handler:        func(a *API) http.HandlerFunc { return a.LatestRateHandler },
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid known_at, expected an RFC 3339 timestamp"}`,
+		},
+		{
+			name:           "Error - Invalid known_at in history",
+			url:            "/history/USD?known_at=yesterday",
+			handler:        func(a *API) http.HandlerFunc { return a.HistoryRateHandler },
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid known_at, expected an RFC 3339 timestamp"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockRateReader{
+				latestRates:     rates,
+				historicalRates: rates,
+			}
+			api := NewAPI(slog.Default(), mock)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.SetPathValue("currency", "USD")
+			req.SetPathValue("date", "2026-02-03")
+
+			rr := httptest.NewRecorder()
+
+			tt.handler(api)(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedKnownAt, mock.knownAt)
+		})
+	}
+}
+
+type mockFetchRunReader struct {
+	runs  []models.FetchRun
+	err   error
+	query models.FetchRunQuery
+}
+
+func (m *mockFetchRunReader) ListFetchRuns(ctx context.Context, q models.FetchRunQuery) ([]models.FetchRun, error) {
+	m.query = q
+
+	return m.runs, m.err
+}
+
+func TestFetchRunsHandler(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC)
+	finished := started.Add(2 * time.Second)
+
+	runs := []models.FetchRun{
+		{
+			ID:             "run-1",
+			Kind:           models.FetchRunKindFetch,
+			Status:         models.FetchRunPartial,
+			Source:         "banklv",
+			SourceURL:      "https://www.bank.lv/vk/ecb_rss.xml",
+			Currencies:     []string{"USD", "XXX"},
+			StartedAt:      started,
+			FinishedAt:     &finished,
+			SaveResult:     models.SaveResult{Inserted: 1},
+			CurrencyErrors: map[string]string{"XXX": "rate not found for currency 'XXX'"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockRuns       []models.FetchRun
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+		expectedQuery  models.FetchRunQuery
+	}{
+		{
+			name:           "Success",
+			query:          "?status=partial&limit=10",
+			mockRuns:       runs,
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"runs": [{
+					"id": "run-1",
+					"kind": "fetch",
+					"status": "partial",
+					"source": "banklv",
+					"source_url": "https://www.bank.lv/vk/ecb_rss.xml",
+					"currencies": ["USD", "XXX"],
+					"started_at": "2026-02-03T16:15:00Z",
+					"finished_at": "2026-02-03T16:15:02Z",
+					"inserted": 1,
+					"updated": 0,
+					"unchanged": 0,
+					"flagged": 0,
+					"held": 0,
+					"rejected": 0,
+					"quarantined": 0,
+					"currency_errors": {"XXX": "rate not found for currency 'XXX'"}
+				}]
+			}`,
+			expectedQuery: models.FetchRunQuery{Status: models.FetchRunPartial, Limit: 10},
+		},
+		{
+			name:           "Success - Empty journal",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"runs": []}`,
+			expectedQuery:  models.FetchRunQuery{Limit: defaultAdminListLimit},
+		},
+		{
+			name:           "Error - Invalid Status",
+			query:          "?status=broken",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid status, expected one of running, succeeded, partial, failed"}`,
+		},
+		{
+			name:           "Error - Invalid Limit",
+			query:          "?limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid limit, expected 1-1000"}`,
+		},
+		{
+			name:           "Error - Fetch Failed",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to fetch runs"}`,
+			expectedQuery:  models.FetchRunQuery{Limit: defaultAdminListLimit},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockFetchRunReader{runs: tt.mockRuns, err: tt.mockErr}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithFetchRunReader(mock))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/fetch-runs"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.FetchRunsHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedQuery, mock.query)
+		})
+	}
+}
+
+type mockQuarantineReader struct {
+	items []models.QuarantinedItem
+	err   error
+	limit int
+}
+
+func (m *mockQuarantineReader) ListQuarantinedItems(ctx context.Context, limit int) ([]models.QuarantinedItem, error) {
+	m.limit = limit
+
+	return m.items, m.err
+}
+
+func TestQuarantineHandler(t *testing.T) {
+	t.Parallel()
+
+	items := []models.QuarantinedItem{
+		{
+			ID:            7,
+			RunID:         "run-1",
+			Source:        "banklv",
+			Item:          "<Item><description>AUD 1.683 USD</description></Item>",
+			Reason:        "invalid feed item: odd number of fields (3), expected currency and rate pairs",
+			QuarantinedAt: time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC),
+		},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockItems      []models.QuarantinedItem
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+		expectedLimit  int
+	}{
+		{
+			name:           "Success",
+			query:          "?limit=10",
+			mockItems:      items,
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"items": [{
+					"id": 7,
+					"run_id": "run-1",
+					"source": "banklv",
+					"item": "<Item><description>AUD 1.683 USD</description></Item>",
+					"reason": "invalid feed item: odd number of fields (3), expected currency and rate pairs",
+					"quarantined_at": "2026-02-03T16:15:00Z"
+				}]
+			}`,
+			expectedLimit: 10,
+		},
+		{
+			name:           "Success - Empty quarantine",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"items": []}`,
+			expectedLimit:  defaultAdminListLimit,
+		},
+		{
+			name:           "Error - Invalid Limit",
+			query:          "?limit=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid limit, expected 1-1000"}`,
+		},
+		{
+			name:           "Error - Fetch Failed",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to fetch quarantined items"}`,
+			expectedLimit:  defaultAdminListLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockQuarantineReader{items: tt.mockItems, err: tt.mockErr}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithQuarantineReader(mock))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/quarantine"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.QuarantineHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedLimit, mock.limit)
+		})
+	}
+}
+
+type mockDiscrepancyReader struct {
+	discrepancies []models.RateDiscrepancy
+	err           error
+	query         models.DiscrepancyQuery
+}
+
+func (m *mockDiscrepancyReader) ListDiscrepancies(ctx context.Context, q models.DiscrepancyQuery) ([]models.RateDiscrepancy, error) {
+	m.query = q
+
+	return m.discrepancies, m.err
+}
+
+func TestDiscrepanciesHandler(t *testing.T) {
+	t.Parallel()
+
+	discrepancies := []models.RateDiscrepancy{
+		{
+			ID:       3,
+			RunID:    "run-1",
+			Currency: "USD",
+			Date:     time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+			Quotes: map[string]decimal.Decimal{
+				"banklv": decimal.RequireFromString("1.184"),
+				"ecb":    decimal.RequireFromString("1.1801"),
+			},
+			Chosen:       decimal.RequireFromString("1.1801"),
+			ChosenSource: "ecb",
+			Policy:       "median",
+			Spread:       decimal.RequireFromString("0.0033048"),
+			DetectedAt:   time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC),
+		},
+	}
+
+	tests := []struct {
+		name              string
+		query             string
+		mockDiscrepancies []models.RateDiscrepancy
+		mockErr           error
+		expectedStatus    int
+		expectedBody      string
+		expectedQuery     models.DiscrepancyQuery
+	}{
+		{
+			name:              "Success",
+			query:             "?currency=usd&limit=10",
+			mockDiscrepancies: discrepancies,
+			expectedStatus:    http.StatusOK,
+			expectedBody: `
+			{
+				"discrepancies": [{
+					"id": 3,
+					"run_id": "run-1",
+					"currency": "USD",
+					"date": "2026-02-03T00:00:00Z",
+					"quotes": {"banklv": "1.184", "ecb": "1.1801"},
+					"chosen": "1.1801",
+					"chosen_source": "ecb",
+					"policy": "median",
+					"spread": "0.0033048",
+					"detected_at": "2026-02-03T16:15:00Z"
+				}]
+			}`,
+			expectedQuery: models.DiscrepancyQuery{Currency: "USD", Limit: 10},
+		},
+		{
+			name:           "Success - No discrepancies",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"discrepancies": []}`,
+			expectedQuery:  models.DiscrepancyQuery{Limit: defaultAdminListLimit},
+		},
+		{
+			name:           "Error - Invalid Currency",
+			query:          "?currency=DOLLAR",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid currency format"}`,
+		},
+		{
+			name:           "Error - Invalid Limit",
+			query:          "?limit=5000",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid limit, expected 1-1000"}`,
+		},
+		{
+			name:           "Error - Fetch Failed",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to fetch discrepancies"}`,
+			expectedQuery:  models.DiscrepancyQuery{Limit: defaultAdminListLimit},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockDiscrepancyReader{discrepancies: tt.mockDiscrepancies, err: tt.mockErr}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithDiscrepancyReader(mock))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/discrepancies"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.DiscrepanciesHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedQuery, mock.query)
+		})
+	}
+}
+
+type mockAnomalyStore struct {
+	anomalies []models.RateAnomaly
+	err       error
+	query     models.AnomalyQuery
+	approved  int64
+}
+
+func (m *mockAnomalyStore) ListAnomalies(ctx context.Context, q models.AnomalyQuery) ([]models.RateAnomaly, error) {
+	m.query = q
+
+	return m.anomalies, m.err
+}
+
+func (m *mockAnomalyStore) ApproveAnomaly(ctx context.Context, id int64) (models.RateAnomaly, error) {
+	m.approved = id
+
+	if m.err != nil {
+		return models.RateAnomaly{}, m.err
+	}
+
+	return m.anomalies[0], nil
+}
+
+func TestAnomaliesHandler(t *testing.T) {
+	t.Parallel()
+
+	anomalies := []models.RateAnomaly{
+		{
+			ID:         4,
+			RunID:      "run-1",
+			Currency:   "USD",
+			Date:       time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+			Rate:       decimal.RequireFromString("1.32"),
+			Source:     "banklv",
+			Status:     models.AnomalyHeld,
+			Reason:     "changed 11.82 % from 1.1805, above 10.00 %",
+			DetectedAt: time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC),
+		},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockAnomalies  []models.RateAnomaly
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+		expectedQuery  models.AnomalyQuery
+	}{
+		{
+			name:           "Success",
+			query:          "?status=held&currency=usd&limit=10",
+			mockAnomalies:  anomalies,
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"anomalies": [{
+					"id": 4,
+					"run_id": "run-1",
+					"currency": "USD",
+					"date": "2026-02-03T00:00:00Z",
+					"rate": "1.32",
+					"source": "banklv",
+					"status": "held",
+					"reason": "changed 11.82 % from 1.1805, above 10.00 %",
+					"detected_at": "2026-02-03T16:15:00Z"
+				}]
+			}`,
+			expectedQuery: models.AnomalyQuery{Status: models.AnomalyHeld, Currency: "USD", Limit: 10},
+		},
+		{
+			name:           "Success - No anomalies",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"anomalies": []}`,
+			expectedQuery:  models.AnomalyQuery{Limit: defaultAdminListLimit},
+		},
+		{
+			name:           "Error - Invalid Status",
+			query:          "?status=pending",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid status, expected one of flagged, held, approved, rejected"}`,
+		},
+		{
+			name:           "Error - Invalid Currency",
+			query:          "?currency=DOLLAR",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid currency format"}`,
+		},
+		{
+			name:           "Error - Invalid Limit",
+			query:          "?limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid limit, expected 1-1000"}`,
+		},
+		{
+			name:           "Error - Fetch Failed",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to fetch anomalies"}`,
+			expectedQuery:  models.AnomalyQuery{Limit: defaultAdminListLimit},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockAnomalyStore{anomalies: tt.mockAnomalies, err: tt.mockErr}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithAnomalyStore(mock))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/anomalies"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			api.AnomaliesHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedQuery, mock.query)
+		})
+	}
+}
+
+func TestApproveAnomalyHandler(t *testing.T) {
+	t.Parallel()
+
+	resolvedAt := time.Date(2026, 2, 3, 17, 0, 0, 0, time.UTC)
+	approved := models.RateAnomaly{
+		ID:         4,
+		Currency:   "USD",
+		Date:       time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+		Rate:       decimal.RequireFromString("1.32"),
+		Status:     models.AnomalyApproved,
+		Reason:     "changed 11.82 % from 1.1805, above 10.00 %",
+		DetectedAt: time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC),
+		ResolvedAt: &resolvedAt,
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+		expectedID     int64
+	}{
+		{
+			name:           "Success",
+			id:             "4",
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"id": 4,
+				"currency": "USD",
+				"date": "2026-02-03T00:00:00Z",
+				"rate": "1.32",
+				"status": "approved",
+				"reason": "changed 11.82 % from 1.1805, above 10.00 %",
+				"detected_at": "2026-02-03T16:15:00Z",
+				"resolved_at": "2026-02-03T17:00:00Z"
+			}`,
+			expectedID: 4,
+		},
+		{
+			name:           "Error - Invalid ID",
+			id:             "four",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid anomaly id"}`,
+		},
+		{
+			name:           "Error - Not Found",
+			id:             "42",
+			mockErr:        fmt.Errorf("%w: 42", models.ErrAnomalyNotFound),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error": "anomaly not found"}`,
+			expectedID:     42,
+		},
+		{
+			name:           "Error - Not Held",
+			id:             "4",
+			mockErr:        fmt.Errorf("%w: anomaly 4 is rejected", models.ErrAnomalyNotHeld),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error": "only held rates can be approved"}`,
+			expectedID:     4,
+		},
+		{
+			name:           "Error - Approve Failed",
+			id:             "4",
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to approve anomaly"}`,
+			expectedID:     4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockAnomalyStore{anomalies: []models.RateAnomaly{approved}, err: tt.mockErr}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithAnomalyStore(mock))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/anomalies/"+tt.id+"/approve", nil)
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			api.ApproveAnomalyHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedID, mock.approved)
+		})
+	}
+}
+
+type mockSubscriptionStore struct {
+	subs       []models.Subscription
+	deliveries []models.WebhookDelivery
+	err        error
+
+	created models.Subscription
+	deleted int64
+	query   models.DeliveryQuery
+}
+
+func (m *mockSubscriptionStore) CreateSubscription(ctx context.Context, sub models.Subscription) (models.Subscription, error) {
+	m.created = sub
+	sub.ID = 7
+	sub.CreatedAt = time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC)
+
+	return sub, m.err
+}
+
+func (m *mockSubscriptionStore) ListSubscriptions(ctx context.Context) ([]models.Subscription, error) {
+	return m.subs, m.err
+}
+
+func (m *mockSubscriptionStore) DeleteSubscription(ctx context.Context, id int64) error {
+	m.deleted = id
+
+	return m.err
+}
+
+func (m *mockSubscriptionStore) ListDeliveries(ctx context.Context, q models.DeliveryQuery) ([]models.WebhookDelivery, error) {
+	m.query = q
+
+	return m.deliveries, m.err
+}
+
+// CheckTarget refuses the hosts of the internal network and fails to resolve the unknown ones
+func (m *mockSubscriptionStore) CheckTarget(ctx context.Context, target *url.URL) error {
+	switch target.Hostname() {
+	case "169.254.169.254", "db.internal":
+		return fmt.Errorf("%w: %s", webhook.ErrPrivateTarget, target.Hostname())
+	case "nowhere.invalid":
+		return errors.New("no such host")
+	default:
+		return nil
+	}
+}
+
+func TestCreateSubscriptionHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		body           string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+		expected       models.Subscription
+	}{
+		{
+			name:           "Success",
+			body:           `{"url": "https://treasury.example/hooks/rates", "currencies": ["usd", "GBP", "USD"], "condition": "change", "threshold": "0.5", "secret": "treasury-secret-1"}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody: `
+			{
+				"id": 7,
+				"url": "https://treasury.example/hooks/rates",
+				"currencies": ["USD", "GBP"],
+				"condition": "change",
+				"threshold": "0.5",
+				"secret": "treasury-secret-1",
+				"created_at": "2026-02-03T16:15:00Z"
+			}`,
+			expected: models.Subscription{
+				URL:        "https://treasury.example/hooks/rates",
+				Currencies: []string{"USD", "GBP"},
+				Condition:  models.ConditionChange,
+				Threshold:  decimal.NewNullDecimal(decimal.RequireFromString("0.5")),
+				Secret:     "treasury-secret-1",
+			},
+		},
+		{
+			name:           "Error - Unknown Field",
+			body:           `{"url": "https://treasury.example/hooks/rates", "condition": "published", "events": ["all"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid request body"}`,
+		},
+		{
+			name:           "Error - Invalid URL",
+			body:           `{"url": "treasury.example/hooks", "condition": "published"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid url, expected an absolute http or https URL"}`,
+		},
+		{
+			name:           "Error - Link-Local URL",
+			body:           `{"url": "http://169.254.169.254/latest/meta-data", "condition": "published"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid url, the host must resolve to public addresses"}`,
+		},
+		{
+			name:           "Error - Host Resolving To A Private Address",
+			body:           `{"url": "https://db.internal:3306/", "condition": "published"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid url, the host must resolve to public addresses"}`,
+		},
+		{
+			name:           "Error - Unresolvable Host",
+			body:           `{"url": "https://nowhere.invalid/hooks", "condition": "published"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid url, the host could not be resolved"}`,
+		},
+		{
+			name:           "Error - Invalid Currency",
+			body:           `{"url": "https://treasury.example/hooks", "currencies": ["DOLLAR"], "condition": "published"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid currency format"}`,
+		},
+		{
+			name:           "Error - Invalid Condition",
+			body:           `{"url": "https://treasury.example/hooks", "condition": "daily"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid condition, expected one of published, change, crossing"}`,
+		},
+		{
+			name:           "Error - Missing Threshold",
+			body:           `{"url": "https://treasury.example/hooks", "condition": "crossing"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "the crossing condition needs a positive threshold"}`,
+		},
+		{
+			name:           "Error - Threshold Without Use",
+			body:           `{"url": "https://treasury.example/hooks", "condition": "published", "threshold": 1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "threshold is only used by the change and crossing conditions"}`,
+		},
+		{
+			name:           "Error - Short Secret",
+			body:           `{"url": "https://treasury.example/hooks", "condition": "published", "secret": "hunter2"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "secret must be at least 16 characters"}`,
+		},
+		{
+			name:           "Error - Create Failed",
+			body:           `{"url": "https://treasury.example/hooks", "condition": "published"}`,
+			mockErr:        errors.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error": "failed to create subscription"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockSubscriptionStore{err: tt.mockErr}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithSubscriptionStore(mock, mock))
+
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			api.CreateSubscriptionHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+
+			if tt.expectedStatus == http.StatusCreated {
+				require.Equal(t, tt.expected, mock.created)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionHandlerGeneratesSecret(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockSubscriptionStore{}
+	api := NewAPI(slog.Default(), &mockRateReader{}, WithSubscriptionStore(mock, mock))
+
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions",
+		strings.NewReader(`{"url": "http://localhost:9000/hook", "condition": "published"}`))
+	rr := httptest.NewRecorder()
+
+	api.CreateSubscriptionHandler(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Len(t, mock.created.Secret, 64)
+	require.Contains(t, rr.Body.String(), `"secret":"`+mock.created.Secret+`"`)
+	require.Contains(t, rr.Body.String(), `"currencies":[]`)
+}
+
+func TestSubscriptionsHandler(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockSubscriptionStore{subs: []models.Subscription{{
+		ID:         1,
+		URL:        "https://treasury.example/hooks/rates",
+		Currencies: []string{"USD"},
+		Condition:  models.ConditionCrossing,
+		Threshold:  decimal.NewNullDecimal(decimal.RequireFromString("1.2")),
+		CreatedAt:  time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC),
+	}}}
+	api := NewAPI(slog.Default(), &mockRateReader{}, WithSubscriptionStore(mock, mock))
+
+	rr := httptest.NewRecorder()
+	api.SubscriptionsHandler(rr, httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `
+	{
+		"subscriptions": [{
+			"id": 1,
+			"url": "https://treasury.example/hooks/rates",
+			"currencies": ["USD"],
+			"condition": "crossing",
+			"threshold": "1.2",
+			"created_at": "2026-02-03T16:15:00Z"
+		}]
+	}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	NewAPI(slog.Default(), &mockRateReader{}, WithSubscriptionStore(&mockSubscriptionStore{}, &mockSubscriptionStore{})).
+		SubscriptionsHandler(rr, httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+
+	require.JSONEq(t, `{"subscriptions": []}`, rr.Body.String())
+}
+
+func TestDeleteSubscriptionHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		id             string
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Success",
+			id:             "3",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Error - Invalid ID",
+			id:             "0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid subscription id"}`,
+		},
+		{
+			name:           "Error - Not Found",
+			id:             "3",
+			mockErr:        fmt.Errorf("%w: 3", models.ErrSubscriptionNotFound),
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error": "subscription not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockSubscriptionStore{err: tt.mockErr}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithSubscriptionStore(mock, mock))
+
+			req := httptest.NewRequest(http.MethodDelete, "/subscriptions/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			api.DeleteSubscriptionHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedBody == "" {
+				require.Empty(t, rr.Body.String())
+				require.EqualValues(t, 3, mock.deleted)
+			} else {
+				require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeliveriesHandler(t *testing.T) {
+	t.Parallel()
+
+	delivered := time.Date(2026, 2, 3, 16, 20, 1, 0, time.UTC)
+	deliveries := []models.WebhookDelivery{{
+		ID:             12,
+		SubscriptionID: 1,
+		RunID:          "run-1",
+		Event:          "rates.change",
+		Payload:        `{"event":"rates.change"}`,
+		Status:         models.DeliveryDelivered,
+		Attempts:       2,
+		ResponseStatus: 204,
+		NextAttemptAt:  time.Date(2026, 2, 3, 16, 20, 30, 0, time.UTC),
+		CreatedAt:      time.Date(2026, 2, 3, 16, 20, 0, 0, time.UTC),
+		DeliveredAt:    &delivered,
+		URL:            "https://treasury.example/hooks/rates",
+		Secret:         "treasury-secret-1",
+	}}
+
+	tests := []struct {
+		name           string
+		query          string
+		mockDeliveries []models.WebhookDelivery
+		expectedStatus int
+		expectedBody   string
+		expectedQuery  models.DeliveryQuery
+	}{
+		{
+			name:           "Success",
+			query:          "?status=delivered&limit=10",
+			mockDeliveries: deliveries,
+			expectedStatus: http.StatusOK,
+			expectedBody: `
+			{
+				"deliveries": [{
+					"id": 12,
+					"subscription_id": 1,
+					"run_id": "run-1",
+					"event": "rates.change",
+					"payload": "{\"event\":\"rates.change\"}",
+					"status": "delivered",
+					"attempts": 2,
+					"response_status": 204,
+					"next_attempt_at": "2026-02-03T16:20:30Z",
+					"created_at": "2026-02-03T16:20:00Z",
+					"delivered_at": "2026-02-03T16:20:01Z"
+				}]
+			}`,
+			expectedQuery: models.DeliveryQuery{SubscriptionID: 1, Status: models.DeliveryDelivered, Limit: 10},
+		},
+		{
+			name:           "Success - No deliveries",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"deliveries": []}`,
+			expectedQuery:  models.DeliveryQuery{SubscriptionID: 1, Limit: defaultAdminListLimit},
+		},
+		{
+			name:           "Error - Invalid Status",
+			query:          "?status=sent",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid status, expected one of pending, delivered, failed"}`,
+		},
+		{
+			name:           "Error - Invalid Limit",
+			query:          "?limit=many",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid limit, expected 1-1000"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockSubscriptionStore{deliveries: tt.mockDeliveries}
+			api := NewAPI(slog.Default(), &mockRateReader{}, WithSubscriptionStore(mock, mock))
+
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions/1/deliveries"+tt.query, nil)
+			req.SetPathValue("id", "1")
+			rr := httptest.NewRecorder()
+
+			api.DeliveriesHandler(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			require.Equal(t, tt.expectedQuery, mock.query)
+		})
+	}
+}
+
+// revisionLog is an in-memory revision log feeding a stream.Broker
+type revisionLog struct {
+	mu        sync.Mutex
+	revisions []models.RateRevision
+	polls     int
+}
+
+func (m *revisionLog) add(currency, rate string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revisions = append(m.revisions, models.RateRevision{
+		ID:        int64(len(m.revisions) + 1),
+		Currency:  currency,
+		Date:      time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+		NewRate:   decimal.RequireFromString(rate),
+		Source:    "banklv",
+		RunID:     "run-1",
+		RevisedAt: time.Date(2026, 2, 3, 16, 20, 0, 0, time.UTC),
+	})
+}
+
+func (m *revisionLog) LatestRevisionID(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return int64(len(m.revisions)), nil
+}
+
+func (m *revisionLog) RevisionsAfter(ctx context.Context, afterID int64, limit int) ([]models.RateRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.polls++
+
+	var revisions []models.RateRevision
+	for _, rev := range m.revisions {
+		if rev.ID > afterID && len(revisions) < limit {
+			revisions = append(revisions, rev)
+		}
+	}
+
+	return revisions, nil
+}
+
+// streamEvent is a server-sent event as read by a client
+type streamEvent struct {
+	id, event, data string
+}
+
+// readEvent reads the next event from a stream, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) streamEvent {
+	t.Helper()
+
+	var event streamEvent
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && event.id != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestRateStreamHandler(t *testing.T) {
+	t.Parallel()
+
+	revLog := &revisionLog{}
+	revLog.add("USD", "1.1801")
+	revLog.add("GBP", "0.8658")
+	revLog.add("USD", "1.1805")
+
+	broker := stream.NewBroker(slog.Default(), revLog, time.Millisecond)
+
+	brokerCtx, stopBroker := context.WithCancel(t.Context())
+	brokerDone := make(chan struct{})
+
+	go func() {
+		defer close(brokerDone)
+
+		_ = broker.Run(brokerCtx)
+	}()
+
+	defer func() {
+		stopBroker()
+		<-brokerDone
+	}()
+
+	// Revisions stored once the broker polls are published
+	require.Eventually(t, func() bool {
+		revLog.mu.Lock()
+		defer revLog.mu.Unlock()
+
+		return revLog.polls > 0
+	}, 5*time.Second, time.Millisecond)
+
+	api := NewAPI(slog.Default(), &mockRateReader{}, WithRateStream(broker, time.Millisecond))
+
+	server := httptest.NewServer(http.HandlerFunc(api.RateStreamHandler))
+	defer server.Close()
+
+	connect := func(query, lastEventID string) *bufio.Reader {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+query, nil)
+		require.NoError(t, err)
+
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() }) // nolint:errcheck // Test cleanup
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		require.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+		return bufio.NewReader(resp.Body)
+	}
+
+	// Resuming after event 1 replays the missed USD revision, then follows live ones
+	resumed := connect("?currency=usd", "1")
+	fresh := connect("", "")
+
+	event := readEvent(t, resumed)
+	require.Equal(t, streamEvent{
+		id:    "3",
+		event: "rate",
+		data: `{"id":3,"currency":"USD","date":"2026-02-03T00:00:00Z","old_rate":null,"new_rate":"1.1805",` +
+			`"source":"banklv","run_id":"run-1","revised_at":"2026-02-03T16:20:00Z"}`,
+	}, event)
+
+	revLog.add("GBP", "0.8660")
+	revLog.add("USD", "1.1810")
+
+	require.Equal(t, "5", readEvent(t, resumed).id, "the GBP revision is filtered out")
+
+	// A client connecting without Last-Event-ID only gets the revisions stored after it connected
+	require.Equal(t, "4", readEvent(t, fresh).id)
+	require.Equal(t, "5", readEvent(t, fresh).id)
+
+	// Stopping the broker ends the streams
+	stopBroker()
+	<-brokerDone
+
+	_, err := io.ReadAll(fresh)
+	require.NoError(t, err)
+}
+
+func TestRateStreamHandlerErrors(t *testing.T) {
+	t.Parallel()
+
+	broker := stream.NewBroker(slog.Default(), &revisionLog{}, time.Second)
+
+	tests := []struct {
+		name           string
+		streamer       RateStreamer
+		query          string
+		lastEventID    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Error - Stream Not Configured",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error": "rate stream not available"}`,
+		},
+		{
+			name:           "Error - Invalid Currency",
+			streamer:       broker,
+			query:          "?currency=USD,EURO",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid currency format"}`,
+		},
+		{
+			name:           "Error - Invalid Last-Event-ID",
+			streamer:       broker,
+			lastEventID:    "abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid Last-Event-ID, expected an event id"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []Option
+			if tt.streamer != nil {
+				opts = append(opts, WithRateStream(tt.streamer, time.Second))
+			}
+
+			api := NewAPI(slog.Default(), &mockRateReader{}, opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/rates/stream"+tt.query, nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+
+			rec := httptest.NewRecorder()
+			api.RateStreamHandler(rec, req)
+
+			require.Equal(t, tt.expectedStatus, rec.Code)
+			require.JSONEq(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		query    string
+		accept   string
+		expected string
+		err      error
+	}{
+		{name: "No Accept", expected: formatJSON},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: formatJSON},
+		{name: "CSV", accept: "text/csv", expected: formatCSV},
+		{name: "SDMX-JSON with version", accept: "application/vnd.sdmx.data+json;version=1.0.0", expected: formatSDMX},
+		{name: "Highest quality wins", accept: "application/json;q=0.5, text/csv;q=0.8", expected: formatCSV},
+		{name: "First of equal quality wins", accept: "text/xml, text/csv", expected: formatXML},
+		{name: "Wildcard", accept: "*/*", expected: formatJSON},
+		{name: "Unparsable header", accept: ";;", expected: formatJSON},
+		{name: "Format parameter overrides Accept", query: "?format=sdmx", accept: "text/csv", expected: formatSDMX},
+		{name: "Nothing acceptable", accept: "image/png, text/plain", err: errNotAcceptable},
+		{name: "Refused type", accept: "text/csv;q=0", err: errNotAcceptable},
+		{name: "Unknown format parameter", query: "?format=xlsx", err: errNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/rates/latest"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			format, err := negotiateFormat(req)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestRateFormats(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2026, 2, d, 0, 0, 0, 0, time.UTC) }
+
+	reader := &mockRateReader{
+		latestRates: []models.ExchangeRate{
+			{Currency: "GBP", Rate: decimal.RequireFromString("0.8658"), Date: day(3)},
+			{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: day(3)},
+		},
+		historicalRates: []models.ExchangeRate{
+			{Currency: "USD", Rate: decimal.RequireFromString("1.1795"), Date: day(2)},
+			{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: day(3)},
+			{Currency: "USD", Rate: decimal.RequireFromString("1.1810"), Date: day(4)},
+		},
+	}
+
+	api := NewAPI(slog.Default(), reader)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/rates/latest", api.LatestRateHandler)
+	mux.HandleFunc("GET /api/v1/rates/history/{currency}", api.HistoryRateHandler)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/api/v1/rates/history/USD?limit=2", "text/csv")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		require.Equal(t, "Accept", rec.Header().Get("Vary"))
+		require.Equal(t, "date,currency,base,rate\n2026-02-02,USD,EUR,1.1795\n2026-02-03,USD,EUR,1.1801\n", rec.Body.String())
+		require.Equal(t, `</api/v1/rates/history/USD?cursor=`+encodeCursor(day(3))+`&limit=2>; rel="next"`, rec.Header().Get("Link"))
+	})
+
+	t.Run("Gesmes XML", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/api/v1/rates/history/USD?format=xml", "")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+		require.Contains(t, rec.Body.String(), `<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">`)
+
+		// The ECB fetcher reads it like the ECB's own feed, newest day first
+		var envelope fetcher.ECBEnvelope
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &envelope))
+		require.Equal(t, []fetcher.ECBDay{
+			{Time: "2026-02-04", Rates: []fetcher.ECBRate{{Currency: "USD", Rate: "1.181"}}},
+			{Time: "2026-02-03", Rates: []fetcher.ECBRate{{Currency: "USD", Rate: "1.1801"}}},
+			{Time: "2026-02-02", Rates: []fetcher.ECBRate{{Currency: "USD", Rate: "1.1795"}}},
+		}, envelope.Days)
+	})
+
+	t.Run("Gesmes XML of rebased rates", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/api/v1/rates/latest?base=USD", "application/xml")
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.JSONEq(t, `{"error": "the xml format only carries EUR based rates"}`, rec.Body.String())
+	})
+
+	t.Run("SDMX-JSON", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/api/v1/rates/latest", "application/vnd.sdmx.data+json")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "application/vnd.sdmx.data+json; version=1.0.0; charset=utf-8", rec.Header().Get("Content-Type"))
+
+		var message sdmxMessage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &message))
+
+		require.NotEmpty(t, message.Header.ID)
+		require.Equal(t, map[string]sdmxSeries{
+			"0:0:0:0:0": {Observations: map[string][]json.Number{"0": {"0.8658"}}},
+			"0:1:0:0:0": {Observations: map[string][]json.Number{"0": {"1.1801"}}},
+		}, message.DataSets[0].Series)
+
+		dimensions := message.Structure.Dimensions
+		require.Equal(t, []sdmxValue{{ID: "GBP", Name: "GBP"}, {ID: "USD", Name: "USD"}}, dimensions.Series[1].Values)
+		require.Equal(t, []sdmxValue{{ID: "EUR", Name: "EUR"}}, dimensions.Series[2].Values)
+		require.Equal(t, []sdmxValue{{ID: "2026-02-03", Name: "2026-02-03"}}, dimensions.Observation[0].Values)
+		require.Contains(t, rec.Body.String(), `"observations":{"0":[1.1801]}`, "rates are JSON numbers")
+	})
+
+	t.Run("Not acceptable", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/api/v1/rates/latest", "text/plain")
+
+		require.Equal(t, http.StatusNotAcceptable, rec.Code)
+		require.JSONEq(t, `{"error": "unsupported format, expected one of json, csv, xml, sdmx"}`, rec.Body.String())
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		t.Parallel()
+
+		rec := get("/api/v1/rates/latest?format=xlsx", "")
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
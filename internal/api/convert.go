@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// baseCurrency is the currency all stored rates are quoted against (ECB reference rates).
+const baseCurrency = "EUR"
+
+// ratePrecision is the number of decimal places reported for derived cross rates.
+const ratePrecision int32 = 10
+
+var errRateNotFound = errors.New("rate not found")
+
+// RoundingMode selects how converted amounts are rounded.
+type RoundingMode string
+
+const (
+	RoundHalfUp   RoundingMode = "half_up"   // half away from zero
+	RoundHalfEven RoundingMode = "half_even" // banker's rounding
+	RoundUp       RoundingMode = "up"        // away from zero
+	RoundDown     RoundingMode = "down"      // towards zero (truncate)
+	RoundCeil     RoundingMode = "ceil"      // towards +infinity
+	RoundFloor    RoundingMode = "floor"     // towards -infinity
+)
+
+// ParseRoundingMode validates a rounding mode name
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	mode := RoundingMode(strings.ToLower(strings.TrimSpace(s)))
+
+	switch mode {
+	case RoundHalfUp, RoundHalfEven, RoundUp, RoundDown, RoundCeil, RoundFloor:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown rounding mode %q", s)
+	}
+}
+
+func (m RoundingMode) round(d decimal.Decimal, places int32) decimal.Decimal {
+	switch m {
+	case RoundHalfUp:
+		return d.Round(places)
+	case RoundUp:
+		return d.RoundUp(places)
+	case RoundDown:
+		return d.RoundDown(places)
+	case RoundCeil:
+		return d.RoundCeil(places)
+	case RoundFloor:
+		return d.RoundFloor(places)
+	default:
+		return d.RoundBank(places)
+	}
+}
+
+// ConvertResponse represents the API response for a currency conversion
+type ConvertResponse struct {
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Amount decimal.Decimal `json:"amount"`
+	Rate   decimal.Decimal `json:"rate"`
+	Date   time.Time       `json:"date"`
+	Result decimal.Decimal `json:"result"`
+}
+
+func (a *API) ConvertHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from := strings.ToUpper(query.Get("from"))
+	to := strings.ToUpper(query.Get("to"))
+
+	// ISO 4217
+	if len(from) != 3 || len(to) != 3 {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("invalid currency pair %q/%q", from, to), "invalid currency format")
+
+		return
+	}
+
+	amount, err := decimal.NewFromString(query.Get("amount"))
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("parse amount: %w", err), "invalid amount")
+
+		return
+	}
+
+	var date *time.Time
+	if raw := query.Get("date"); raw != "" {
+		d, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("parse date: %w", err), "invalid date format, expected YYYY-MM-DD")
+
+			return
+		}
+
+		date = &d
+	}
+
+	rates, err := a.lookupRates(r.Context(), date, from, to)
+	if errors.Is(err, errRateNotFound) {
+		a.errorResponse(w, http.StatusNotFound, err, err.Error())
+
+		return
+	}
+
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("lookup rates: %w", err), "failed to fetch rates")
+
+		return
+	}
+
+	fromRate, toRate := rates[from], rates[to]
+
+	if err := checkDivisor(fromRate.Currency, fromRate.Rate, fromRate.Date); err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, err, "failed to convert amount")
+
+		return
+	}
+
+	a.jsonResponse(w, http.StatusOK, ConvertResponse{
+		From:   from,
+		To:     to,
+		Amount: amount,
+		Rate:   a.roundingMode.round(toRate.Rate.Div(fromRate.Rate), ratePrecision),
+		Date:   effectiveDate(fromRate, toRate),
+		Result: a.roundingMode.round(amount.Mul(toRate.Rate).Div(fromRate.Rate), a.precision),
+	})
+}
+
+// lookupRates resolves the EUR-based rate of every requested currency, either as of the given date
+// (falling back to the previous business day) or, when date is nil, the latest stored one.
+// EUR itself is synthesised with a rate of 1.
+func (a *API) lookupRates(ctx context.Context, date *time.Time, currencies ...string) (map[string]models.ExchangeRate, error) {
+	var (
+		rates []models.ExchangeRate
+		err   error
+	)
+
+	if date == nil {
+		rates, err = a.rateReader.GetLatestRatesKnownAt(ctx, time.Time{})
+	} else {
+		rates, err = a.rateReader.GetRatesAsOfKnownAt(ctx, *date, time.Time{})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get rates: %w", err)
+	}
+
+	result := make(map[string]models.ExchangeRate, len(currencies))
+
+	for _, currency := range currencies {
+		if currency == baseCurrency {
+			continue
+		}
+
+		idx := slices.IndexFunc(rates, func(rate models.ExchangeRate) bool {
+			return rate.Currency == currency
+		})
+
+		if idx == -1 {
+			if date != nil {
+				return nil, fmt.Errorf("%w for %s on or before %s", errRateNotFound, currency, date.Format(time.DateOnly))
+			}
+
+			return nil, fmt.Errorf("%w for %s", errRateNotFound, currency)
+		}
+
+		result[currency] = rates[idx]
+	}
+
+	if !slices.Contains(currencies, baseCurrency) {
+		return result, nil
+	}
+
+	eur := models.ExchangeRate{Currency: baseCurrency, Rate: decimal.NewFromInt(1)}
+
+	// EUR is quoted on whatever day the other side of the pair was
+	switch {
+	case len(result) > 0:
+		for _, rate := range result {
+			if rate.Date.After(eur.Date) {
+				eur.Date = rate.Date
+			}
+		}
+	case date != nil:
+		eur.Date = *date
+	default:
+		eur.Date = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	result[baseCurrency] = eur
+
+	return result, nil
+}
+
+// effectiveDate reports the date a conversion is based on: the older of the two quotes used.
+func effectiveDate(from, to models.ExchangeRate) time.Time {
+	if from.Date.Before(to.Date) {
+		return from.Date
+	}
+
+	return to.Date
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// DiscrepancyReader reads the rates reconciled sources disagreed on
+type DiscrepancyReader interface {
+	ListDiscrepancies(ctx context.Context, q models.DiscrepancyQuery) ([]models.RateDiscrepancy, error)
+}
+
+// WithDiscrepancyReader enables the rate discrepancies endpoint
+func WithDiscrepancyReader(reader DiscrepancyReader) Option {
+	return func(a *API) {
+		a.discrepancies = reader
+	}
+}
+
+// DiscrepanciesResponse represents the API response for rate discrepancies
+type DiscrepanciesResponse struct {
+	Discrepancies []models.RateDiscrepancy `json:"discrepancies"`
+}
+
+// DiscrepanciesHandler lists the rates reconciled sources disagreed on, most recent first.
+// Supported query parameters: currency to list only that currency's discrepancies and limit.
+func (a *API) DiscrepanciesHandler(w http.ResponseWriter, r *http.Request) {
+	if a.discrepancies == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("discrepancy reader not configured"), "discrepancies not available")
+
+		return
+	}
+
+	params := r.URL.Query()
+	q := models.DiscrepancyQuery{Currency: strings.ToUpper(params.Get("currency"))}
+
+	if q.Currency != "" && len(q.Currency) != 3 {
+		a.errorResponse(w, http.StatusBadRequest, errors.New("invalid currency format: must be 3 characters"), "invalid currency format")
+
+		return
+	}
+
+	var err error
+
+	q.Limit, err = parseLimit(r, defaultAdminListLimit, maxAdminListLimit)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	discrepancies, err := a.discrepancies.ListDiscrepancies(r.Context(), q)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("list rate discrepancies: %w", err),
+			"failed to fetch discrepancies")
+
+		return
+	}
+
+	if discrepancies == nil {
+		discrepancies = []models.RateDiscrepancy{}
+	}
+
+	a.jsonResponse(w, http.StatusOK, DiscrepanciesResponse{Discrepancies: discrepancies})
+}
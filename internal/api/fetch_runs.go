@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// FetchRunReader reads the journal of fetch runs
+type FetchRunReader interface {
+	ListFetchRuns(ctx context.Context, q models.FetchRunQuery) ([]models.FetchRun, error)
+}
+
+// WithFetchRunReader enables the fetch run journal endpoint
+func WithFetchRunReader(reader FetchRunReader) Option {
+	return func(a *API) {
+		a.fetchRuns = reader
+	}
+}
+
+// FetchRunsResponse represents the API response for the fetch run journal
+type FetchRunsResponse struct {
+	Runs []models.FetchRun `json:"runs"`
+}
+
+// FetchRunsHandler lists journaled fetch and backfill runs, most recent first.
+// Supported query parameters: status to list only runs with that outcome and limit.
+func (a *API) FetchRunsHandler(w http.ResponseWriter, r *http.Request) {
+	if a.fetchRuns == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("fetch run reader not configured"), "fetch run journal not available")
+
+		return
+	}
+
+	params := r.URL.Query()
+	q := models.FetchRunQuery{Status: params.Get("status")}
+
+	if q.Status != "" && !slices.Contains(models.FetchRunStatuses, q.Status) {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("unknown status %q", q.Status),
+			"invalid status, expected one of "+strings.Join(models.FetchRunStatuses, ", "))
+
+		return
+	}
+
+	var err error
+
+	q.Limit, err = parseLimit(r, defaultAdminListLimit, maxAdminListLimit)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	runs, err := a.fetchRuns.ListFetchRuns(r.Context(), q)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("list fetch runs: %w", err), "failed to fetch runs")
+
+		return
+	}
+
+	if runs == nil {
+		runs = []models.FetchRun{}
+	}
+
+	a.jsonResponse(w, http.StatusOK, FetchRunsResponse{Runs: runs})
+}
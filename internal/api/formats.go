@@ -0,0 +1,400 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/google/uuid"
+)
+
+// Output formats of the rate endpoints
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"  // one row per currency and date
+	formatXML  = "xml"  // the ECB's gesmes Cube envelope
+	formatSDMX = "sdmx" // SDMX-JSON data message
+)
+
+var formats = []string{formatJSON, formatCSV, formatXML, formatSDMX}
+
+// acceptedTypes maps the media types a client may ask for to a format
+var acceptedTypes = map[string]string{
+	"application/json":               formatJSON,
+	"application/*":                  formatJSON,
+	"*/*":                            formatJSON,
+	"text/csv":                       formatCSV,
+	"application/xml":                formatXML,
+	"text/xml":                       formatXML,
+	"application/vnd.sdmx.data+json": formatSDMX,
+}
+
+// contentTypes labels the responses of every format
+var contentTypes = map[string]string{
+	formatJSON: "application/json",
+	formatCSV:  "text/csv; charset=utf-8",
+	formatXML:  "application/xml; charset=utf-8",
+	formatSDMX: "application/vnd.sdmx.data+json; version=1.0.0; charset=utf-8",
+}
+
+var errNotAcceptable = errors.New("unsupported format, expected one of " + strings.Join(formats, ", "))
+
+// rateDocument is a response carrying rates, which every output format can encode
+type rateDocument interface {
+	rateSet() (base string, rates []models.ExchangeRate)
+}
+
+func (r LatestRatesResponse) rateSet() (string, []models.ExchangeRate) {
+	return quoteBase(r.Base), r.Rates
+}
+
+func (r HistoricalRatesResponse) rateSet() (string, []models.ExchangeRate) {
+	return quoteBase(r.Base), r.History
+}
+
+func (r AsOfRatesResponse) rateSet() (string, []models.ExchangeRate) {
+	return baseCurrency, r.Rates
+}
+
+// quoteBase returns the currency rates are quoted against, EUR unless the request rebased them
+func quoteBase(base string) string {
+	if base == "" {
+		return baseCurrency
+	}
+
+	return base
+}
+
+// negotiateFormat picks the output format: the format query parameter when given, otherwise the supported
+// media type the Accept header prefers. Requests without Accept and browsers get JSON.
+func negotiateFormat(r *http.Request) (string, error) {
+	if raw := r.URL.Query().Get("format"); raw != "" {
+		if !slices.Contains(formats, raw) {
+			return "", errNotAcceptable
+		}
+
+		return raw, nil
+	}
+
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, nil
+	}
+
+	var (
+		best    string
+		bestQ   float64
+		checked bool
+	)
+
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		checked = true
+
+		// Browsers rank XML above anything else they accept, they keep getting JSON as before content negotiation
+		if mediaType == "text/html" {
+			return formatJSON, nil
+		}
+
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+
+		// The first of equally preferred types wins
+		if format, ok := acceptedTypes[mediaType]; ok && q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+
+	// An unparsable header is ignored rather than turning the request down
+	if !checked {
+		return formatJSON, nil
+	}
+
+	if best == "" {
+		return "", errNotAcceptable
+	}
+
+	return best, nil
+}
+
+// outputFormat negotiates the format of a rate response, answering 400 for an unknown format parameter and 406
+// when nothing the client accepts is supported
+func (a *API) outputFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	format, err := negotiateFormat(r)
+	if err != nil {
+		status := http.StatusNotAcceptable
+		if r.URL.Query().Has("format") {
+			status = http.StatusBadRequest
+		}
+
+		a.errorResponse(w, status, fmt.Errorf("negotiate format: %w", err), err.Error())
+
+		return "", false
+	}
+
+	return format, true
+}
+
+// ratesResponse writes a rate response in the negotiated format
+func (a *API) ratesResponse(w http.ResponseWriter, format string, status int, doc rateDocument) {
+	if format == formatJSON {
+		a.jsonResponse(w, status, doc)
+
+		return
+	}
+
+	base, rates := doc.rateSet()
+
+	var (
+		body bytes.Buffer
+		err  error
+	)
+
+	switch format {
+	case formatCSV:
+		err = writeCSV(&body, base, rates)
+	case formatXML:
+		// The Cube format has no place for the base, its rates are always per euro
+		if base != baseCurrency {
+			a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("xml output rebased onto %s", base),
+				"the xml format only carries EUR based rates")
+
+			return
+		}
+
+		err = writeGesmes(&body, rates)
+	case formatSDMX:
+		err = writeSDMX(&body, base, rates, time.Now())
+	}
+
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("encode %s response: %w", format, err), "failed to encode rates")
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.WriteHeader(status)
+
+	if _, err := w.Write(body.Bytes()); err != nil {
+		a.logger.Error("write response failed", "err", err)
+	}
+}
+
+// writeCSV writes a header row and one row per currency and date
+func writeCSV(buf *bytes.Buffer, base string, rates []models.ExchangeRate) error {
+	cw := csv.NewWriter(buf)
+
+	if err := cw.Write([]string{"date", "currency", "base", "rate"}); err != nil {
+		return err
+	}
+
+	for _, rate := range rates {
+		if err := cw.Write([]string{rate.Date.Format(time.DateOnly), rate.Currency, base, rate.Rate.String()}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// gesmesEnvelope is the document the ECB publishes its reference rates in, as read by internal/fetcher
+type gesmesEnvelope struct {
+	XMLName   xml.Name    `xml:"gesmes:Envelope"`
+	GesmesNS  string      `xml:"xmlns:gesmes,attr"`
+	DefaultNS string      `xml:"xmlns,attr"`
+	Subject   string      `xml:"gesmes:subject"`
+	Sender    string      `xml:"gesmes:Sender>gesmes:name"`
+	Days      []gesmesDay `xml:"Cube>Cube"`
+}
+
+type gesmesDay struct {
+	Time  string       `xml:"time,attr"`
+	Rates []gesmesRate `xml:"Cube"`
+}
+
+type gesmesRate struct {
+	Currency string `xml:"currency,attr"`
+	Rate     string `xml:"rate,attr"`
+}
+
+// writeGesmes writes the rates grouped by day, newest day first like the ECB's feeds
+func writeGesmes(buf *bytes.Buffer, rates []models.ExchangeRate) error {
+	envelope := gesmesEnvelope{
+		GesmesNS:  "http://www.gesmes.org/xml/2002-08-01",
+		DefaultNS: "http://www.ecb.int/vocabulary/2002-08-01/eurofxref",
+		Subject:   "Reference rates",
+		Sender:    "Currency Exchange Rate Service",
+	}
+
+	days := make(map[string]int)
+	for _, rate := range rates {
+		date := rate.Date.Format(time.DateOnly)
+
+		i, ok := days[date]
+		if !ok {
+			i = len(envelope.Days)
+			days[date] = i
+			envelope.Days = append(envelope.Days, gesmesDay{Time: date})
+		}
+
+		envelope.Days[i].Rates = append(envelope.Days[i].Rates, gesmesRate{Currency: rate.Currency, Rate: rate.Rate.String()})
+	}
+
+	slices.SortStableFunc(envelope.Days, func(a, b gesmesDay) int {
+		return strings.Compare(b.Time, a.Time)
+	})
+
+	buf.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(buf)
+	encoder.Indent("", "\t")
+
+	if err := encoder.Encode(envelope); err != nil {
+		return err
+	}
+
+	buf.WriteString("\n")
+
+	return nil
+}
+
+// sdmxMessage is an SDMX-JSON data message laid out like the ECB's EXR dataflow: one series per currency keyed
+// by FREQ:CURRENCY:CURRENCY_DENOM:EXR_TYPE:EXR_SUFFIX, observations keyed by the index of their TIME_PERIOD
+type sdmxMessage struct {
+	Header    sdmxHeader    `json:"header"`
+	DataSets  []sdmxDataSet `json:"dataSets"`
+	Structure sdmxStructure `json:"structure"`
+}
+
+type sdmxHeader struct {
+	ID       string    `json:"id"`
+	Test     bool      `json:"test"`
+	Prepared time.Time `json:"prepared"`
+	Sender   sdmxValue `json:"sender"`
+}
+
+type sdmxDataSet struct {
+	Action string                `json:"action"`
+	Series map[string]sdmxSeries `json:"series"`
+}
+
+type sdmxSeries struct {
+	// Observations hold the rate as a JSON number, written from the decimal so no digit is lost
+	Observations map[string][]json.Number `json:"observations"`
+}
+
+type sdmxStructure struct {
+	Name       string         `json:"name"`
+	Dimensions sdmxDimensions `json:"dimensions"`
+}
+
+type sdmxDimensions struct {
+	Series      []sdmxDimension `json:"series"`
+	Observation []sdmxDimension `json:"observation"`
+}
+
+type sdmxDimension struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	KeyPosition int         `json:"keyPosition"`
+	Role        string      `json:"role,omitempty"`
+	Values      []sdmxValue `json:"values"`
+}
+
+type sdmxValue struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// writeSDMX writes the rates as daily spot rate series, one per currency
+func writeSDMX(buf *bytes.Buffer, base string, rates []models.ExchangeRate, prepared time.Time) error {
+	var currencies []string
+
+	currencyIndex := make(map[string]int)
+	periodIndex := make(map[string]int)
+
+	for _, rate := range rates {
+		if _, ok := currencyIndex[rate.Currency]; !ok {
+			currencyIndex[rate.Currency] = len(currencies)
+			currencies = append(currencies, rate.Currency)
+		}
+
+		periodIndex[rate.Date.Format(time.DateOnly)] = 0
+	}
+
+	periods := slices.Sorted(maps.Keys(periodIndex))
+
+	for i, period := range periods {
+		periodIndex[period] = i
+	}
+
+	series := make(map[string]sdmxSeries, len(currencies))
+	for _, rate := range rates {
+		key := "0:" + strconv.Itoa(currencyIndex[rate.Currency]) + ":0:0:0"
+		if _, ok := series[key]; !ok {
+			series[key] = sdmxSeries{Observations: make(map[string][]json.Number)}
+		}
+
+		period := strconv.Itoa(periodIndex[rate.Date.Format(time.DateOnly)])
+		series[key].Observations[period] = []json.Number{json.Number(rate.Rate.String())}
+	}
+
+	values := func(ids []string) []sdmxValue {
+		v := make([]sdmxValue, 0, len(ids))
+		for _, id := range ids {
+			v = append(v, sdmxValue{ID: id, Name: id})
+		}
+
+		return v
+	}
+
+	message := sdmxMessage{
+		Header: sdmxHeader{
+			ID:       uuid.NewString(),
+			Prepared: prepared.UTC().Truncate(time.Second),
+			Sender:   sdmxValue{ID: "CURRENCY_SERVICE"},
+		},
+		DataSets: []sdmxDataSet{{Action: "Replace", Series: series}},
+		Structure: sdmxStructure{
+			Name: "Exchange Rates",
+			Dimensions: sdmxDimensions{
+				Series: []sdmxDimension{
+					{ID: "FREQ", Name: "Frequency", KeyPosition: 0, Values: []sdmxValue{{ID: "D", Name: "Daily"}}},
+					{ID: "CURRENCY", Name: "Currency", KeyPosition: 1, Values: values(currencies)},
+					{ID: "CURRENCY_DENOM", Name: "Currency denominator", KeyPosition: 2, Values: values([]string{base})},
+					{ID: "EXR_TYPE", Name: "Exchange rate type", KeyPosition: 3, Values: []sdmxValue{{ID: "SP00", Name: "Spot"}}},
+					{ID: "EXR_SUFFIX", Name: "Series variation - EXR context", KeyPosition: 4, Values: []sdmxValue{{ID: "A", Name: "Average"}}},
+				},
+				Observation: []sdmxDimension{
+					{ID: "TIME_PERIOD", Name: "Time period or range", KeyPosition: 5, Role: "time", Values: values(periods)},
+				},
+			},
+		},
+	}
+
+	return json.NewEncoder(buf).Encode(message)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// QuarantineReader reads the feed items rejected by validation
+type QuarantineReader interface {
+	ListQuarantinedItems(ctx context.Context, limit int) ([]models.QuarantinedItem, error)
+}
+
+// WithQuarantineReader enables the quarantined feed items endpoint
+func WithQuarantineReader(reader QuarantineReader) Option {
+	return func(a *API) {
+		a.quarantine = reader
+	}
+}
+
+// QuarantineResponse represents the API response for quarantined feed items
+type QuarantineResponse struct {
+	Items []models.QuarantinedItem `json:"items"`
+}
+
+// QuarantineHandler lists the feed items rejected by validation, most recent first.
+// Supported query parameters: limit.
+func (a *API) QuarantineHandler(w http.ResponseWriter, r *http.Request) {
+	if a.quarantine == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("quarantine reader not configured"), "quarantine not available")
+
+		return
+	}
+
+	limit, err := parseLimit(r, defaultAdminListLimit, maxAdminListLimit)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	items, err := a.quarantine.ListQuarantinedItems(r.Context(), limit)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("list quarantined items: %w", err),
+			"failed to fetch quarantined items")
+
+		return
+	}
+
+	if items == nil {
+		items = []models.QuarantinedItem{}
+	}
+
+	a.jsonResponse(w, http.StatusOK, QuarantineResponse{Items: items})
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+var errBaseRateMissing = errors.New("base currency has no rate")
+
+// errInvalidRate marks a stored rate other rates cannot be divided by
+var errInvalidRate = errors.New("stored rate is not positive")
+
+// checkDivisor returns an error unless rate can be divided by
+func checkDivisor(currency string, rate decimal.Decimal, date time.Time) error {
+	if !rate.IsPositive() {
+		return fmt.Errorf("%w: %s rate %s on %s", errInvalidRate, currency, rate, date.UTC().Format(time.DateOnly))
+	}
+
+	return nil
+}
+
+// parseBase reads the optional base query parameter. An empty result means the stored EUR quotes are used as is.
+func parseBase(r *http.Request) (string, error) {
+	base := strings.ToUpper(r.URL.Query().Get("base"))
+
+	// ISO 4217
+	if base != "" && len(base) != 3 {
+		return "", errors.New("invalid base currency format")
+	}
+
+	return base, nil
+}
+
+// rebaseRates re-expresses EUR-quoted rates against base using base's own rate on the same date, as known at
+// knownAt when it is set. The base currency itself is dropped from the result.
+func (a *API) rebaseRates(ctx context.Context, base string, rates []models.ExchangeRate, knownAt time.Time) ([]models.ExchangeRate, error) {
+	baseRates, err := a.baseRatesByDate(ctx, base, rates, knownAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rebased := make([]models.ExchangeRate, 0, len(rates))
+	for _, rate := range rates {
+		if rate.Currency == base {
+			continue
+		}
+
+		baseRate, ok := baseRates[rate.Date.UTC().Format(time.DateOnly)]
+		if !ok {
+			return nil, fmt.Errorf("%w: no %s rate on %s", errBaseRateMissing, base, rate.Date.UTC().Format(time.DateOnly))
+		}
+
+		if err := checkDivisor(base, baseRate, rate.Date); err != nil {
+			return nil, err
+		}
+
+		rebased = append(rebased, models.ExchangeRate{
+			Currency: rate.Currency,
+			Rate:     a.roundingMode.round(rate.Rate.Div(baseRate), ratePrecision),
+			Date:     rate.Date,
+		})
+	}
+
+	return rebased, nil
+}
+
+// rebaseLatest rebases the latest rates and adds EUR as a synthetic row dated like base's latest quote
+func (a *API) rebaseLatest(ctx context.Context, base string, rates []models.ExchangeRate, knownAt time.Time) ([]models.ExchangeRate, error) {
+	idx := slices.IndexFunc(rates, func(rate models.ExchangeRate) bool {
+		return rate.Currency == base
+	})
+
+	if idx == -1 {
+		return nil, fmt.Errorf("%w: no %s rates stored", errBaseRateMissing, base)
+	}
+
+	eur, err := a.invertRates(rates[idx : idx+1])
+	if err != nil {
+		return nil, err
+	}
+
+	rebased, err := a.rebaseRates(ctx, base, rates, knownAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rebased = append(rebased, eur...)
+	slices.SortFunc(rebased, func(a, b models.ExchangeRate) int {
+		return strings.Compare(a.Currency, b.Currency)
+	})
+
+	return rebased, nil
+}
+
+// invertRates turns the EUR-quoted rates of base into synthetic EUR rates quoted in base
+func (a *API) invertRates(baseRates []models.ExchangeRate) ([]models.ExchangeRate, error) {
+	one := decimal.NewFromInt(1)
+
+	inverted := make([]models.ExchangeRate, 0, len(baseRates))
+	for _, rate := range baseRates {
+		if err := checkDivisor(rate.Currency, rate.Rate, rate.Date); err != nil {
+			return nil, err
+		}
+
+		inverted = append(inverted, models.ExchangeRate{
+			Currency: baseCurrency,
+			Rate:     a.roundingMode.round(one.Div(rate.Rate), ratePrecision),
+			Date:     rate.Date,
+		})
+	}
+
+	return inverted, nil
+}
+
+// baseRatesByDate loads the rates of base covering the dates spanned by rates, keyed by YYYY-MM-DD
+func (a *API) baseRatesByDate(ctx context.Context, base string, rates []models.ExchangeRate, knownAt time.Time) (map[string]decimal.Decimal, error) {
+	if len(rates) == 0 {
+		return nil, nil
+	}
+
+	from := slices.MinFunc(rates, func(a, b models.ExchangeRate) int { return a.Date.Compare(b.Date) }).Date
+	to := slices.MaxFunc(rates, func(a, b models.ExchangeRate) int { return a.Date.Compare(b.Date) }).Date
+
+	history, err := a.rateReader.GetHistoricalRatesRange(ctx, base, models.HistoryQuery{From: from, To: to, KnownAt: knownAt})
+	if err != nil {
+		return nil, fmt.Errorf("get %s rates: %w", base, err)
+	}
+
+	byDate := make(map[string]decimal.Decimal, len(history))
+	for _, rate := range history {
+		byDate[rate.Date.UTC().Format(time.DateOnly)] = rate.Rate
+	}
+
+	return byDate, nil
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/stream"
+)
+
+// RateStreamer feeds the rate event stream: live revisions through subscriptions, missed ones from the log
+type RateStreamer interface {
+	Subscribe() (*stream.Subscription, error)
+	LatestRevisionID(ctx context.Context) (int64, error)
+	RevisionsAfter(ctx context.Context, afterID int64, limit int) ([]models.RateRevision, error)
+}
+
+// WithRateStream enables the rate event stream, with a comment sent on idle connections every heartbeat
+func WithRateStream(streamer RateStreamer, heartbeat time.Duration) Option {
+	return func(a *API) {
+		a.stream = streamer
+		a.heartbeat = heartbeat
+	}
+}
+
+const (
+	// streamWriteTimeout bounds every write to a stream, which replaces the server's write timeout
+	streamWriteTimeout = 15 * time.Second
+
+	// replayPage is the number of missed revisions read at once when a client resumes
+	replayPage = 500
+
+	// rateEvent is the event name of a stored rate
+	rateEvent = "rate"
+)
+
+// RateStreamHandler streams the rates stored from now on as server-sent events, one rate event per revision
+// with the revision id as event id. A client sending Last-Event-ID first gets the revisions stored after that
+// event. Supported query parameters: currency, one or more comma-separated currencies.
+func (a *API) RateStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if a.stream == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("rate stream not configured"), "rate stream not available")
+
+		return
+	}
+
+	var currencies []string
+	if raw := r.URL.Query().Get("currency"); raw != "" {
+		for currency := range strings.SplitSeq(raw, ",") {
+			currency = strings.ToUpper(strings.TrimSpace(currency))
+			if len(currency) != 3 {
+				a.errorResponse(w, http.StatusBadRequest, errors.New("invalid currency format: must be 3 characters"), "invalid currency format")
+
+				return
+			}
+
+			currencies = append(currencies, currency)
+		}
+	}
+
+	var (
+		lastID int64
+		resume bool
+	)
+
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(raw, 10, 64); err != nil || lastID < 0 {
+			a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("parse Last-Event-ID %q", raw), "invalid Last-Event-ID, expected an event id")
+
+			return
+		}
+
+		resume = true
+	}
+
+	// The server's WriteTimeout would cut the stream off, it is lifted for this connection and every write sets its
+	// own deadline instead
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("clear write deadline: %w", err), "streaming not supported")
+
+		return
+	}
+
+	// Subscribing before reading the log leaves no gap between replayed and live revisions, the overlap is
+	// skipped by id
+	sub, err := a.stream.Subscribe()
+	if err != nil {
+		a.errorResponse(w, http.StatusServiceUnavailable, fmt.Errorf("subscribe to rate stream: %w", err), "rate stream unavailable")
+
+		return
+	}
+	defer sub.Close()
+
+	if !resume {
+		if lastID, err = a.stream.LatestRevisionID(r.Context()); err != nil {
+			a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("read latest revision: %w", err), "failed to open rate stream")
+
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keeps reverse proxies from buffering events
+	w.WriteHeader(http.StatusOK)
+
+	events := &eventWriter{w: w, rc: rc, currencies: currencies, lastID: lastID}
+
+	if err := events.flush(); err != nil {
+		return
+	}
+
+	for resume {
+		revisions, err := a.stream.RevisionsAfter(r.Context(), events.lastID, replayPage)
+		if err != nil {
+			if r.Context().Err() == nil {
+				a.logger.Error("failed to replay rate revisions", slog.Int64("after_id", events.lastID), slog.Any("error", err))
+			}
+
+			return
+		}
+
+		if err := events.send(revisions); err != nil {
+			return
+		}
+
+		resume = len(revisions) == replayPage
+	}
+
+	heartbeat := time.NewTicker(a.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := events.comment("heartbeat"); err != nil {
+				return
+			}
+		case revisions, ok := <-sub.C:
+			// Closed on shutdown or when the client fell behind, it resumes from the last event it got
+			if !ok {
+				return
+			}
+
+			if err := events.send(revisions); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// eventWriter writes revisions as server-sent events
+type eventWriter struct {
+	w          http.ResponseWriter
+	rc         *http.ResponseController
+	currencies []string // empty for every currency
+	lastID     int64    // revisions up to this id have been handled
+}
+
+// send writes the revisions after lastID of the requested currencies
+func (e *eventWriter) send(revisions []models.RateRevision) error {
+	var b strings.Builder
+
+	for _, rev := range revisions {
+		if rev.ID <= e.lastID {
+			continue
+		}
+
+		e.lastID = rev.ID
+
+		if len(e.currencies) > 0 && !slices.Contains(e.currencies, rev.Currency) {
+			continue
+		}
+
+		data, err := json.Marshal(rev)
+		if err != nil {
+			return fmt.Errorf("encode rate event: %w", err)
+		}
+
+		fmt.Fprintf(&b, "id: %d\nevent: %s\ndata: %s\n\n", rev.ID, rateEvent, data)
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+
+	return e.write(b.String())
+}
+
+// comment writes a comment line, which clients ignore but which keeps proxies from closing an idle connection
+func (e *eventWriter) comment(text string) error {
+	return e.write(": " + text + "\n\n")
+}
+
+func (e *eventWriter) write(s string) error {
+	if err := e.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := e.w.Write([]byte(s)); err != nil {
+		return err
+	}
+
+	return e.flush()
+}
+
+func (e *eventWriter) flush() error {
+	return e.rc.Flush()
+}
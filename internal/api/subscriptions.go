@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/webhook"
+	"github.com/shopspring/decimal"
+)
+
+// SubscriptionStore keeps webhook subscriptions and their delivery log
+type SubscriptionStore interface {
+	CreateSubscription(ctx context.Context, sub models.Subscription) (models.Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]models.Subscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, q models.DeliveryQuery) ([]models.WebhookDelivery, error)
+}
+
+// TargetChecker rejects webhook URLs the service must not post to
+type TargetChecker interface {
+	CheckTarget(ctx context.Context, target *url.URL) error
+}
+
+// WithSubscriptionStore enables the webhook subscription endpoints, accepting the URLs targets allows
+func WithSubscriptionStore(store SubscriptionStore, targets TargetChecker) Option {
+	return func(a *API) {
+		a.subscriptions = store
+		a.targets = targets
+	}
+}
+
+// SubscriptionsResponse represents the API response for webhook subscriptions
+type SubscriptionsResponse struct {
+	Subscriptions []models.Subscription `json:"subscriptions"`
+}
+
+// DeliveriesResponse represents the API response for a subscription's delivery log
+type DeliveriesResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+}
+
+// subscriptionRequest is the body of a subscription to create
+type subscriptionRequest struct {
+	URL        string              `json:"url"`
+	Currencies []string            `json:"currencies"`
+	Condition  string              `json:"condition"`
+	Threshold  decimal.NullDecimal `json:"threshold"`
+	Secret     string              `json:"secret"`
+}
+
+// maxSubscriptionBody bounds the size of a subscription request
+const maxSubscriptionBody = 16 << 10
+
+// minSecretLength is the shortest signing secret a subscriber may choose
+const minSecretLength = 16
+
+// CreateSubscriptionHandler registers a webhook. The response carries the signing secret, generated unless the
+// request gives one; it is not shown again.
+func (a *API) CreateSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if a.subscriptions == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("subscription store not configured"), "subscriptions not available")
+
+		return
+	}
+
+	var req subscriptionRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubscriptionBody))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&req); err != nil {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("decode subscription: %w", err), "invalid request body")
+
+		return
+	}
+
+	sub, err := newSubscription(r.Context(), req, a.targets)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	created, err := a.subscriptions.CreateSubscription(r.Context(), sub)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("create subscription: %w", err), "failed to create subscription")
+
+		return
+	}
+
+	a.jsonResponse(w, http.StatusCreated, created)
+}
+
+// newSubscription validates a subscription request
+func newSubscription(ctx context.Context, req subscriptionRequest, targets TargetChecker) (models.Subscription, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return models.Subscription{}, errors.New("invalid url, expected an absolute http or https URL")
+	}
+
+	// Deliveries are posted from inside the service's network, the URL must not point back into it
+	if err := targets.CheckTarget(ctx, target); err != nil {
+		if errors.Is(err, webhook.ErrPrivateTarget) {
+			return models.Subscription{}, errors.New("invalid url, the host must resolve to public addresses")
+		}
+
+		return models.Subscription{}, errors.New("invalid url, the host could not be resolved")
+	}
+
+	currencies := make([]string, 0, len(req.Currencies))
+	for _, currency := range req.Currencies {
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if len(currency) != 3 {
+			return models.Subscription{}, errors.New("invalid currency format")
+		}
+
+		if !slices.Contains(currencies, currency) {
+			currencies = append(currencies, currency)
+		}
+	}
+
+	if !slices.Contains(models.Conditions, req.Condition) {
+		return models.Subscription{}, errors.New("invalid condition, expected one of " + strings.Join(models.Conditions, ", "))
+	}
+
+	switch {
+	case req.Condition == models.ConditionPublished && req.Threshold.Valid:
+		return models.Subscription{}, errors.New("threshold is only used by the change and crossing conditions")
+	case req.Condition != models.ConditionPublished && (!req.Threshold.Valid || !req.Threshold.Decimal.IsPositive()):
+		return models.Subscription{}, fmt.Errorf("the %s condition needs a positive threshold", req.Condition)
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = webhook.NewSecret()
+	} else if len(secret) < minSecretLength {
+		return models.Subscription{}, fmt.Errorf("secret must be at least %d characters", minSecretLength)
+	}
+
+	return models.Subscription{
+		URL:        target.String(),
+		Currencies: currencies,
+		Condition:  req.Condition,
+		Threshold:  req.Threshold,
+		Secret:     secret,
+	}, nil
+}
+
+// SubscriptionsHandler lists the webhook subscriptions without their secrets
+func (a *API) SubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if a.subscriptions == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("subscription store not configured"), "subscriptions not available")
+
+		return
+	}
+
+	subs, err := a.subscriptions.ListSubscriptions(r.Context())
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("list subscriptions: %w", err), "failed to fetch subscriptions")
+
+		return
+	}
+
+	if subs == nil {
+		subs = []models.Subscription{}
+	}
+
+	a.jsonResponse(w, http.StatusOK, SubscriptionsResponse{Subscriptions: subs})
+}
+
+// DeleteSubscriptionHandler removes a webhook subscription and its delivery log
+func (a *API) DeleteSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	if a.subscriptions == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("subscription store not configured"), "subscriptions not available")
+
+		return
+	}
+
+	id, ok := a.subscriptionID(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.subscriptions.DeleteSubscription(r.Context(), id)
+
+	switch {
+	case errors.Is(err, models.ErrSubscriptionNotFound):
+		a.errorResponse(w, http.StatusNotFound, err, "subscription not found")
+	case err != nil:
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("delete subscription %d: %w", id, err), "failed to delete subscription")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeliveriesHandler lists the delivery log of a subscription, most recent first.
+// Supported query parameters: status (pending, delivered or failed) and limit.
+func (a *API) DeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if a.subscriptions == nil {
+		a.errorResponse(w, http.StatusNotFound, errors.New("subscription store not configured"), "subscriptions not available")
+
+		return
+	}
+
+	id, ok := a.subscriptionID(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	q := models.DeliveryQuery{SubscriptionID: id, Status: params.Get("status")}
+
+	if q.Status != "" && !slices.Contains(models.DeliveryStatuses, q.Status) {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("unknown status %q", q.Status),
+			"invalid status, expected one of "+strings.Join(models.DeliveryStatuses, ", "))
+
+		return
+	}
+
+	var err error
+
+	q.Limit, err = parseLimit(r, defaultAdminListLimit, maxAdminListLimit)
+	if err != nil {
+		a.errorResponse(w, http.StatusBadRequest, err, err.Error())
+
+		return
+	}
+
+	deliveries, err := a.subscriptions.ListDeliveries(r.Context(), q)
+	if err != nil {
+		a.errorResponse(w, http.StatusInternalServerError, fmt.Errorf("list webhook deliveries: %w", err), "failed to fetch deliveries")
+
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	a.jsonResponse(w, http.StatusOK, DeliveriesResponse{Deliveries: deliveries})
+}
+
+// subscriptionID reads the {id} path parameter, answering 400 when it is not a positive integer
+func (a *API) subscriptionID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		a.errorResponse(w, http.StatusBadRequest, fmt.Errorf("parse subscription id %q", r.PathValue("id")), "invalid subscription id")
+
+		return 0, false
+	}
+
+	return id, true
+}
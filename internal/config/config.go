@@ -1,18 +1,35 @@
 package config
 
 import (
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
+	Database   DatabaseConfig
+	Server     ServerConfig
+	Conversion ConversionConfig
+	Scheduler  SchedulerConfig
+	Fetcher    FetcherConfig
+	Anomaly    AnomalyConfig
+	Webhook    WebhookConfig
+	Stream     StreamConfig
+	Health     HealthConfig
 }
 
+// Supported database drivers
+const (
+	DriverMariaDB  = "mariadb"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type DatabaseConfig struct {
+	Driver       string
 	Host         string
 	Port         int
 	User         string
@@ -21,39 +38,173 @@ type DatabaseConfig struct {
 	MaxOpenConns int
 	MaxIdleConns int
 	ConnLifetime time.Duration
+	AutoMigrate  bool
+	SSLMode      string
+
+	// Path is the database file of the sqlite driver
+	Path string
 }
 
 type ServerConfig struct {
 	Port int
 }
 
+type ConversionConfig struct {
+	RoundingMode string
+	Precision    int32
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration
+	Staleness    time.Duration
+}
+
+type FetcherConfig struct {
+	Sources []string
+
+	// Retries of a single feed download
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+
+	// Circuit breaker guarding every source
+	BreakerThreshold   int
+	BreakerOpenTimeout time.Duration
+
+	// Reconciliation of every selected source, off while the policy is empty
+	ReconcilePolicy    string
+	ReconcileTolerance string
+}
+
+// AnomalyConfig configures the checks fetched rates go through before they are stored
+type AnomalyConfig struct {
+	Enabled    bool
+	Window     int
+	Thresholds string // per-currency overrides as JSON
+}
+
+// WebhookConfig configures how webhook deliveries are sent and retried
+type WebhookConfig struct {
+	Timeout        time.Duration
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	PollInterval   time.Duration
+
+	// AllowPrivateTargets lets subscriptions reach addresses inside the service's network
+	AllowPrivateTargets bool
+}
+
+// StreamConfig configures the server-sent event stream of stored rates
+type StreamConfig struct {
+	PollInterval time.Duration
+	Heartbeat    time.Duration
+}
+
+type SchedulerConfig struct {
+	Cron        string
+	TimeZone    string
+	Jitter      time.Duration
+	MaxAttempts int
+	RetryDelay  time.Duration
+	Currencies  []string
+
+	// MetricsPort is where the schedule command exports its metrics, 0 disables the listener
+	MetricsPort int
+}
+
 func Load(logger *slog.Logger) (*Config, error) {
 	viper.SetEnvPrefix("CURRENCY_SERVICE")
 	viper.AutomaticEnv()
 
 	// Database defaults
+	viper.SetDefault("DB_DRIVER", DriverMariaDB)
 	viper.SetDefault("DB_HOST", "localhost")
-	viper.SetDefault("DB_PORT", 3306)
+	viper.SetDefault("DB_PORT", defaultPort(viper.GetString("DB_DRIVER")))
 	viper.SetDefault("DB_USER", "currency")
 	viper.SetDefault("DB_PASSWORD", "currency")
 	viper.SetDefault("DB_NAME", "currency_service")
 	viper.SetDefault("DB_MAX_OPEN_CONNS", 25)
 	viper.SetDefault("DB_MAX_IDLE_CONNS", 5)
 	viper.SetDefault("DB_CONN_LIFETIME", "5m")
+	viper.SetDefault("DB_AUTO_MIGRATE", false)
+	viper.SetDefault("DB_SSLMODE", "disable")
+	viper.SetDefault("DB_PATH", "rates.db")
 
 	// Server defaults
 	viper.SetDefault("SERVER_PORT", 8080)
 
-	connLifetime, err := time.ParseDuration(viper.GetString("DB_CONN_LIFETIME"))
-	if err != nil {
-		logger.Warn("invalid DB_CONN_LIFETIME, using default",
-			slog.String("value", viper.GetString("DB_CONN_LIFETIME")),
-			slog.Any("error", err))
-		connLifetime = 5 * time.Minute
-	}
+	// Conversion defaults
+	viper.SetDefault("CONVERSION_ROUNDING_MODE", "half_even")
+	viper.SetDefault("CONVERSION_PRECISION", 2)
+
+	// Fetcher defaults: primary source first, fallbacks after it
+	viper.SetDefault("SOURCES", "banklv")
+
+	// Retry defaults: ride out short upstream blips within the fetch timeout
+	viper.SetDefault("FETCH_RETRY_MAX_ATTEMPTS", 3)
+	viper.SetDefault("FETCH_RETRY_BASE_DELAY", "500ms")
+	viper.SetDefault("FETCH_RETRY_MAX_DELAY", "5s")
+	viper.SetDefault("FETCH_BREAKER_THRESHOLD", 3)
+	viper.SetDefault("FETCH_BREAKER_OPEN_TIMEOUT", "5m")
+
+	// Reconciliation defaults: off, and when on sources may differ by 0.01 %
+	viper.SetDefault("FETCH_RECONCILE_POLICY", "")
+	viper.SetDefault("FETCH_RECONCILE_TOLERANCE", "0.0001")
+
+	// Anomaly check defaults: a month and a half of business days of history
+	viper.SetDefault("ANOMALY_CHECKS", true)
+	viper.SetDefault("ANOMALY_WINDOW", 30)
+	viper.SetDefault("ANOMALY_THRESHOLDS", "")
+
+	// Webhook defaults: eight attempts spread over about an hour
+	viper.SetDefault("WEBHOOK_TIMEOUT", "5s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOK_RETRY_BASE_DELAY", "30s")
+	viper.SetDefault("WEBHOOK_RETRY_MAX_DELAY", "30m")
+	viper.SetDefault("WEBHOOK_POLL_INTERVAL", "15s")
+	viper.SetDefault("WEBHOOK_ALLOW_PRIVATE_TARGETS", false)
+
+	// Stream defaults: rates reach clients within seconds, idle connections stay below common proxy timeouts
+	viper.SetDefault("STREAM_POLL_INTERVAL", "2s")
+	viper.SetDefault("STREAM_HEARTBEAT", "15s")
+
+	// Readiness defaults: a week covers weekends and the longest TARGET holiday closures
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("HEALTH_STALENESS", "168h")
+
+	// Scheduler defaults: shortly after the ECB publishes its reference rates around 16:00 CET
+	viper.SetDefault("SCHEDULE_CRON", "15 16 * * MON-FRI")
+	viper.SetDefault("SCHEDULE_TIMEZONE", "CET")
+	viper.SetDefault("SCHEDULE_JITTER", "5m")
+	viper.SetDefault("SCHEDULE_MAX_ATTEMPTS", 3)
+	viper.SetDefault("SCHEDULE_RETRY_DELAY", "1m")
+	viper.SetDefault("SCHEDULE_CURRENCIES", "USD,GBP,JPY")
+	viper.SetDefault("SCHEDULE_METRICS_PORT", 9090)
+
+	connLifetime := durationOrDefault(logger, "DB_CONN_LIFETIME", 5*time.Minute)
+
+	jitter := durationOrDefault(logger, "SCHEDULE_JITTER", 5*time.Minute)
+	retryDelay := durationOrDefault(logger, "SCHEDULE_RETRY_DELAY", time.Minute)
+
+	retryBaseDelay := durationOrDefault(logger, "FETCH_RETRY_BASE_DELAY", 500*time.Millisecond)
+	retryMaxDelay := durationOrDefault(logger, "FETCH_RETRY_MAX_DELAY", 5*time.Second)
+	breakerOpenTimeout := durationOrDefault(logger, "FETCH_BREAKER_OPEN_TIMEOUT", 5*time.Minute)
+
+	webhookTimeout := durationOrDefault(logger, "WEBHOOK_TIMEOUT", 5*time.Second)
+	webhookRetryBaseDelay := durationOrDefault(logger, "WEBHOOK_RETRY_BASE_DELAY", 30*time.Second)
+	webhookRetryMaxDelay := durationOrDefault(logger, "WEBHOOK_RETRY_MAX_DELAY", 30*time.Minute)
+	webhookPollInterval := durationOrDefault(logger, "WEBHOOK_POLL_INTERVAL", 15*time.Second)
+
+	streamPollInterval := durationOrDefault(logger, "STREAM_POLL_INTERVAL", 2*time.Second)
+	streamHeartbeat := durationOrDefault(logger, "STREAM_HEARTBEAT", 15*time.Second)
+
+	checkTimeout := durationOrDefault(logger, "HEALTH_CHECK_TIMEOUT", 2*time.Second)
+	staleness := durationOrDefault(logger, "HEALTH_STALENESS", 168*time.Hour)
 
 	cfg := &Config{
 		Database: DatabaseConfig{
+			Driver:       viper.GetString("DB_DRIVER"),
 			Host:         viper.GetString("DB_HOST"),
 			Port:         viper.GetInt("DB_PORT"),
 			User:         viper.GetString("DB_USER"),
@@ -62,18 +213,110 @@ func Load(logger *slog.Logger) (*Config, error) {
 			MaxOpenConns: viper.GetInt("DB_MAX_OPEN_CONNS"),
 			MaxIdleConns: viper.GetInt("DB_MAX_IDLE_CONNS"),
 			ConnLifetime: connLifetime,
+			AutoMigrate:  viper.GetBool("DB_AUTO_MIGRATE"),
+			SSLMode:      viper.GetString("DB_SSLMODE"),
+			Path:         viper.GetString("DB_PATH"),
 		},
 		Server: ServerConfig{
 			Port: viper.GetInt("SERVER_PORT"),
 		},
+		Conversion: ConversionConfig{
+			RoundingMode: viper.GetString("CONVERSION_ROUNDING_MODE"),
+			Precision:    viper.GetInt32("CONVERSION_PRECISION"),
+		},
+		Scheduler: SchedulerConfig{
+			Cron:        viper.GetString("SCHEDULE_CRON"),
+			TimeZone:    viper.GetString("SCHEDULE_TIMEZONE"),
+			Jitter:      jitter,
+			MaxAttempts: viper.GetInt("SCHEDULE_MAX_ATTEMPTS"),
+			RetryDelay:  retryDelay,
+			Currencies:  commaList("SCHEDULE_CURRENCIES"),
+			MetricsPort: viper.GetInt("SCHEDULE_METRICS_PORT"),
+		},
+		Fetcher: FetcherConfig{
+			Sources:            commaList("SOURCES"),
+			RetryMaxAttempts:   viper.GetInt("FETCH_RETRY_MAX_ATTEMPTS"),
+			RetryBaseDelay:     retryBaseDelay,
+			RetryMaxDelay:      retryMaxDelay,
+			BreakerThreshold:   viper.GetInt("FETCH_BREAKER_THRESHOLD"),
+			BreakerOpenTimeout: breakerOpenTimeout,
+			ReconcilePolicy:    viper.GetString("FETCH_RECONCILE_POLICY"),
+			ReconcileTolerance: viper.GetString("FETCH_RECONCILE_TOLERANCE"),
+		},
+		Anomaly: AnomalyConfig{
+			Enabled:    viper.GetBool("ANOMALY_CHECKS"),
+			Window:     viper.GetInt("ANOMALY_WINDOW"),
+			Thresholds: viper.GetString("ANOMALY_THRESHOLDS"),
+		},
+		Webhook: WebhookConfig{
+			Timeout:        webhookTimeout,
+			MaxAttempts:    viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+			RetryBaseDelay: webhookRetryBaseDelay,
+			RetryMaxDelay:  webhookRetryMaxDelay,
+			PollInterval:   webhookPollInterval,
+
+			AllowPrivateTargets: viper.GetBool("WEBHOOK_ALLOW_PRIVATE_TARGETS"),
+		},
+		Stream: StreamConfig{
+			PollInterval: streamPollInterval,
+			Heartbeat:    streamHeartbeat,
+		},
+		Health: HealthConfig{
+			CheckTimeout: checkTimeout,
+			Staleness:    staleness,
+		},
+	}
+
+	// A database URL takes precedence over the individual connection settings
+	if raw := viper.GetString("DB_URL"); raw != "" {
+		if err := cfg.Database.ApplyURL(raw); err != nil {
+			return nil, fmt.Errorf("CURRENCY_SERVICE_DB_URL: %w", err)
+		}
 	}
 
 	logger.Debug("configuration loaded",
+		slog.String("db_driver", cfg.Database.Driver),
 		slog.String("db_host", cfg.Database.Host),
 		slog.Int("db_port", cfg.Database.Port),
 		slog.String("db_name", cfg.Database.Name),
 		slog.Int("server_port", cfg.Server.Port),
+		slog.String("conversion_rounding_mode", cfg.Conversion.RoundingMode),
 	)
 
 	return cfg, nil
 }
+
+// defaultPort returns the standard port of the driver's database server
+func defaultPort(driver string) int {
+	if driver == DriverPostgres {
+		return 5432
+	}
+
+	return 3306
+}
+
+// durationOrDefault parses a duration setting, falling back to def when it is malformed
+func durationOrDefault(logger *slog.Logger, key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(viper.GetString(key))
+	if err != nil {
+		logger.Warn("invalid "+key+", using default",
+			slog.String("value", viper.GetString(key)),
+			slog.Any("error", err))
+
+		return def
+	}
+
+	return d
+}
+
+// commaList reads a comma-separated setting such as "USD,GBP,JPY"
+func commaList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(viper.GetString(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
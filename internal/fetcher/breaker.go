@@ -0,0 +1,158 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitOpenError is returned instead of calling a source whose breaker is open
+type CircuitOpenError struct {
+	Source string
+	Until  time.Time
+	now    time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s for source %s, next attempt in %s", ErrCircuitOpen, e.Source, e.RetryAfter().Round(time.Second))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// RetryAfter returns how long the breaker stays open, calling the source earlier is pointless
+func (e *CircuitOpenError) RetryAfter() time.Duration {
+	return max(e.Until.Sub(e.now), 0)
+}
+
+// BreakerConfig configures the circuit breaker of every source. A threshold below 1 disables the breaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed fetches that opens the circuit
+	FailureThreshold int
+
+	// OpenTimeout is how long an open circuit rejects fetches before letting a single probe through
+	OpenTimeout time.Duration
+}
+
+// Breaker stops calling a source after FailureThreshold consecutive failures. Once OpenTimeout has passed it
+// lets one probe through: a success closes the circuit again, a failure keeps it open for another OpenTimeout.
+type Breaker struct {
+	source string
+	cfg    BreakerConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(source string, cfg BreakerConfig) *Breaker {
+	return &Breaker{
+		source: source,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Allow reports whether the source may be called, returning a *CircuitOpenError when it may not.
+// Every allowed call must be followed by Record or Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state(b.now()) {
+	case breakerClosed:
+		return nil
+	case breakerHalfOpen:
+		if !b.probing {
+			b.probing = true
+
+			return nil
+		}
+	}
+
+	return b.openError()
+}
+
+// Open returns a *CircuitOpenError while the circuit is open and nil otherwise, without taking a probe slot
+func (b *Breaker) Open() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state(b.now()) != breakerOpen {
+		return nil
+	}
+
+	return b.openError()
+}
+
+// Record counts the outcome of an allowed call
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err == nil {
+		b.failures = 0
+		b.openedAt = time.Time{}
+
+		return
+	}
+
+	b.failures++
+
+	if b.enabled() && b.failures >= b.cfg.FailureThreshold {
+		b.openedAt = b.now()
+	}
+}
+
+// Release ends an allowed call without counting it, for calls abandoned by the caller
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *Breaker) enabled() bool {
+	return b.cfg.FailureThreshold > 0
+}
+
+// state must be called with mu held
+func (b *Breaker) state(now time.Time) string {
+	if !b.enabled() || b.openedAt.IsZero() {
+		return breakerClosed
+	}
+
+	if now.Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+		return breakerOpen
+	}
+
+	return breakerHalfOpen
+}
+
+// openError must be called with mu held. A half-open breaker whose probe is in flight rejects calls
+// until the probe has finished, those callers are told to retry after another OpenTimeout.
+func (b *Breaker) openError() *CircuitOpenError {
+	now := b.now()
+
+	until := b.openedAt.Add(b.cfg.OpenTimeout)
+	if !until.After(now) {
+		until = now.Add(b.cfg.OpenTimeout)
+	}
+
+	return &CircuitOpenError{Source: b.source, Until: until, now: now}
+}
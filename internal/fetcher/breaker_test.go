@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 2, 6, 16, 15, 0, 0, time.UTC)
+	failure := errors.New("unexpected status code: 503")
+
+	breaker := NewBreaker(SourceBankLatvia, BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+	breaker.now = func() time.Time { return now }
+
+	// Closed: failures below the threshold keep the circuit closed
+	require.NoError(t, breaker.Allow())
+	breaker.Record(failure)
+	require.NoError(t, breaker.Open())
+
+	// Open: the threshold is reached and calls are rejected until the timeout passes
+	require.NoError(t, breaker.Allow())
+	breaker.Record(failure)
+
+	err := breaker.Allow()
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.EqualError(t, err, "circuit open for source banklv, next attempt in 1m0s")
+	require.ErrorIs(t, breaker.Open(), ErrCircuitOpen)
+
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	require.Equal(t, time.Minute, openErr.RetryAfter())
+
+	// Half-open: a single probe is let through, a failed probe opens the circuit again
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.Open())
+	require.NoError(t, breaker.Allow())
+	require.ErrorIs(t, breaker.Allow(), ErrCircuitOpen, "only one probe at a time")
+	breaker.Record(failure)
+	require.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+	// A released probe leaves the circuit half-open
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.Allow())
+	breaker.Release()
+
+	// A successful probe closes the circuit
+	require.NoError(t, breaker.Allow())
+	breaker.Record(nil)
+	require.NoError(t, breaker.Allow())
+	breaker.Record(failure)
+	require.NoError(t, breaker.Allow(), "the failure count starts over after a success")
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	t.Parallel()
+
+	breaker := NewBreaker(SourceECB, BreakerConfig{})
+
+	for range 10 {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(errors.New("unexpected status code: 503"))
+	}
+}
@@ -0,0 +1,91 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotModified is returned by getXML when the server answers a conditional request with 304 Not Modified
+var ErrNotModified = errors.New("not modified")
+
+// validators are the cache validators a server sent along with a document
+type validators struct {
+	ETag         string
+	LastModified string
+}
+
+// feedCache keeps the last decoded copy of a feed. The copy is served without asking the server while the
+// feed's TTL lasts, after that it is revalidated with a conditional request and only downloaded and decoded
+// again when it changed.
+type feedCache[T any] struct {
+	now func() time.Time
+
+	mu         sync.Mutex
+	valid      bool
+	value      T
+	validators validators
+	fetchedAt  time.Time
+	ttl        time.Duration
+}
+
+func newFeedCache[T any]() *feedCache[T] {
+	return &feedCache[T]{now: time.Now}
+}
+
+// download fetches the feed, conditionally on cached when it is not empty. It returns the decoded feed,
+// its validators and its TTL, or ErrNotModified.
+type download[T any] func(ctx context.Context, cached validators) (T, validators, time.Duration, error)
+
+// get returns the cached feed while it is fresh and downloads it otherwise. The lock is not held during the
+// download, concurrent downloads are coalesced by the fetchers already.
+func (c *feedCache[T]) get(ctx context.Context, fetch download[T]) (value T, fresh bool, err error) {
+	c.mu.Lock()
+	now := c.now()
+
+	if c.valid && now.Before(c.fetchedAt.Add(c.ttl)) {
+		value = c.value
+		c.mu.Unlock()
+
+		return value, false, nil
+	}
+
+	var cached validators
+	if c.valid {
+		cached = c.validators
+	}
+	c.mu.Unlock()
+
+	value, received, ttl, err := fetch(ctx, cached)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if errors.Is(err, ErrNotModified) {
+		if !c.valid {
+			var zero T
+
+			return zero, false, fmt.Errorf("unexpected 304 response to an unconditional request: %w", err)
+		}
+
+		c.fetchedAt = now
+
+		return c.value, false, nil
+	}
+
+	if err != nil {
+		var zero T
+
+		return zero, false, err
+	}
+
+	c.valid = true
+	c.value = value
+	c.validators = received
+	c.fetchedAt = now
+	c.ttl = ttl
+
+	return value, true, nil
+}
@@ -0,0 +1,131 @@
+package fetcher
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedCache(t *testing.T) {
+	t.Parallel()
+
+	const etag = `"feed-v1"`
+
+	var (
+		mu       sync.Mutex
+		requests []http.Header
+		modified atomic.Bool
+	)
+
+	modified.Store(true)
+
+	seen := func() []http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return slices.Clone(requests)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Header.Clone())
+		mu.Unlock()
+
+		if !modified.Load() && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Fri, 06 Feb 2026 18:35:34 GMT")
+		_, _ = fmt.Fprint(w, mockRSS)
+	}))
+	defer ts.Close()
+
+	now := time.Date(2026, 2, 6, 16, 15, 0, 0, time.UTC)
+
+	fetcher := NewBankLatviaFetcher(slog.Default(), ts.Client(), ts.URL)
+	fetcher.cache.now = func() time.Time { return now }
+
+	fetchUSD := func() {
+		t.Helper()
+
+		rates, err := fetcher.GetCurrencyRates(t.Context(), "USD")
+		require.NoError(t, err)
+		require.Len(t, rates, 2)
+	}
+
+	// The first fetch downloads the feed unconditionally
+	fetchUSD()
+	require.Len(t, seen(), 1)
+	require.Empty(t, seen()[0].Get("If-None-Match"))
+	require.Empty(t, seen()[0].Get("If-Modified-Since"))
+
+	// Within the <ttl>5</ttl> the feed advertises, the server is not asked at all
+	now = now.Add(4 * time.Minute)
+	fetchUSD()
+	require.Len(t, seen(), 1)
+
+	// After the TTL the feed is revalidated, a 304 serves the cached items
+	modified.Store(false)
+	now = now.Add(2 * time.Minute)
+	fetchUSD()
+	require.Len(t, seen(), 2)
+	require.Equal(t, etag, seen()[1].Get("If-None-Match"))
+	require.Equal(t, "Fri, 06 Feb 2026 18:35:34 GMT", seen()[1].Get("If-Modified-Since"))
+
+	// A 304 restarts the TTL
+	now = now.Add(4 * time.Minute)
+	fetchUSD()
+	require.Len(t, seen(), 2)
+
+	// A changed feed is downloaded again
+	modified.Store(true)
+	now = now.Add(2 * time.Minute)
+	fetchUSD()
+	require.Len(t, seen(), 3)
+}
+
+func TestFeedCacheKeepsCopyOnError(t *testing.T) {
+	t.Parallel()
+
+	var failing atomic.Bool
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = fmt.Fprint(w, mockECB)
+	}))
+	defer ts.Close()
+
+	fetcher := NewECBFetcher(slog.Default(), ts.Client(), SourceECB, ts.URL)
+
+	_, err := fetcher.GetAllRates(t.Context())
+	require.NoError(t, err)
+
+	// The ECB feed has no TTL, so every fetch asks the server and a failure is reported rather than hidden
+	failing.Store(true)
+
+	_, err = fetcher.GetAllRates(t.Context())
+	require.EqualError(t, err, "unexpected status code: 503")
+
+	failing.Store(false)
+
+	allRates, err := fetcher.GetAllRates(t.Context())
+	require.NoError(t, err)
+	require.NotEmpty(t, allRates)
+}
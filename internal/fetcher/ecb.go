@@ -0,0 +1,188 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/singleflight"
+)
+
+// ----------------------- ECB eurofxref Cube structure -----------------------
+type ECBEnvelope struct {
+	Days []ECBDay `xml:"Cube>Cube"`
+}
+
+type ECBDay struct {
+	Time  string    `xml:"time,attr"`
+	Rates []ECBRate `xml:"Cube"`
+}
+
+type ECBRate struct {
+	Currency string `xml:"currency,attr"`
+	Rate     string `xml:"rate,attr"`
+}
+
+// ----------------------------------------------------------------------------
+
+// ECBFetcher reads the ECB euro foreign exchange reference rates published as
+// eurofxref-daily.xml (latest day) or eurofxref-hist-90d.xml (last 90 days).
+type ECBFetcher struct {
+	logger *slog.Logger
+	client *http.Client
+	url    string
+	name   string
+
+	// inflight coalesces concurrent feed downloads into a single request
+	inflight singleflight.Group
+
+	// cache keeps the last parsed download, it is revalidated on every fetch as the feed advertises no TTL
+	cache *feedCache[Feed]
+
+	// now is the clock feed dates are validated against
+	now func() time.Time
+}
+
+func NewECBFetcher(logger *slog.Logger, client *http.Client, name, url string) *ECBFetcher {
+	e := &ECBFetcher{
+		logger: logger,
+		client: client,
+		url:    url,
+		name:   name,
+		cache:  newFeedCache[Feed](),
+		now:    time.Now,
+	}
+
+	e.logger = e.logger.With(slog.String("fetcher", "ECBFetcher"), slog.String("url", url))
+
+	return e
+}
+
+func (e *ECBFetcher) Name() string {
+	return e.name
+}
+
+func (e *ECBFetcher) URL() string {
+	return e.url
+}
+
+// GetFeed returns the rates of the valid days grouped by date, and the days rejected by validation.
+// Rejected days are reported by the fetch that downloaded them only, not again while the feed is unchanged.
+func (e *ECBFetcher) GetFeed(ctx context.Context) (Feed, error) {
+	feed, err := e.fetchCubes(ctx)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	if len(feed.Rates) == 0 {
+		return feed, noRatesFound(feed)
+	}
+
+	return feed, nil
+}
+
+// GetAllRates returns all valid exchange rates from the feed grouped by date
+func (e *ECBFetcher) GetAllRates(ctx context.Context) ([][]models.ExchangeRate, error) {
+	feed, err := e.GetFeed(ctx)
+
+	return feed.Rates, err
+}
+
+func (e *ECBFetcher) GetCurrencyRates(ctx context.Context, currency string) ([]models.ExchangeRate, error) {
+	allRates, err := e.GetAllRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	currencyRates := FilterCurrency(allRates, currency)
+	if len(currencyRates) == 0 {
+		return nil, fmt.Errorf("%w '%s'", ErrRateNotFound, currency)
+	}
+
+	return currencyRates, nil
+}
+
+// fetchCubes returns the parsed feed, downloading and parsing it again only when the server reports a change.
+// The download is shared between concurrent callers.
+func (e *ECBFetcher) fetchCubes(ctx context.Context) (Feed, error) {
+	return coalesced(ctx, &e.inflight, e.url, func(ctx context.Context) (Feed, error) {
+		feed, fresh, err := e.cache.get(ctx, func(ctx context.Context, cached validators) (Feed, validators, time.Duration, error) {
+			var envelope ECBEnvelope
+
+			received, err := getXML(ctx, e.client, e.url, cached, &envelope)
+			if err != nil {
+				return Feed{}, received, 0, err
+			}
+
+			return e.parseDays(envelope.Days), received, 0, nil
+		})
+
+		if err == nil && !fresh {
+			e.logger.Debug("feed unchanged, using cached rates")
+
+			feed.Rejected = nil
+		}
+
+		return feed, err
+	})
+}
+
+// parseDays parses and validates every day of the feed, a day with any problem is rejected as a whole
+func (e *ECBFetcher) parseDays(days []ECBDay) Feed {
+	var feed Feed
+
+	now := e.now()
+
+	for _, day := range days {
+		rates, err := e.parseDay(day)
+		if err == nil {
+			err = validateRates(rates, now)
+		}
+
+		if err != nil {
+			e.logger.Warn("feed day rejected", "date", day.Time, "err", err)
+
+			raw, _ := xml.Marshal(day) // nolint:errcheck // ECBDay only holds strings
+			feed.Rejected = append(feed.Rejected, rejectItem(e.name, string(raw), err))
+
+			continue
+		}
+
+		feed.Rates = append(feed.Rates, rates)
+	}
+
+	return feed
+}
+
+// parseDay converts a <Cube time="2026-02-06"> element into rates dated midnight UTC, matching Bank.lv
+func (e *ECBFetcher) parseDay(day ECBDay) ([]models.ExchangeRate, error) {
+	date, err := time.Parse(time.DateOnly, day.Time)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed date %q", ErrInvalidItem, day.Time)
+	}
+
+	var rates []models.ExchangeRate
+	for _, cube := range day.Rates {
+		rate, err := decimal.NewFromString(cube.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: malformed rate %q", ErrInvalidItem, cube.Currency, cube.Rate)
+		}
+
+		rates = append(rates, models.ExchangeRate{
+			Currency: cube.Currency,
+			Rate:     rate,
+			Date:     date,
+		})
+	}
+
+	if len(rates) == 0 {
+		return nil, ErrNoRatesFound
+	}
+
+	return rates, nil
+}
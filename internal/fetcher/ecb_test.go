@@ -0,0 +1,111 @@
+package fetcher
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// trimmed eurofxref-hist-90d.xml response
+const mockECB = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender>
+		<gesmes:name>European Central Bank</gesmes:name>
+	</gesmes:Sender>
+	<Cube>
+		<Cube time='2026-02-03'>
+			<Cube currency='USD' rate='1.1801'/>
+			<Cube currency='JPY' rate='183.92'/>
+			<Cube currency='GBP' rate='0.86230'/>
+		</Cube>
+		<Cube time='2026-02-02'>
+			<Cube currency='USD' rate='1.1840'/>
+			<Cube currency='JPY' rate='183.59'/>
+			<Cube currency='GBP' rate='0.86580'/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestECBGetAllRates(t *testing.T) {
+	t.Parallel()
+
+	date1 := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	date2 := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		response      string
+		statusCode    int
+		expectedRates [][]models.ExchangeRate
+		expectErr     string
+	}{
+		{
+			name:       "Success - Returns all days",
+			response:   mockECB,
+			statusCode: http.StatusOK,
+			expectedRates: [][]models.ExchangeRate{
+				{
+					{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: date2},
+					{Currency: "JPY", Rate: decimal.RequireFromString("183.92"), Date: date2},
+					{Currency: "GBP", Rate: decimal.RequireFromString("0.86230"), Date: date2},
+				},
+				{
+					{Currency: "USD", Rate: decimal.RequireFromString("1.1840"), Date: date1},
+					{Currency: "JPY", Rate: decimal.RequireFromString("183.59"), Date: date1},
+					{Currency: "GBP", Rate: decimal.RequireFromString("0.86580"), Date: date1},
+				},
+			},
+		},
+		{
+			name:       "Skips days with a malformed date",
+			response:   `<Envelope><Cube><Cube time='03.02.2026'><Cube currency='USD' rate='1.1801'/></Cube></Cube></Envelope>`,
+			statusCode: http.StatusOK,
+			expectErr:  "no rates found",
+		},
+		{
+			name:       "ECB returns non-200 status",
+			response:   "error",
+			statusCode: http.StatusServiceUnavailable,
+			expectErr:  "unexpected status code: 503",
+		},
+		{
+			name:       "ECB returns malformed XML",
+			response:   "invalid xml",
+			statusCode: http.StatusOK,
+			expectErr:  "decode XML: EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				_, _ = fmt.Fprint(w, tt.response)
+			}))
+			defer ts.Close()
+
+			fetcher := NewECBFetcher(slog.Default(), ts.Client(), SourceECB90Days, ts.URL)
+
+			rates, err := fetcher.GetAllRates(t.Context())
+
+			if tt.expectErr != "" {
+				require.ErrorContains(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRates, rates)
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package fetcher
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// SourceECBHistory marks rates loaded from the ECB full history archive
+	SourceECBHistory = "ecb-hist"
+
+	ECBHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.zip"
+)
+
+// ECBHistoryReader streams the ECB eurofxref-hist CSV one day at a time.
+// The file has a Date column followed by one column per currency; currencies
+// that were not quoted on a day hold N/A.
+type ECBHistoryReader struct {
+	csv        *csv.Reader
+	currencies []string
+
+	// now is the clock the dates are validated against
+	now func() time.Time
+}
+
+func NewECBHistoryReader(r io.Reader) (*ECBHistoryReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	if len(header) < 2 || !strings.EqualFold(strings.TrimPrefix(header[0], "\ufeff"), "Date") {
+		return nil, errors.New("unexpected CSV header: first column must be Date")
+	}
+
+	currencies := make([]string, 0, len(header)-1)
+	for _, currency := range header[1:] {
+		currencies = append(currencies, strings.TrimSpace(currency))
+	}
+
+	return &ECBHistoryReader{csv: reader, currencies: currencies, now: time.Now}, nil
+}
+
+// Next returns the rates of the next day in the file. Days without any quote yield an empty slice.
+// The rates are validated like those of the feeds, a day with an invalid rate is an error.
+// It returns io.EOF once the file is exhausted.
+func (h *ECBHistoryReader) Next() ([]models.ExchangeRate, error) {
+	record, err := h.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := h.csv.FieldPos(0)
+
+	date, err := time.Parse(time.DateOnly, strings.TrimSpace(record[0]))
+	if err != nil {
+		return nil, fmt.Errorf("line %d: parse date: %w", line, err)
+	}
+
+	rates := make([]models.ExchangeRate, 0, len(h.currencies))
+
+	for i, currency := range h.currencies {
+		// The ECB terminates every line with a comma, leaving an unnamed empty column
+		if currency == "" || i+1 >= len(record) {
+			continue
+		}
+
+		cell := strings.TrimSpace(record[i+1])
+		if cell == "" || cell == "N/A" {
+			continue
+		}
+
+		rate, err := decimal.NewFromString(cell)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: parse %s rate %q: %w", line, currency, cell, err)
+		}
+
+		rates = append(rates, models.ExchangeRate{
+			Currency: currency,
+			Rate:     rate,
+			Date:     date,
+			Source:   SourceECBHistory,
+		})
+	}
+
+	if err := validateRates(rates, h.now()); err != nil {
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+
+	return rates, nil
+}
+
+// OpenECBHistory opens the eurofxref-hist CSV found at path, which is either a local file or an
+// http(s) URL pointing at the zip archive published by the ECB or at the extracted CSV.
+// Zip archives need random access, so remote ones are spooled to a temporary file first.
+func OpenECBHistory(ctx context.Context, client *http.Client, path string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		if strings.EqualFold(filepath.Ext(path), ".zip") {
+			return openZippedCSV(path, nil)
+		}
+
+		return os.Open(path) // nolint:gosec // The path is provided by the operator
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch archive: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close() // nolint:errcheck // We can't do much about a close error here
+
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if !strings.EqualFold(filepath.Ext(req.URL.Path), ".zip") {
+		return resp.Body, nil
+	}
+
+	defer resp.Body.Close() // nolint:errcheck // We can't do much about a close error here
+
+	tmp, err := os.CreateTemp("", "eurofxref-hist-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("create temporary file: %w", err)
+	}
+
+	cleanup := func() error {
+		return errors.Join(tmp.Close(), os.Remove(tmp.Name()))
+	}
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		return nil, errors.Join(fmt.Errorf("download archive: %w", err), cleanup())
+	}
+
+	return openZippedCSV(tmp.Name(), cleanup)
+}
+
+// openZippedCSV opens the first CSV inside the zip archive at path. cleanup, if set, runs on Close.
+func openZippedCSV(path string, cleanup func() error) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		err = fmt.Errorf("open archive: %w", err)
+
+		if cleanup != nil {
+			err = errors.Join(err, cleanup())
+		}
+
+		return nil, err
+	}
+
+	closeAll := func() error {
+		err := archive.Close()
+		if cleanup != nil {
+			err = errors.Join(err, cleanup())
+		}
+
+		return err
+	}
+
+	for _, file := range archive.File {
+		if !strings.EqualFold(filepath.Ext(file.Name), ".csv") {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("open %s: %w", file.Name, err), closeAll())
+		}
+
+		return &zippedCSV{ReadCloser: rc, closeAll: closeAll}, nil
+	}
+
+	return nil, errors.Join(errors.New("archive contains no CSV file"), closeAll())
+}
+
+type zippedCSV struct {
+	io.ReadCloser
+	closeAll func() error
+}
+
+func (z *zippedCSV) Close() error {
+	return errors.Join(z.ReadCloser.Close(), z.closeAll())
+}
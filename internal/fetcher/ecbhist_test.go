@@ -0,0 +1,192 @@
+package fetcher
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// trimmed eurofxref-hist.csv, every line ends with a trailing comma like the original
+const mockECBHistory = `Date,USD,JPY,CYP,GBP,
+2026-02-03,1.1801,183.92,N/A,0.86230,
+2026-02-02,1.1840,183.59,N/A,0.86580,
+1999-01-04,1.1789,133.73,0.58231,0.71110,
+`
+
+func readAll(t *testing.T, r io.Reader) ([][]models.ExchangeRate, error) {
+	t.Helper()
+
+	history, err := NewECBHistoryReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var days [][]models.ExchangeRate
+
+	for {
+		day, err := history.Next()
+		if err == io.EOF {
+			return days, nil
+		}
+
+		if err != nil {
+			return days, err
+		}
+
+		days = append(days, day)
+	}
+}
+
+func TestECBHistoryReader(t *testing.T) {
+	t.Parallel()
+
+	rate := func(currency, value string, date time.Time) models.ExchangeRate {
+		return models.ExchangeRate{Currency: currency, Rate: decimal.RequireFromString(value), Date: date, Source: SourceECBHistory}
+	}
+
+	date1 := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+	date2 := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	date3 := time.Date(1999, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		csv          string
+		expectedDays [][]models.ExchangeRate
+		expectErr    string
+	}{
+		{
+			name: "Success - N/A cells are skipped",
+			csv:  mockECBHistory,
+			expectedDays: [][]models.ExchangeRate{
+				{rate("USD", "1.1801", date1), rate("JPY", "183.92", date1), rate("GBP", "0.86230", date1)},
+				{rate("USD", "1.1840", date2), rate("JPY", "183.59", date2), rate("GBP", "0.86580", date2)},
+				{rate("USD", "1.1789", date3), rate("JPY", "133.73", date3), rate("CYP", "0.58231", date3), rate("GBP", "0.71110", date3)},
+			},
+		},
+		{
+			name:      "Missing Date column",
+			csv:       "USD,JPY\n1.1801,183.92\n",
+			expectErr: "first column must be Date",
+		},
+		{
+			name:      "Malformed date",
+			csv:       "Date,USD,\n03.02.2026,1.1801,\n",
+			expectErr: "line 2: parse date",
+		},
+		{
+			name:      "Malformed rate",
+			csv:       "Date,USD,\n2026-02-03,abc,\n",
+			expectErr: `line 2: parse USD rate "abc"`,
+		},
+		{
+			name:      "Rate that is not positive",
+			csv:       "Date,USD,JPY,\n2026-02-03,0,-183.92,\n",
+			expectErr: "line 2: invalid feed item: USD: rate 0 is not positive; JPY: rate -183.92 is not positive",
+		},
+		{
+			name:      "Unknown currency",
+			csv:       "Date,XYZ,\n2026-02-03,1.1801,\n",
+			expectErr: `line 2: invalid feed item: unknown currency code "XYZ"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			days, err := readAll(t, strings.NewReader(tt.csv))
+
+			if tt.expectErr != "" {
+				require.ErrorContains(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedDays, days)
+		})
+	}
+}
+
+func zipCSV(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("eurofxref-hist.csv")
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte(mockECBHistory))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestOpenECBHistory(t *testing.T) {
+	t.Parallel()
+
+	archive := zipCSV(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eurofxref-hist.zip":
+			_, _ = w.Write(archive)
+		case "/eurofxref-hist.csv":
+			_, _ = w.Write([]byte(mockECBHistory))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "eurofxref-hist.zip"), archive, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "eurofxref-hist.csv"), []byte(mockECBHistory), 0o600))
+
+	tests := []struct {
+		name      string
+		path      string
+		expectErr string
+	}{
+		{name: "Local zip archive", path: filepath.Join(dir, "eurofxref-hist.zip")},
+		{name: "Local CSV", path: filepath.Join(dir, "eurofxref-hist.csv")},
+		{name: "Remote zip archive", path: ts.URL + "/eurofxref-hist.zip"},
+		{name: "Remote CSV", path: ts.URL + "/eurofxref-hist.csv"},
+		{name: "Remote not found", path: ts.URL + "/missing.zip", expectErr: "unexpected status code: 404"},
+		{name: "Local file missing", path: filepath.Join(dir, "missing.csv"), expectErr: "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := OpenECBHistory(t.Context(), ts.Client(), tt.path)
+
+			if tt.expectErr != "" {
+				require.ErrorContains(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			days, err := readAll(t, file)
+			require.NoError(t, err)
+			require.Len(t, days, 3)
+			require.NoError(t, file.Close())
+		})
+	}
+}
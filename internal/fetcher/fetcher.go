@@ -13,7 +13,7 @@ import (
 
 	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
 	"github.com/shopspring/decimal"
-	"golang.org/x/net/html/charset"
+	"golang.org/x/sync/singleflight"
 )
 
 // ----------------------- RSS feed structure based on actual Bank.lv feed -----------------------
@@ -22,6 +22,8 @@ type RSS struct {
 }
 
 type Channel struct {
+	// TTL is the number of minutes the feed may be cached before it is refreshed
+	TTL   int    `xml:"ttl"`
 	Items []Item `xml:"item"`
 }
 
@@ -36,6 +38,15 @@ type BankLatviaFetcher struct {
 	logger *slog.Logger
 	client *http.Client
 	url    string
+
+	// inflight coalesces concurrent feed downloads into a single request
+	inflight singleflight.Group
+
+	// cache keeps the parsed feed for the TTL the feed advertises and revalidates it after that
+	cache *feedCache[Feed]
+
+	// now is the clock feed dates are validated against
+	now func() time.Time
 }
 
 func NewBankLatviaFetcher(logger *slog.Logger, client *http.Client, url string) *BankLatviaFetcher {
@@ -43,6 +54,8 @@ func NewBankLatviaFetcher(logger *slog.Logger, client *http.Client, url string)
 		logger: logger,
 		client: client,
 		url:    url,
+		cache:  newFeedCache[Feed](),
+		now:    time.Now,
 	}
 
 	b.logger = b.logger.With(slog.String("fetcher", "BankLatviaFetcher"), slog.String("url", url))
@@ -50,6 +63,14 @@ func NewBankLatviaFetcher(logger *slog.Logger, client *http.Client, url string)
 	return b
 }
 
+func (b *BankLatviaFetcher) Name() string {
+	return SourceBankLatvia
+}
+
+func (b *BankLatviaFetcher) URL() string {
+	return b.url
+}
+
 const (
 	dateLayout = time.RFC1123Z
 )
@@ -59,45 +80,55 @@ var (
 	ErrRateNotFound = errors.New("rate not found for currency")
 )
 
-// GetAllRates returns all exchange rates from the feed grouped by date
-func (b *BankLatviaFetcher) GetAllRates(ctx context.Context) ([][]models.ExchangeRate, error) {
-	items, err := b.fetchRSS(ctx)
-	if err != nil {
-		return nil, err
+// noRatesFound explains an empty feed, mentioning the items rejected by validation
+func noRatesFound(feed Feed) error {
+	if len(feed.Rejected) > 0 {
+		return fmt.Errorf("%w, %d items rejected: %s", ErrNoRatesFound, len(feed.Rejected), feed.Rejected[0].Reason)
 	}
 
-	if len(items) == 0 {
-		return nil, ErrNoRatesFound
+	return ErrNoRatesFound
+}
+
+// GetFeed returns the rates of the valid feed items grouped by date, and the items rejected by validation.
+// Rejected items are reported by the fetch that downloaded them only, not again while the feed is cached.
+func (b *BankLatviaFetcher) GetFeed(ctx context.Context) (Feed, error) {
+	feed, err := b.fetchRSS(ctx)
+	if err != nil {
+		return Feed{}, err
 	}
 
-	var allRates [][]models.ExchangeRate
-	for _, item := range items {
-		rates, err := b.parseRates(item.Description, b.parseDate(item.PubDate))
-		if err != nil {
-			b.logger.Warn("failed to parse rates for item", "date", item.PubDate, "err", err)
+	if len(feed.Rates) == 0 {
+		return feed, noRatesFound(feed)
+	}
 
-			continue
-		}
+	return feed, nil
+}
 
-		allRates = append(allRates, rates)
-	}
+// GetAllRates returns all valid exchange rates from the feed grouped by date
+func (b *BankLatviaFetcher) GetAllRates(ctx context.Context) ([][]models.ExchangeRate, error) {
+	feed, err := b.GetFeed(ctx)
 
-	return allRates, nil
+	return feed.Rates, err
 }
 
 func (b *BankLatviaFetcher) GetCurrencyRates(ctx context.Context, currency string) ([]models.ExchangeRate, error) {
-	items, err := b.fetchRSS(ctx)
+	allRates, err := b.GetAllRates(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var currencyRates []models.ExchangeRate
-	for _, item := range items {
-		rates, err := b.parseRates(item.Description, b.parseDate(item.PubDate))
-		if err != nil {
-			continue
-		}
+	currencyRates := FilterCurrency(allRates, currency)
+	if len(currencyRates) == 0 {
+		return nil, fmt.Errorf("%w '%s'", ErrRateNotFound, currency)
+	}
+
+	return currencyRates, nil
+}
 
+// FilterCurrency picks the rates of a single currency out of rates grouped by date
+func FilterCurrency(allRates [][]models.ExchangeRate, currency string) []models.ExchangeRate {
+	var currencyRates []models.ExchangeRate
+	for _, rates := range allRates {
 		idx := slices.IndexFunc(rates, func(r models.ExchangeRate) bool {
 			return r.Currency == currency
 		})
@@ -107,38 +138,73 @@ func (b *BankLatviaFetcher) GetCurrencyRates(ctx context.Context, currency strin
 		}
 	}
 
-	if len(currencyRates) == 0 {
-		return nil, fmt.Errorf("%w '%s'", ErrRateNotFound, currency)
-	}
+	return currencyRates
+}
 
-	return currencyRates, nil
+// fetchRSS returns the parsed feed, downloading and parsing it again only when the cached copy is past its
+// TTL and the server reports a change. The download is shared between concurrent callers.
+func (b *BankLatviaFetcher) fetchRSS(ctx context.Context) (Feed, error) {
+	return coalesced(ctx, &b.inflight, b.url, func(ctx context.Context) (Feed, error) {
+		feed, fresh, err := b.cache.get(ctx, func(ctx context.Context, cached validators) (Feed, validators, time.Duration, error) {
+			var rss RSS
+
+			received, err := getXML(ctx, b.client, b.url, cached, &rss)
+			if err != nil {
+				return Feed{}, received, 0, err
+			}
+
+			return b.parseItems(rss.Channel.Items), received, time.Duration(rss.Channel.TTL) * time.Minute, nil
+		})
+
+		if err == nil && !fresh {
+			b.logger.Debug("feed unchanged, using cached rates")
+
+			feed.Rejected = nil
+		}
+
+		return feed, err
+	})
 }
 
-func (b *BankLatviaFetcher) fetchRSS(ctx context.Context) ([]Item, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+// parseItems parses and validates every feed item, an item with any problem is rejected as a whole
+func (b *BankLatviaFetcher) parseItems(items []Item) Feed {
+	var feed Feed
+
+	now := b.now()
+
+	for _, item := range items {
+		rates, err := b.parseItem(item, now)
+		if err != nil {
+			b.logger.Warn("feed item rejected", "date", item.PubDate, "err", err)
+
+			raw, _ := xml.Marshal(item) // nolint:errcheck // Item only holds strings
+			feed.Rejected = append(feed.Rejected, rejectItem(b.Name(), string(raw), err))
+
+			continue
+		}
+
+		feed.Rates = append(feed.Rates, rates)
 	}
 
-	resp, err := b.client.Do(req)
+	return feed
+}
+
+func (b *BankLatviaFetcher) parseItem(item Item, now time.Time) ([]models.ExchangeRate, error) {
+	date, err := b.parseDate(item.PubDate)
 	if err != nil {
-		return nil, fmt.Errorf("fetch RSS feed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close() // nolint:errcheck // We can't do much about a close error here
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	rates, err := b.parseRates(item.Description, date)
+	if err != nil {
+		return nil, err
 	}
 
-	decoder := xml.NewDecoder(resp.Body)
-	decoder.CharsetReader = charset.NewReaderLabel
-
-	var rss RSS
-	if err := decoder.Decode(&rss); err != nil {
-		return nil, fmt.Errorf("decode XML: %w", err)
+	if err := validateRates(rates, now); err != nil {
+		return nil, err
 	}
 
-	return rss.Channel.Items, nil
+	return rates, nil
 }
 
 // parseRates parses the description field into a slice of models.ExchangeRate
@@ -147,15 +213,17 @@ func (b *BankLatviaFetcher) parseRates(description string, date time.Time) ([]mo
 	var rates []models.ExchangeRate
 	fields := strings.Fields(description)
 
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd number of fields (%d), expected currency and rate pairs", ErrInvalidItem, len(fields))
+	}
+
 	// Process pairs: code rate
 	for i := 0; i < len(fields); i += 2 {
 		currency, rateStr := fields[i], fields[i+1]
 
 		rate, err := decimal.NewFromString(rateStr)
 		if err != nil {
-			b.logger.Error("failed to parse rate", "currency", currency, "rateStr", rateStr, "err", err)
-
-			continue
+			return nil, fmt.Errorf("%w: %s: malformed rate %q", ErrInvalidItem, currency, rateStr)
 		}
 
 		rates = append(rates, models.ExchangeRate{
@@ -173,13 +241,11 @@ func (b *BankLatviaFetcher) parseRates(description string, date time.Time) ([]mo
 }
 
 // parseDate parses the pubDate string into time.Time
-func (b *BankLatviaFetcher) parseDate(dateStr string) time.Time {
+func (b *BankLatviaFetcher) parseDate(dateStr string) (time.Time, error) {
 	t, err := time.Parse(dateLayout, dateStr)
 	if err != nil {
-		b.logger.Error("failed to parse date", "dateStr", dateStr, "err", err)
-
-		return time.Time{} // Return zero time on error
+		return time.Time{}, fmt.Errorf("%w: malformed pubDate %q", ErrInvalidItem, dateStr)
 	}
 
-	return t.UTC() // Ensure consistent timezone
+	return t.UTC(), nil // Ensure consistent timezone
 }
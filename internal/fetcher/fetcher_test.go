@@ -5,6 +5,8 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -278,3 +280,38 @@ func TestGetCurrencyRates(t *testing.T) {
 		})
 	}
 }
+
+func TestConcurrentCallsShareOneDownload(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(200 * time.Millisecond) // keep the request in flight while the other callers arrive
+
+		_, _ = fmt.Fprint(w, mockRSS)
+	}))
+	defer ts.Close()
+
+	fetcher := NewBankLatviaFetcher(slog.Default(), ts.Client(), ts.URL)
+
+	currencies := []string{"AUD", "BRL", "CAD", "CHF", "CNY", "CZK", "DKK", "GBP", "HKD", "HUF"}
+
+	var wg sync.WaitGroup
+	for _, currency := range currencies {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			rates, err := fetcher.GetCurrencyRates(t.Context(), currency)
+			require.NoError(t, err)
+			require.Len(t, rates, 2)
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(t, int32(1), hits.Load())
+}
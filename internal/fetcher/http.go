@@ -0,0 +1,79 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/sync/singleflight"
+)
+
+// getXML downloads url and decodes the XML body into v, honouring the charset declared by the document.
+// The request is conditional on cached when it is not empty, ErrNotModified is returned when the document
+// did not change. The validators of the downloaded document are returned for the next request.
+func getXML(ctx context.Context, client *http.Client, url string, cached validators, v any) (validators, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return validators{}, fmt.Errorf("create request: %w", err)
+	}
+
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	if cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return validators{}, fmt.Errorf("fetch feed: %w", err)
+	}
+	defer resp.Body.Close() // nolint:errcheck // We can't do much about a close error here
+
+	if resp.StatusCode == http.StatusNotModified {
+		return cached, ErrNotModified
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return validators{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	decoder := xml.NewDecoder(resp.Body)
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	if err := decoder.Decode(v); err != nil {
+		return validators{}, fmt.Errorf("decode XML: %w", err)
+	}
+
+	return validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// coalesced runs fetch once for all concurrent callers sharing key. The shared call is detached from
+// the caller's cancellation, so one caller giving up does not fail the others; it is bounded by the
+// HTTP client timeout instead.
+func coalesced[T any](ctx context.Context, group *singleflight.Group, key string, fetch func(ctx context.Context) (T, error)) (T, error) {
+	result := group.DoChan(key, func() (any, error) {
+		return fetch(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case <-ctx.Done():
+		var zero T
+
+		return zero, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			var zero T
+
+			return zero, res.Err
+		}
+
+		return res.Val.(T), nil
+	}
+}
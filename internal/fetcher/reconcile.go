@@ -0,0 +1,205 @@
+package fetcher
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ConsensusPolicy selects the value stored for a rate the sources quote differently
+type ConsensusPolicy string
+
+const (
+	PolicyPrimaryWins ConsensusPolicy = "primary-wins" // the most preferred source quoting the rate
+	PolicyMajority    ConsensusPolicy = "majority"     // the value most sources agree on, ties go to the preferred one
+	PolicyMedian      ConsensusPolicy = "median"       // the median quote, the lower middle one for an even count
+)
+
+// ConsensusPolicies lists every consensus policy
+var ConsensusPolicies = []ConsensusPolicy{PolicyPrimaryWins, PolicyMajority, PolicyMedian}
+
+// ParseConsensusPolicy validates a consensus policy name
+func ParseConsensusPolicy(s string) (ConsensusPolicy, error) {
+	policy := ConsensusPolicy(strings.ToLower(strings.TrimSpace(s)))
+
+	if !slices.Contains(ConsensusPolicies, policy) {
+		return "", fmt.Errorf("unknown consensus policy %q", s)
+	}
+
+	return policy, nil
+}
+
+// ReconcileConfig configures how the feeds of several sources are merged
+type ReconcileConfig struct {
+	Policy ConsensusPolicy
+	// Tolerance is the relative spread between the quotes of a rate above which the sources disagree,
+	// 0.0001 allows them to differ by 0.01 %
+	Tolerance decimal.Decimal
+}
+
+// SourceFeed is the feed served by one source
+type SourceFeed struct {
+	Source string
+	Feed
+}
+
+// Reconciler merges the feeds of several sources into one. Every rate quoted by more than one source is compared
+// and the value stored is chosen by the policy, rates the sources disagree on are reported as discrepancies.
+type Reconciler struct {
+	cfg ReconcileConfig
+}
+
+func NewReconciler(cfg ReconcileConfig) *Reconciler {
+	return &Reconciler{cfg: cfg}
+}
+
+// quote is the rate one source gives for a currency and date
+type quote struct {
+	source string
+	rate   decimal.Decimal
+}
+
+// rateKey identifies a rate across feeds, dates are compared by day
+type rateKey struct {
+	day      string
+	currency string
+}
+
+// Reconcile merges feeds given in order of preference. The result holds one rate per currency and date quoted by
+// any of the sources, grouped by date in the order the dates first appear.
+func (rc *Reconciler) Reconcile(feeds []SourceFeed) Feed {
+	var (
+		keys   []rateKey
+		dates  = make(map[string]time.Time)
+		quotes = make(map[rateKey][]quote)
+	)
+
+	for _, feed := range feeds {
+		for _, rates := range feed.Rates {
+			for _, rate := range rates {
+				key := rateKey{day: rate.Date.Format(time.DateOnly), currency: rate.Currency}
+
+				if _, ok := quotes[key]; !ok {
+					keys = append(keys, key)
+				}
+
+				if _, ok := dates[key.day]; !ok {
+					dates[key.day] = rate.Date
+				}
+
+				quotes[key] = append(quotes[key], quote{source: feed.Source, rate: rate.Rate})
+			}
+		}
+	}
+
+	var (
+		merged Feed
+		byDay  = make(map[string]int)
+	)
+
+	for _, key := range keys {
+		date := dates[key.day]
+		chosen := rc.choose(quotes[key])
+
+		idx, ok := byDay[key.day]
+		if !ok {
+			idx = len(merged.Rates)
+			byDay[key.day] = idx
+			merged.Rates = append(merged.Rates, nil)
+		}
+
+		merged.Rates[idx] = append(merged.Rates[idx], models.ExchangeRate{
+			Currency: key.currency,
+			Rate:     chosen.rate,
+			Date:     date,
+			Source:   chosen.source,
+		})
+
+		if discrepancy, ok := rc.compare(key.currency, date, quotes[key], chosen); ok {
+			metrics.RateDiscrepancies.WithLabelValues(key.currency).Inc()
+
+			merged.Discrepancies = append(merged.Discrepancies, discrepancy)
+		}
+	}
+
+	return merged
+}
+
+// choose picks the quote to store out of quotes given in order of preference
+func (rc *Reconciler) choose(quotes []quote) quote {
+	switch rc.cfg.Policy {
+	case PolicyMajority:
+		best, votes := quotes[0], 0
+
+		for _, candidate := range quotes {
+			agreeing := 0
+
+			for _, other := range quotes {
+				if !rc.exceedsTolerance(spread(candidate.rate, other.rate)) {
+					agreeing++
+				}
+			}
+
+			if agreeing > votes {
+				best, votes = candidate, agreeing
+			}
+		}
+
+		return best
+	case PolicyMedian:
+		// The stable sort keeps equal quotes in order of preference
+		sorted := slices.Clone(quotes)
+		slices.SortStableFunc(sorted, func(a, b quote) int { return a.rate.Cmp(b.rate) })
+
+		return sorted[(len(sorted)-1)/2]
+	default:
+		return quotes[0]
+	}
+}
+
+// compare reports the quotes of a rate as a discrepancy when their spread exceeds the tolerance
+func (rc *Reconciler) compare(currency string, date time.Time, quotes []quote, chosen quote) (models.RateDiscrepancy, bool) {
+	if len(quotes) < 2 {
+		return models.RateDiscrepancy{}, false
+	}
+
+	low, high := quotes[0].rate, quotes[0].rate
+	for _, q := range quotes[1:] {
+		low = decimal.Min(low, q.rate)
+		high = decimal.Max(high, q.rate)
+	}
+
+	s := spread(low, high)
+	if !rc.exceedsTolerance(s) {
+		return models.RateDiscrepancy{}, false
+	}
+
+	bySource := make(map[string]decimal.Decimal, len(quotes))
+	for _, q := range quotes {
+		bySource[q.source] = q.rate
+	}
+
+	return models.RateDiscrepancy{
+		Currency:     currency,
+		Date:         date,
+		Quotes:       bySource,
+		Chosen:       chosen.rate,
+		ChosenSource: chosen.source,
+		Policy:       string(rc.cfg.Policy),
+		Spread:       s.Round(rateScale),
+	}, true
+}
+
+func (rc *Reconciler) exceedsTolerance(spread decimal.Decimal) bool {
+	return spread.GreaterThan(rc.cfg.Tolerance)
+}
+
+// spread returns how far apart two positive rates are, relative to the smaller one
+func spread(a, b decimal.Decimal) decimal.Decimal {
+	return a.Sub(b).Abs().Div(decimal.Min(a, b))
+}
@@ -0,0 +1,158 @@
+package fetcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+
+	monday := time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	day := func(date time.Time, usd, gbp string) []models.ExchangeRate {
+		return []models.ExchangeRate{
+			{Currency: "USD", Rate: decimal.RequireFromString(usd), Date: date},
+			{Currency: "GBP", Rate: decimal.RequireFromString(gbp), Date: date},
+		}
+	}
+
+	// Bank.lv published Monday's rates again for Tuesday
+	banklv := SourceFeed{Source: SourceBankLatvia, Feed: Feed{Rates: [][]models.ExchangeRate{
+		day(monday, "1.1840", "0.8658"),
+		day(tuesday, "1.1840", "0.8658"),
+	}}}
+	ecb := SourceFeed{Source: SourceECB, Feed: Feed{Rates: [][]models.ExchangeRate{
+		day(tuesday, "1.1801", "0.8623"),
+	}}}
+	ecb90d := SourceFeed{Source: SourceECB90Days, Feed: Feed{Rates: [][]models.ExchangeRate{
+		day(monday, "1.1840", "0.8658"),
+		day(tuesday, "1.1801", "0.8623"),
+	}}}
+
+	tests := []struct {
+		name      string
+		policy    ConsensusPolicy
+		tolerance string
+		feeds     []SourceFeed
+		// source chosen for Tuesday's USD rate, Monday's sources all agree
+		expectedSource string
+		expectedRate   string
+		expectFlagged  bool
+	}{
+		{
+			name:           "Primary wins",
+			policy:         PolicyPrimaryWins,
+			feeds:          []SourceFeed{banklv, ecb, ecb90d},
+			expectedSource: SourceBankLatvia,
+			expectedRate:   "1.1840",
+			expectFlagged:  true,
+		},
+		{
+			name:           "Majority outvotes the stale primary",
+			policy:         PolicyMajority,
+			feeds:          []SourceFeed{banklv, ecb, ecb90d},
+			expectedSource: SourceECB,
+			expectedRate:   "1.1801",
+			expectFlagged:  true,
+		},
+		{
+			name:           "Majority tie goes to the primary",
+			policy:         PolicyMajority,
+			feeds:          []SourceFeed{banklv, ecb},
+			expectedSource: SourceBankLatvia,
+			expectedRate:   "1.1840",
+			expectFlagged:  true,
+		},
+		{
+			name:           "Median",
+			policy:         PolicyMedian,
+			feeds:          []SourceFeed{banklv, ecb, ecb90d},
+			expectedSource: SourceECB90Days,
+			expectedRate:   "1.1801",
+			expectFlagged:  true,
+		},
+		{
+			name:           "Median of an even count is the lower middle quote",
+			policy:         PolicyMedian,
+			feeds:          []SourceFeed{banklv, ecb},
+			expectedSource: SourceECB,
+			expectedRate:   "1.1801",
+			expectFlagged:  true,
+		},
+		{
+			name:           "Spread within the tolerance",
+			policy:         PolicyMajority,
+			tolerance:      "0.01",
+			feeds:          []SourceFeed{banklv, ecb, ecb90d},
+			expectedSource: SourceBankLatvia,
+			expectedRate:   "1.1840",
+		},
+		{
+			name:           "Single source",
+			policy:         PolicyMedian,
+			feeds:          []SourceFeed{banklv},
+			expectedSource: SourceBankLatvia,
+			expectedRate:   "1.1840",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tolerance := decimal.Zero
+			if tt.tolerance != "" {
+				tolerance = decimal.RequireFromString(tt.tolerance)
+			}
+
+			feed := NewReconciler(ReconcileConfig{Policy: tt.policy, Tolerance: tolerance}).Reconcile(tt.feeds)
+
+			require.Len(t, feed.Rates, 2, "one group per date")
+			require.Equal(t, monday, feed.Rates[0][0].Date)
+			require.Equal(t, SourceBankLatvia, feed.Rates[0][0].Source)
+
+			usd := feed.Rates[1][0]
+			require.Equal(t, "USD", usd.Currency)
+			require.Equal(t, tuesday, usd.Date)
+			require.Equal(t, tt.expectedSource, usd.Source)
+			require.True(t, decimal.RequireFromString(tt.expectedRate).Equal(usd.Rate), usd.Rate.String())
+
+			if !tt.expectFlagged {
+				require.Empty(t, feed.Discrepancies)
+
+				return
+			}
+
+			// Tuesday's USD and GBP rates are flagged, Monday's are not
+			require.Len(t, feed.Discrepancies, 2)
+
+			flagged := feed.Discrepancies[0]
+			require.Equal(t, "USD", flagged.Currency)
+			require.Equal(t, tuesday, flagged.Date)
+			require.Len(t, flagged.Quotes, len(tt.feeds))
+			require.True(t, decimal.RequireFromString("1.1840").Equal(flagged.Quotes[SourceBankLatvia]))
+			require.True(t, usd.Rate.Equal(flagged.Chosen))
+			require.Equal(t, tt.expectedSource, flagged.ChosenSource)
+			require.Equal(t, string(tt.policy), flagged.Policy)
+			require.Equal(t, "0.0033048", flagged.Spread.String())
+			require.Equal(t, "GBP", feed.Discrepancies[1].Currency)
+		})
+	}
+}
+
+func TestParseConsensusPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := ParseConsensusPolicy(" Majority ")
+	require.NoError(t, err)
+	require.Equal(t, PolicyMajority, policy)
+
+	_, err = ParseConsensusPolicy("average")
+	require.EqualError(t, err, `unknown consensus policy "average"`)
+}
@@ -0,0 +1,270 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// Well-known rate sources
+const (
+	SourceBankLatvia = "banklv"
+	SourceECB        = "ecb"
+	SourceECB90Days  = "ecb-90d"
+
+	BankLatviaURL = "https://www.bank.lv/vk/ecb_rss.xml"
+	ECBDailyURL   = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	ECB90DaysURL  = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+)
+
+var ErrUnknownSource = errors.New("unknown rate source")
+
+// Feed is what a source served: the rates of its valid items grouped by date, and the items it rejected.
+// A reconciled feed also lists the rates its sources disagreed on.
+type Feed struct {
+	Rates         [][]models.ExchangeRate
+	Rejected      []models.QuarantinedItem
+	Discrepancies []models.RateDiscrepancy
+}
+
+// Source is an upstream provider of EUR-based reference rates
+type Source interface {
+	Name() string
+	URL() string
+	// GetFeed returns the feed, including the rejected items when it fails because none were valid
+	GetFeed(ctx context.Context) (Feed, error)
+}
+
+// Registry holds the available rate sources and serves rates from the selected ones in order:
+// the first selected source is the primary, the rest are fallbacks used when it fails.
+// With reconciliation every selected source is asked instead and their feeds are merged.
+// Every source is guarded by its own circuit breaker, a source whose circuit is open is skipped.
+type Registry struct {
+	logger     *slog.Logger
+	sources    map[string]Source
+	breakers   map[string]*Breaker
+	selected   []string
+	reconciler *Reconciler
+
+	breakerCfg BreakerConfig
+}
+
+// RegistryOption configures optional Registry behaviour
+type RegistryOption func(*Registry)
+
+// WithBreaker guards every source with a circuit breaker configured by cfg
+func WithBreaker(cfg BreakerConfig) RegistryOption {
+	return func(r *Registry) {
+		r.breakerCfg = cfg
+	}
+}
+
+// WithReconciliation asks every selected source on each fetch and merges their feeds as cfg says
+func WithReconciliation(cfg ReconcileConfig) RegistryOption {
+	return func(r *Registry) {
+		r.reconciler = NewReconciler(cfg)
+	}
+}
+
+func NewRegistry(logger *slog.Logger, opts ...RegistryOption) *Registry {
+	r := &Registry{
+		logger:   logger,
+		sources:  make(map[string]Source),
+		breakers: make(map[string]*Breaker),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.logger = r.logger.With(slog.String("component", "registry"))
+
+	return r
+}
+
+// NewDefaultRegistry registers Bank.lv and both ECB feeds, with Bank.lv selected
+func NewDefaultRegistry(logger *slog.Logger, client *http.Client, opts ...RegistryOption) *Registry {
+	r := NewRegistry(logger, opts...)
+
+	r.Register(NewBankLatviaFetcher(logger, client, BankLatviaURL))
+	r.Register(NewECBFetcher(logger, client, SourceECB, ECBDailyURL))
+	r.Register(NewECBFetcher(logger, client, SourceECB90Days, ECB90DaysURL))
+
+	r.selected = []string{SourceBankLatvia}
+
+	return r
+}
+
+// Register adds a source, replacing any previously registered source with the same name
+func (r *Registry) Register(source Source) {
+	r.sources[source.Name()] = source
+	r.breakers[source.Name()] = NewBreaker(source.Name(), r.breakerCfg)
+}
+
+// Names returns the names of all registered sources
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.sources))
+	for name := range r.sources {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// SourceURL returns the feed URL of a registered source, empty for unknown ones
+func (r *Registry) SourceURL(name string) string {
+	source, ok := r.sources[name]
+	if !ok {
+		return ""
+	}
+
+	return source.URL()
+}
+
+// Select sets the sources to read from, in order of preference
+func (r *Registry) Select(names ...string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("%w: no source selected", ErrUnknownSource)
+	}
+
+	for _, name := range names {
+		if _, ok := r.sources[name]; !ok {
+			return fmt.Errorf("%w '%s', available: %s", ErrUnknownSource, name, strings.Join(r.Names(), ", "))
+		}
+	}
+
+	r.selected = slices.Clone(names)
+
+	return nil
+}
+
+// GetFeed returns the feed of the first selected source that succeeds, its rates tagged with the source's name.
+// With reconciliation it returns the merged feeds of every selected source that succeeds instead.
+// The items rejected by every source asked are returned as well, also when all of them failed.
+func (r *Registry) GetFeed(ctx context.Context) (Feed, error) {
+	var (
+		feeds    []SourceFeed
+		errs     []error
+		skipped  []*CircuitOpenError
+		rejected []models.QuarantinedItem
+	)
+
+	for _, name := range r.selected {
+		feed, err := r.fetchSource(ctx, name)
+		rejected = append(rejected, feed.Rejected...)
+
+		var openErr *CircuitOpenError
+
+		switch {
+		case errors.As(err, &openErr):
+			skipped = append(skipped, openErr)
+
+			// Not wrapped, see below
+			errs = append(errs, fmt.Errorf("source %s: %s", name, err))
+
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("source %s: %w", name, err))
+
+			continue
+		}
+
+		if r.reconciler == nil {
+			return Feed{Rates: feed.Rates, Rejected: rejected}, nil
+		}
+
+		feeds = append(feeds, SourceFeed{Source: name, Feed: feed})
+	}
+
+	if len(feeds) > 0 {
+		if len(errs) > 0 {
+			r.logger.Warn("reconciling without some sources", slog.Any("error", errors.Join(errs...)))
+		}
+
+		merged := r.reconciler.Reconcile(feeds)
+		merged.Rejected = rejected
+
+		return merged, nil
+	}
+
+	// Waiting for a circuit to close is only worth it when no source could be asked at all, so that is the only
+	// case in which the caller gets to see the circuit that closes first
+	if len(skipped) == len(r.selected) {
+		soonest := slices.MinFunc(skipped, func(a, b *CircuitOpenError) int { return a.Until.Compare(b.Until) })
+
+		return Feed{}, fmt.Errorf("every selected source has an open circuit, the first to close: %w", soonest)
+	}
+
+	return Feed{Rejected: rejected}, errors.Join(errs...)
+}
+
+// fetchSource fetches the feed of one source through its circuit breaker and tags its rates with the source's
+// name. A source whose circuit is open is not asked, the error is then a *CircuitOpenError.
+func (r *Registry) fetchSource(ctx context.Context, name string) (Feed, error) {
+	breaker := r.breakers[name]
+
+	if err := breaker.Allow(); err != nil {
+		r.logger.Warn("source skipped", slog.String("source", name), slog.Any("error", err))
+		metrics.SourceFetches.WithLabelValues(name, metrics.ResultCircuitOpen).Inc()
+
+		return Feed{}, err
+	}
+
+	feed, err := r.sources[name].GetFeed(ctx)
+
+	// A caller giving up says nothing about the health of the source
+	if ctx.Err() != nil {
+		breaker.Release()
+	} else {
+		breaker.Record(err)
+	}
+
+	if err != nil {
+		r.logger.Warn("source failed", slog.String("source", name), slog.Any("error", err))
+		metrics.SourceFetches.WithLabelValues(name, metrics.ResultFailure).Inc()
+
+		return feed, err
+	}
+
+	metrics.SourceFetches.WithLabelValues(name, metrics.ResultSuccess).Inc()
+
+	// Sources hand every caller the feed they cached, the rates are tagged on a copy of it
+	tagged := make([][]models.ExchangeRate, 0, len(feed.Rates))
+	for _, rates := range feed.Rates {
+		rates = slices.Clone(rates)
+		for i := range rates {
+			rates[i].Source = name
+		}
+
+		tagged = append(tagged, rates)
+	}
+
+	feed.Rates = tagged
+
+	return feed, nil
+}
+
+// Available returns nil while at least one selected source accepts fetches, and the reasons otherwise
+func (r *Registry) Available() error {
+	var errs []error
+
+	for _, name := range r.selected {
+		err := r.breakers[name].Open()
+		if err == nil {
+			return nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,245 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSource struct {
+	name     string
+	rates    [][]models.ExchangeRate
+	rejected []models.QuarantinedItem
+	err      error
+	calls    int
+}
+
+func (m *mockSource) Name() string { return m.name }
+
+func (m *mockSource) URL() string { return "https://" + m.name + ".example/rates.xml" }
+
+func (m *mockSource) GetFeed(ctx context.Context) (Feed, error) {
+	m.calls++
+
+	if m.err != nil {
+		return Feed{Rejected: m.rejected}, m.err
+	}
+
+	return Feed{Rates: m.rates, Rejected: m.rejected}, nil
+}
+
+func TestRegistryGetAllRates(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+	rates := func() [][]models.ExchangeRate {
+		return [][]models.ExchangeRate{{{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: date}}}
+	}
+
+	tests := []struct {
+		name           string
+		primaryErr     error
+		fallbackErr    error
+		expectedSource string
+		fallbackCalls  int
+		expectErr      string
+	}{
+		{
+			name:           "Primary succeeds",
+			expectedSource: "primary",
+			fallbackCalls:  0,
+		},
+		{
+			name:           "Falls back when primary fails",
+			primaryErr:     errors.New("unexpected status code: 503"),
+			expectedSource: "fallback",
+			fallbackCalls:  1,
+		},
+		{
+			name:          "All sources fail",
+			primaryErr:    errors.New("unexpected status code: 503"),
+			fallbackErr:   errors.New("decode XML: EOF"),
+			fallbackCalls: 1,
+			expectErr:     "source primary: unexpected status code: 503\nsource fallback: decode XML: EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			primary := &mockSource{name: "primary", rates: rates(), err: tt.primaryErr}
+			fallback := &mockSource{name: "fallback", rates: rates(), err: tt.fallbackErr}
+
+			registry := NewRegistry(slog.Default())
+			registry.Register(primary)
+			registry.Register(fallback)
+
+			require.NoError(t, registry.Select("primary", "fallback"))
+
+			feed, err := registry.GetFeed(t.Context())
+
+			require.Equal(t, tt.fallbackCalls, fallback.calls)
+
+			if tt.expectErr != "" {
+				require.EqualError(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedSource, feed.Rates[0][0].Source)
+
+			for _, source := range []*mockSource{primary, fallback} {
+				require.Empty(t, source.rates[0][0].Source, "the feed a source returns may be cached, it is not modified")
+			}
+		})
+	}
+}
+
+func TestRegistrySelect(t *testing.T) {
+	t.Parallel()
+
+	registry := NewDefaultRegistry(slog.Default(), nil)
+
+	require.Equal(t, []string{SourceBankLatvia, SourceECB, SourceECB90Days}, registry.Names())
+	require.NoError(t, registry.Select(SourceECB, SourceBankLatvia))
+
+	err := registry.Select("fixer")
+	require.ErrorIs(t, err, ErrUnknownSource)
+	require.EqualError(t, err, "unknown rate source 'fixer', available: banklv, ecb, ecb-90d")
+
+	require.ErrorIs(t, registry.Select(), ErrUnknownSource)
+
+	require.Equal(t, ECBDailyURL, registry.SourceURL(SourceECB))
+	require.Empty(t, registry.SourceURL("fixer"))
+}
+
+func TestRegistryCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	primary := &mockSource{name: "primary", err: errors.New("unexpected status code: 503")}
+	fallback := &mockSource{name: "fallback", rates: [][]models.ExchangeRate{{{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: date}}}}
+
+	registry := NewRegistry(slog.Default(), WithBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour}))
+	registry.Register(primary)
+	registry.Register(fallback)
+
+	require.NoError(t, registry.Select("primary", "fallback"))
+
+	for range 3 {
+		feed, err := registry.GetFeed(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, "fallback", feed.Rates[0][0].Source)
+	}
+
+	require.Equal(t, 2, primary.calls, "an open circuit skips the source")
+	require.NoError(t, registry.Available(), "the fallback still accepts fetches")
+
+	fallback.err = errors.New("decode XML: EOF")
+
+	for range 2 {
+		_, err := registry.GetFeed(t.Context())
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrCircuitOpen, "a source was asked, waiting for the circuit does not help")
+	}
+
+	require.ErrorIs(t, registry.Available(), ErrCircuitOpen)
+
+	_, err := registry.GetFeed(t.Context())
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.ErrorContains(t, err, "every selected source has an open circuit")
+
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	require.Equal(t, "primary", openErr.Source, "the primary circuit opened first and closes first")
+	require.Equal(t, 2, primary.calls)
+	require.Equal(t, 2+3, fallback.calls)
+}
+
+func TestRegistryCollectsRejectedItems(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	primary := &mockSource{
+		name:     "primary",
+		rejected: []models.QuarantinedItem{{Source: "primary", Item: "<Item/>", Reason: "invalid feed item: missing date"}},
+		err:      ErrNoRatesFound,
+	}
+	fallback := &mockSource{
+		name:     "fallback",
+		rates:    [][]models.ExchangeRate{{{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: date}}},
+		rejected: []models.QuarantinedItem{{Source: "fallback", Item: "<Cube/>", Reason: "invalid feed item: duplicate currency USD"}},
+	}
+
+	registry := NewRegistry(slog.Default())
+	registry.Register(primary)
+	registry.Register(fallback)
+
+	require.NoError(t, registry.Select("primary", "fallback"))
+
+	feed, err := registry.GetFeed(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "fallback", feed.Rates[0][0].Source)
+	require.Equal(t, append(primary.rejected, fallback.rejected...), feed.Rejected)
+
+	fallback.err = errors.New("unexpected status code: 503")
+
+	feed, err = registry.GetFeed(t.Context())
+	require.Error(t, err)
+	require.Empty(t, feed.Rates)
+	require.Equal(t, append(primary.rejected, fallback.rejected...), feed.Rejected)
+}
+
+func TestRegistryReconciliation(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+	quote := func(rate string) [][]models.ExchangeRate {
+		return [][]models.ExchangeRate{{{Currency: "USD", Rate: decimal.RequireFromString(rate), Date: date}}}
+	}
+
+	primary := &mockSource{name: "primary", rates: quote("1.1840")}
+	second := &mockSource{name: "second", rates: quote("1.1801")}
+	third := &mockSource{name: "third", rates: quote("1.1801")}
+
+	registry := NewRegistry(slog.Default(), WithReconciliation(ReconcileConfig{Policy: PolicyMajority}))
+	registry.Register(primary)
+	registry.Register(second)
+	registry.Register(third)
+
+	require.NoError(t, registry.Select("primary", "second", "third"))
+
+	// Every source is asked even though the primary succeeds
+	feed, err := registry.GetFeed(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 1, primary.calls)
+	require.Equal(t, 1, second.calls)
+	require.Equal(t, 1, third.calls)
+	require.Equal(t, "second", feed.Rates[0][0].Source)
+	require.Len(t, feed.Discrepancies, 1)
+
+	// The sources that succeed are reconciled without the failing ones
+	second.err = errors.New("unexpected status code: 503")
+
+	feed, err = registry.GetFeed(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "primary", feed.Rates[0][0].Source, "a tie goes to the primary")
+	require.Len(t, feed.Discrepancies, 1)
+
+	// Without any source there is nothing to reconcile
+	primary.err = ErrNoRatesFound
+	third.err = ErrNoRatesFound
+
+	_, err = registry.GetFeed(t.Context())
+	require.ErrorIs(t, err, ErrNoRatesFound)
+}
@@ -0,0 +1,183 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how often and how patiently a feed download is retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of requests made before giving up, 1 disables retries
+	MaxAttempts int
+
+	// BaseDelay is the backoff before the first retry, doubled for every further one
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff between two attempts
+	MaxDelay time.Duration
+}
+
+// RetryTransport retries requests that failed with a 5xx or 429 response or a transient network error,
+// backing off exponentially with jitter and honouring Retry-After. It gives up early when the wait would
+// outlast the request's deadline, returning the last response or error as is.
+type RetryTransport struct {
+	logger *slog.Logger
+	base   http.RoundTripper
+	policy RetryPolicy
+	now    func() time.Time
+}
+
+// NewRetryTransport wraps base, http.DefaultTransport when nil, with policy
+func NewRetryTransport(logger *slog.Logger, base http.RoundTripper, policy RetryPolicy) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &RetryTransport{
+		logger: logger.With(slog.String("component", "retry")),
+		base:   base,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+
+		if attempt >= t.policy.MaxAttempts || !retryable(ctx, req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After"), t.now()); ok {
+				wait = max(wait, after)
+			}
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && t.now().Add(wait).After(deadline) {
+			return resp, err
+		}
+
+		attrs := []any{
+			slog.String("url", req.URL.String()),
+			slog.Int("attempt", attempt),
+			slog.Duration("retry_in", wait),
+		}
+
+		if resp != nil {
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+
+			// Drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		} else {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+
+		t.logger.Warn("request failed, retrying", attrs...)
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the retry following attempt: half of it fixed, half of it random, so that
+// clients failing together do not retry together
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	delay := t.policy.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+
+		if t.policy.MaxDelay > 0 && delay >= t.policy.MaxDelay {
+			delay = t.policy.MaxDelay
+
+			break
+		}
+	}
+
+	if delay < 2 {
+		return delay
+	}
+
+	return delay/2 + rand.N(delay/2)
+}
+
+// retryable reports whether a request may be repeated after the given outcome
+func retryable(ctx context.Context, req *http.Request, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// A consumed body cannot be sent again, feeds are only ever downloaded with GET
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+
+	if err != nil {
+		return transient(err)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// transient reports whether a network error is likely to go away by itself
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(at.Sub(now), 0), true
+}
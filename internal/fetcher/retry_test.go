@@ -0,0 +1,169 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		statuses      []int
+		retryAfter    string
+		timeout       time.Duration
+		expectedCalls int32
+		expectErr     string
+	}{
+		{
+			name:          "Recovers from a 503 blip",
+			statuses:      []int{http.StatusServiceUnavailable, http.StatusBadGateway},
+			expectedCalls: 3,
+		},
+		{
+			name:          "Retries 429",
+			statuses:      []int{http.StatusTooManyRequests},
+			retryAfter:    "0",
+			expectedCalls: 2,
+		},
+		{
+			name:          "Gives up after max attempts",
+			statuses:      []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedCalls: 3,
+			expectErr:     "unexpected status code: 500",
+		},
+		{
+			name:          "Does not retry client errors",
+			statuses:      []int{http.StatusNotFound},
+			expectedCalls: 1,
+			expectErr:     "unexpected status code: 404",
+		},
+		{
+			name:          "Gives up when Retry-After outlasts the deadline",
+			statuses:      []int{http.StatusServiceUnavailable},
+			retryAfter:    "120",
+			timeout:       time.Second,
+			expectedCalls: 1,
+			expectErr:     "unexpected status code: 503",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := int(calls.Add(1))
+				if call <= len(tt.statuses) {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(tt.statuses[call-1])
+
+					return
+				}
+
+				_, _ = fmt.Fprint(w, mockRSS)
+			}))
+			defer ts.Close()
+
+			client := &http.Client{
+				Transport: NewRetryTransport(slog.Default(), ts.Client().Transport, RetryPolicy{
+					MaxAttempts: 3,
+					BaseDelay:   time.Millisecond,
+					MaxDelay:    10 * time.Millisecond,
+				}),
+			}
+
+			ctx := t.Context()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			var rss RSS
+			_, err := getXML(ctx, client, ts.URL, validators{}, &rss)
+
+			require.Equal(t, tt.expectedCalls, calls.Load())
+
+			if tt.expectErr != "" {
+				require.EqualError(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, rss.Channel.Items, 2)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 2, 6, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "", ok: false},
+		{value: "30", expected: 30 * time.Second, ok: true},
+		{value: "-1", ok: false},
+		{value: "Fri, 06 Feb 2026 15:01:30 GMT", expected: 90 * time.Second, ok: true},
+		{value: "Fri, 06 Feb 2026 14:00:00 GMT", expected: 0, ok: true},
+		{value: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+
+			wait, ok := retryAfter(tt.value, now)
+
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, wait)
+		})
+	}
+}
+
+func TestTransient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Connection reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, expected: true},
+		{name: "Connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, expected: true},
+		{name: "Truncated response", err: io.ErrUnexpectedEOF, expected: true},
+		{name: "DNS timeout", err: &net.DNSError{Err: "timeout", IsTimeout: true}, expected: true},
+		{name: "Unknown host", err: &net.DNSError{Err: "no such host", IsNotFound: true}, expected: false},
+		{name: "Cancelled", err: context.Canceled, expected: false},
+		{name: "Unsupported scheme", err: fmt.Errorf("unsupported protocol scheme %q", "ftp"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, transient(tt.err))
+		})
+	}
+}
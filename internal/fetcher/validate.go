@@ -0,0 +1,109 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidItem marks a feed item that failed parsing or validation
+var ErrInvalidItem = errors.New("invalid feed item")
+
+// Rates are stored as DECIMAL(20, 8): at most 12 integer and 8 fractional digits
+const (
+	rateScale         = 8
+	rateIntegerDigits = 12
+)
+
+var maxRate = decimal.New(1, rateIntegerDigits)
+
+// iso4217 holds the active ISO 4217 currency codes, plus the withdrawn ones still found in ECB history
+var iso4217 = toSet(
+	"AED", "AFN", "ALL", "AMD", "ANG", "AOA", "ARS", "AUD", "AWG", "AZN", "BAM", "BBD", "BDT", "BGN", "BHD",
+	"BIF", "BMD", "BND", "BOB", "BRL", "BSD", "BTN", "BWP", "BYN", "BZD", "CAD", "CDF", "CHF", "CLP", "CNY",
+	"COP", "CRC", "CUP", "CVE", "CZK", "DJF", "DKK", "DOP", "DZD", "EGP", "ERN", "ETB", "EUR", "FJD", "FKP",
+	"GBP", "GEL", "GHS", "GIP", "GMD", "GNF", "GTQ", "GYD", "HKD", "HNL", "HTG", "HUF", "IDR", "ILS", "INR",
+	"IQD", "IRR", "ISK", "JMD", "JOD", "JPY", "KES", "KGS", "KHR", "KMF", "KPW", "KRW", "KWD", "KYD", "KZT",
+	"LAK", "LBP", "LKR", "LRD", "LSL", "LYD", "MAD", "MDL", "MGA", "MKD", "MMK", "MNT", "MOP", "MRU", "MUR",
+	"MVR", "MWK", "MXN", "MYR", "MZN", "NAD", "NGN", "NIO", "NOK", "NPR", "NZD", "OMR", "PAB", "PEN", "PGK",
+	"PHP", "PKR", "PLN", "PYG", "QAR", "RON", "RSD", "RUB", "RWF", "SAR", "SBD", "SCR", "SDG", "SEK", "SGD",
+	"SHP", "SLE", "SOS", "SRD", "SSP", "STN", "SVC", "SYP", "SZL", "THB", "TJS", "TMT", "TND", "TOP", "TRY",
+	"TTD", "TWD", "TZS", "UAH", "UGX", "USD", "UYU", "UZS", "VES", "VND", "VUV", "WST", "XAF", "XCD", "XCG",
+	"XOF", "XPF", "YER", "ZAR", "ZMW", "ZWG",
+	// Withdrawn, quoted by the ECB before the euro or a redenomination replaced them
+	"CYP", "EEK", "HRK", "LTL", "LVL", "MTL", "ROL", "SIT", "SKK", "TRL",
+)
+
+func toSet(codes ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		set[code] = struct{}{}
+	}
+
+	return set
+}
+
+// KnownCurrency reports whether code is an ISO 4217 currency code
+func KnownCurrency(code string) bool {
+	_, ok := iso4217[code]
+
+	return ok
+}
+
+// validateRates checks the rates parsed from one feed item: known currencies quoted once, positive rates that fit
+// the database column and a date that is set and not in the future. The error lists every problem found.
+func validateRates(rates []models.ExchangeRate, now time.Time) error {
+	var problems []string
+
+	seen := make(map[string]bool, len(rates))
+
+	for _, rate := range rates {
+		if !KnownCurrency(rate.Currency) {
+			problems = append(problems, fmt.Sprintf("unknown currency code %q", rate.Currency))
+		}
+
+		if seen[rate.Currency] {
+			problems = append(problems, fmt.Sprintf("duplicate currency %s", rate.Currency))
+		}
+
+		seen[rate.Currency] = true
+
+		switch {
+		case !rate.Rate.IsPositive():
+			problems = append(problems, fmt.Sprintf("%s: rate %s is not positive", rate.Currency, rate.Rate))
+		case rate.Rate.GreaterThanOrEqual(maxRate):
+			problems = append(problems, fmt.Sprintf("%s: rate %s has more than %d integer digits", rate.Currency, rate.Rate, rateIntegerDigits))
+		case !rate.Rate.Equal(rate.Rate.Truncate(rateScale)):
+			problems = append(problems, fmt.Sprintf("%s: rate %s has more than %d decimal places", rate.Currency, rate.Rate, rateScale))
+		}
+	}
+
+	// Every rate of an item shares its date
+	if len(rates) > 0 {
+		switch date := rates[0].Date; {
+		case date.IsZero():
+			problems = append(problems, "missing date")
+		case date.After(now):
+			problems = append(problems, fmt.Sprintf("date %s is in the future", date.Format(time.DateOnly)))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidItem, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// rejectItem builds the quarantine entry of an item that failed parsing or validation
+func rejectItem(source, item string, err error) models.QuarantinedItem {
+	return models.QuarantinedItem{
+		Source: source,
+		Item:   item,
+		Reason: err.Error(),
+	}
+}
@@ -0,0 +1,166 @@
+package fetcher
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRates(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 2, 6, 16, 15, 0, 0, time.UTC)
+	date := time.Date(2026, 2, 6, 0, 0, 0, 0, time.UTC)
+
+	rate := func(currency, value string) models.ExchangeRate {
+		return models.ExchangeRate{Currency: currency, Rate: decimal.RequireFromString(value), Date: date}
+	}
+
+	tests := []struct {
+		name      string
+		rates     []models.ExchangeRate
+		expectErr string
+	}{
+		{
+			name:  "Valid",
+			rates: []models.ExchangeRate{rate("USD", "1.18400000"), rate("JPY", "183.59"), rate("IDR", "19890")},
+		},
+		{
+			name:      "Unknown currency code",
+			rates:     []models.ExchangeRate{rate("USD", "1.184"), rate("XYZ", "2")},
+			expectErr: `invalid feed item: unknown currency code "XYZ"`,
+		},
+		{
+			name:      "Duplicate currency",
+			rates:     []models.ExchangeRate{rate("USD", "1.184"), rate("USD", "1.185")},
+			expectErr: "invalid feed item: duplicate currency USD",
+		},
+		{
+			name:      "Zero rate",
+			rates:     []models.ExchangeRate{rate("USD", "0")},
+			expectErr: "invalid feed item: USD: rate 0 is not positive",
+		},
+		{
+			name:      "Negative rate",
+			rates:     []models.ExchangeRate{rate("USD", "-1.184")},
+			expectErr: "invalid feed item: USD: rate -1.184 is not positive",
+		},
+		{
+			name:      "Rate too large for the column",
+			rates:     []models.ExchangeRate{rate("IDR", "1000000000000")},
+			expectErr: "invalid feed item: IDR: rate 1000000000000 has more than 12 integer digits",
+		},
+		{
+			name:      "Rate too precise for the column",
+			rates:     []models.ExchangeRate{rate("USD", "1.123456789")},
+			expectErr: "invalid feed item: USD: rate 1.123456789 has more than 8 decimal places",
+		},
+		{
+			name:      "Missing date",
+			rates:     []models.ExchangeRate{{Currency: "USD", Rate: decimal.RequireFromString("1.184")}},
+			expectErr: "invalid feed item: missing date",
+		},
+		{
+			name: "Future date",
+			rates: []models.ExchangeRate{
+				{Currency: "USD", Rate: decimal.RequireFromString("1.184"), Date: date.AddDate(0, 0, 3)},
+			},
+			expectErr: "invalid feed item: date 2026-02-09 is in the future",
+		},
+		{
+			name:      "Every problem is listed",
+			rates:     []models.ExchangeRate{rate("XYZ", "0"), rate("USD", "1.184"), rate("USD", "1.185")},
+			expectErr: `invalid feed item: unknown currency code "XYZ"; XYZ: rate 0 is not positive; duplicate currency USD`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateRates(tt.rates, now)
+
+			if tt.expectErr != "" {
+				require.ErrorIs(t, err, ErrInvalidItem)
+				require.EqualError(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestBankLatviaQuarantinesMalformedItems(t *testing.T) {
+	t.Parallel()
+
+	const feed = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0">
+	<channel>
+		<ttl>5</ttl>
+		<item>
+			<description><![CDATA[AUD 1.70420000 USD 1.18400000 ]]></description>
+			<pubDate>Mon, 02 Feb 2026 02:00:00 +0200</pubDate>
+		</item>
+		<item>
+			<description><![CDATA[AUD 1.68300000 USD ]]></description>
+			<pubDate>Tue, 03 Feb 2026 02:00:00 +0200</pubDate>
+		</item>
+		<item>
+			<description><![CDATA[AUD 1.68300000 USD 1.18010000 ]]></description>
+			<pubDate>Wednesday</pubDate>
+		</item>
+		<item>
+			<description><![CDATA[AUD 1.68300000 USD 1,18 ]]></description>
+			<pubDate>Thu, 05 Feb 2026 02:00:00 +0200</pubDate>
+		</item>
+		<item>
+			<description><![CDATA[AUD 1.68300000 USD -1.18010000 ]]></description>
+			<pubDate>Fri, 06 Feb 2026 02:00:00 +0200</pubDate>
+		</item>
+	</channel>
+</rss>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, feed)
+	}))
+	defer ts.Close()
+
+	fetcher := NewBankLatviaFetcher(slog.Default(), ts.Client(), ts.URL)
+	fetcher.now = func() time.Time { return time.Date(2026, 2, 6, 16, 15, 0, 0, time.UTC) }
+
+	got, err := fetcher.GetFeed(t.Context())
+	require.NoError(t, err)
+
+	require.Len(t, got.Rates, 1, "the valid item is kept")
+	require.Equal(t, "2026-02-02", got.Rates[0][0].Date.Format(time.DateOnly))
+
+	reasons := make([]string, 0, len(got.Rejected))
+	for _, item := range got.Rejected {
+		require.Equal(t, SourceBankLatvia, item.Source)
+		require.Contains(t, item.Item, "<description>")
+
+		reasons = append(reasons, item.Reason)
+	}
+
+	require.Equal(t, []string{
+		"invalid feed item: odd number of fields (3), expected currency and rate pairs",
+		`invalid feed item: malformed pubDate "Wednesday"`,
+		`invalid feed item: USD: malformed rate "1,18"`,
+		"invalid feed item: USD: rate -1.1801 is not positive",
+	}, reasons)
+
+	// Items are quarantined once, a cached copy of the feed does not report them again
+	got, err = fetcher.GetFeed(t.Context())
+	require.NoError(t, err)
+	require.Len(t, got.Rates, 1)
+	require.Empty(t, got.Rejected)
+}
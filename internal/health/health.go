@@ -0,0 +1,180 @@
+// Package health implements the liveness and readiness endpoints used by orchestrator probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusFail        = "fail"
+	StatusUnavailable = "unavailable"
+)
+
+// Check is a named readiness dependency. Run must respect ctx, it is cancelled once the check timeout expires.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Response is the body of both endpoints
+type Response struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type Handler struct {
+	logger  *slog.Logger
+	timeout time.Duration
+	checks  []Check
+}
+
+func NewHandler(logger *slog.Logger, timeout time.Duration, checks ...Check) *Handler {
+	h := &Handler{
+		logger:  logger,
+		timeout: timeout,
+		checks:  checks,
+	}
+
+	h.logger = h.logger.With(slog.String("component", "health"))
+
+	return h
+}
+
+// LivenessHandler reports that the process is up and serving requests. It deliberately checks no
+// dependency, a database outage must not get the pod restarted.
+func (h *Handler) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	h.jsonResponse(w, http.StatusOK, Response{Status: StatusOK})
+}
+
+// ReadinessHandler runs all checks concurrently and answers 503 when any of them fails
+func (h *Handler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	results := make(map[string]CheckResult, len(h.checks))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, check := range h.checks {
+		wg.Go(func() {
+			start := time.Now()
+			err := check.Run(ctx)
+
+			result := CheckResult{Status: StatusOK, Duration: time.Since(start).Round(time.Microsecond).String()}
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		})
+	}
+
+	wg.Wait()
+
+	status, code := StatusOK, http.StatusOK
+
+	for name, result := range results {
+		if result.Status != StatusOK {
+			status, code = StatusUnavailable, http.StatusServiceUnavailable
+
+			h.logger.Warn("readiness check failed", slog.String("check", name), slog.String("error", result.Error))
+		}
+	}
+
+	h.jsonResponse(w, code, Response{Status: status, Checks: results})
+}
+
+func (h *Handler) jsonResponse(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("json encode failed", "err", err)
+	}
+}
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// DatabaseCheck verifies the database answers a ping
+func DatabaseCheck(pinger Pinger) Check {
+	return Check{
+		Name: "database",
+		Run: func(ctx context.Context) error {
+			if err := pinger.Ping(ctx); err != nil {
+				return fmt.Errorf("ping database: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+type NewestRateReader interface {
+	// NewestRateDate returns the date of the most recent stored rate, the zero time when none is stored
+	NewestRateDate(ctx context.Context) (time.Time, error)
+}
+
+var ErrStale = errors.New("rates are stale")
+
+// FreshnessCheck fails when the newest stored rate is older than window, or when no rate is stored at all
+func FreshnessCheck(reader NewestRateReader, window time.Duration, now func() time.Time) Check {
+	return Check{
+		Name: "freshness",
+		Run: func(ctx context.Context) error {
+			newest, err := reader.NewestRateDate(ctx)
+			if err != nil {
+				return fmt.Errorf("read newest rate date: %w", err)
+			}
+
+			if newest.IsZero() {
+				return fmt.Errorf("%w: no rates stored", ErrStale)
+			}
+
+			if age := now().Sub(newest); age > window {
+				return fmt.Errorf("%w: newest rate is from %s, %s old, window is %s",
+					ErrStale, newest.Format(time.DateOnly), age.Round(time.Minute), window)
+			}
+
+			return nil
+		},
+	}
+}
+
+type SourceAvailability interface {
+	// Available returns nil while at least one rate source accepts fetches
+	Available() error
+}
+
+// SourcesCheck fails while the circuit breaker of every rate source is open, the scheduler cannot fetch then
+func SourcesCheck(sources SourceAvailability) Check {
+	return Check{
+		Name: "sources",
+		Run: func(ctx context.Context) error {
+			return sources.Available()
+		},
+	}
+}
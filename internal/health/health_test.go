@@ -0,0 +1,161 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockDB struct {
+	pingErr   error
+	newest    time.Time
+	newestErr error
+	block     bool
+
+	// sourcesErr is what the fetcher reports while every circuit is open
+	sourcesErr error
+}
+
+func (m *mockDB) Ping(ctx context.Context) error {
+	if m.block {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	return m.pingErr
+}
+
+func (m *mockDB) NewestRateDate(ctx context.Context) (time.Time, error) {
+	return m.newest, m.newestErr
+}
+
+func (m *mockDB) Available() error {
+	return m.sourcesErr
+}
+
+func TestLivenessHandler(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler(slog.Default(), time.Second, DatabaseCheck(&mockDB{pingErr: errors.New("connection refused")}))
+
+	w := httptest.NewRecorder()
+	h.LivenessHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestReadinessHandler(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 2, 9, 12, 0, 0, 0, time.UTC) // Monday
+	clock := func() time.Time { return now }
+
+	tests := []struct {
+		name           string
+		db             *mockDB
+		expectedStatus int
+		expectedChecks map[string]CheckResult
+	}{
+		{
+			name:           "Ready over the weekend",
+			db:             &mockDB{newest: time.Date(2026, 2, 6, 0, 0, 0, 0, time.UTC)},
+			expectedStatus: http.StatusOK,
+			expectedChecks: map[string]CheckResult{
+				"database":  {Status: StatusOK},
+				"freshness": {Status: StatusOK},
+				"sources":   {Status: StatusOK},
+			},
+		},
+		{
+			name:           "Database unreachable",
+			db:             &mockDB{pingErr: errors.New("connection refused"), newestErr: errors.New("connection refused")},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]CheckResult{
+				"database":  {Status: StatusFail, Error: "ping database: connection refused"},
+				"freshness": {Status: StatusFail, Error: "read newest rate date: connection refused"},
+				"sources":   {Status: StatusOK},
+			},
+		},
+		{
+			name:           "Database ping times out",
+			db:             &mockDB{block: true, newest: time.Date(2026, 2, 6, 0, 0, 0, 0, time.UTC)},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]CheckResult{
+				"database":  {Status: StatusFail, Error: "ping database: context deadline exceeded"},
+				"freshness": {Status: StatusOK},
+				"sources":   {Status: StatusOK},
+			},
+		},
+		{
+			name:           "Empty database",
+			db:             &mockDB{},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]CheckResult{
+				"database":  {Status: StatusOK},
+				"freshness": {Status: StatusFail, Error: "rates are stale: no rates stored"},
+				"sources":   {Status: StatusOK},
+			},
+		},
+		{
+			name:           "Stale rates",
+			db:             &mockDB{newest: time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC)},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]CheckResult{
+				"database":  {Status: StatusOK},
+				"freshness": {Status: StatusFail, Error: "rates are stale: newest rate is from 2026-01-30, 252h0m0s old, window is 168h0m0s"},
+				"sources":   {Status: StatusOK},
+			},
+		},
+		{
+			name: "Every source circuit open",
+			db: &mockDB{
+				newest:     time.Date(2026, 2, 6, 0, 0, 0, 0, time.UTC),
+				sourcesErr: errors.New("circuit open for source banklv, next attempt in 4m0s"),
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]CheckResult{
+				"database":  {Status: StatusOK},
+				"freshness": {Status: StatusOK},
+				"sources":   {Status: StatusFail, Error: "circuit open for source banklv, next attempt in 4m0s"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHandler(slog.Default(), 50*time.Millisecond,
+				DatabaseCheck(tt.db),
+				FreshnessCheck(tt.db, 168*time.Hour, clock),
+				SourcesCheck(tt.db),
+			)
+
+			w := httptest.NewRecorder()
+			h.ReadinessHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+
+			var resp Response
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+			for name, result := range resp.Checks {
+				require.NotEmpty(t, result.Duration)
+
+				result.Duration = ""
+				resp.Checks[name] = result
+			}
+
+			require.Equal(t, tt.expectedChecks, resp.Checks)
+		})
+	}
+}
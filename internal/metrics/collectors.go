@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeTimeout bounds the database query made on every scrape
+const scrapeTimeout = 5 * time.Second
+
+type LatestRatesReader interface {
+	GetLatestRates(ctx context.Context) ([]models.ExchangeRate, error)
+}
+
+// FreshnessCollector reports how old the newest stored rate of every currency is. The value is
+// computed on scrape, so it keeps growing while the fetcher is down.
+type FreshnessCollector struct {
+	logger *slog.Logger
+	reader LatestRatesReader
+	now    func() time.Time
+
+	age      *prometheus.Desc
+	scrapeOK *prometheus.Desc
+}
+
+func NewFreshnessCollector(logger *slog.Logger, reader LatestRatesReader) *FreshnessCollector {
+	return &FreshnessCollector{
+		logger: logger.With(slog.String("component", "metrics")),
+		reader: reader,
+		now:    time.Now,
+		age: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "rate_age_seconds"),
+			"Seconds since the publication date of the newest stored rate.",
+			[]string{"currency"}, nil),
+		scrapeOK: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "rate_age_scrape_success"),
+			"Whether the newest stored rates could be read, 1 on success.",
+			nil, nil),
+	}
+}
+
+func (c *FreshnessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.age
+	ch <- c.scrapeOK
+}
+
+func (c *FreshnessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	rates, err := c.reader.GetLatestRates(ctx)
+	if err != nil {
+		c.logger.Error("failed to read latest rates", slog.Any("error", err))
+		ch <- prometheus.MustNewConstMetric(c.scrapeOK, prometheus.GaugeValue, 0)
+
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.scrapeOK, prometheus.GaugeValue, 1)
+
+	now := c.now()
+	for _, rate := range rates {
+		ch <- prometheus.MustNewConstMetric(c.age, prometheus.GaugeValue, now.Sub(rate.Date).Seconds(), rate.Currency)
+	}
+}
+
+type DBStatsProvider interface {
+	Stats() sql.DBStats
+}
+
+// DBStatsCollector exports the connection pool statistics of a database handle
+type DBStatsCollector struct {
+	provider DBStatsProvider
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func NewDBStatsCollector(provider DBStatsProvider) *DBStatsCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, nil, nil)
+	}
+
+	return &DBStatsCollector{
+		provider:          provider,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "The number of established connections both in use and idle."),
+		inUse:             desc("in_use_connections", "The number of connections currently in use."),
+		idle:              desc("idle_connections", "The number of idle connections."),
+		waitCount:         desc("wait_count_total", "The total number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns."),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime."),
+	}
+}
+
+func (c *DBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+func (c *DBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.provider.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
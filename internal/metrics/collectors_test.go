@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLatestReader struct {
+	rates []models.ExchangeRate
+	err   error
+}
+
+func (m *mockLatestReader) GetLatestRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	return m.rates, m.err
+}
+
+func TestFreshnessCollector(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 2, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		reader   *mockLatestReader
+		expected string
+	}{
+		{
+			name: "Reports age per currency",
+			reader: &mockLatestReader{rates: []models.ExchangeRate{
+				{Currency: "GBP", Rate: decimal.RequireFromString("0.8623"), Date: time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)},
+				{Currency: "USD", Rate: decimal.RequireFromString("1.1801"), Date: time.Date(2026, 2, 4, 0, 0, 0, 0, time.UTC)},
+			}},
+			expected: `
+# HELP currency_service_rate_age_seconds Seconds since the publication date of the newest stored rate.
+# TYPE currency_service_rate_age_seconds gauge
+currency_service_rate_age_seconds{currency="GBP"} 129600
+currency_service_rate_age_seconds{currency="USD"} 43200
+# HELP currency_service_rate_age_scrape_success Whether the newest stored rates could be read, 1 on success.
+# TYPE currency_service_rate_age_scrape_success gauge
+currency_service_rate_age_scrape_success 1
+`,
+		},
+		{
+			name:   "Database unavailable",
+			reader: &mockLatestReader{err: errors.New("connection refused")},
+			expected: `
+# HELP currency_service_rate_age_scrape_success Whether the newest stored rates could be read, 1 on success.
+# TYPE currency_service_rate_age_scrape_success gauge
+currency_service_rate_age_scrape_success 0
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			collector := NewFreshnessCollector(slog.Default(), tt.reader)
+			collector.now = func() time.Time { return now }
+
+			require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(tt.expected)))
+		})
+	}
+}
+
+type mockStatsProvider struct {
+	stats sql.DBStats
+}
+
+func (m mockStatsProvider) Stats() sql.DBStats {
+	return m.stats
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	t.Parallel()
+
+	collector := NewDBStatsCollector(mockStatsProvider{stats: sql.DBStats{
+		MaxOpenConnections: 25,
+		OpenConnections:    3,
+		InUse:              1,
+		Idle:               2,
+		WaitCount:          4,
+		WaitDuration:       1500 * time.Millisecond,
+	}})
+
+	expected := `
+# HELP currency_service_db_in_use_connections The number of connections currently in use.
+# TYPE currency_service_db_in_use_connections gauge
+currency_service_db_in_use_connections 1
+# HELP currency_service_db_open_connections The number of established connections both in use and idle.
+# TYPE currency_service_db_open_connections gauge
+currency_service_db_open_connections 3
+# HELP currency_service_db_wait_duration_seconds_total The total time blocked waiting for a new connection.
+# TYPE currency_service_db_wait_duration_seconds_total counter
+currency_service_db_wait_duration_seconds_total 1.5
+`
+
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"currency_service_db_in_use_connections",
+		"currency_service_db_open_connections",
+		"currency_service_db_wait_duration_seconds_total"))
+	require.Equal(t, 9, testutil.CollectAndCount(collector))
+}
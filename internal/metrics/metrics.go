@@ -0,0 +1,72 @@
+// Package metrics defines the Prometheus metrics exported by the service.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "currency_service"
+
+// Fetch results recorded per source and currency
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	// ResultCircuitOpen counts fetches skipped because the source's circuit breaker is open
+	ResultCircuitOpen = "circuit_open"
+
+	// ResultWithheld counts currencies fetched whose rates the anomaly checks all held or rejected
+	ResultWithheld = "withheld"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route pattern, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	SourceFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "source_fetches_total",
+		Help:      "Feed downloads by rate source and result.",
+	}, []string{"source", "result"})
+
+	RateFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_fetches_total",
+		Help:      "Fetched and stored rates by rate source, currency and result.",
+	}, []string{"source", "currency", "result"})
+
+	RateDiscrepancies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_discrepancies_total",
+		Help:      "Rates the reconciled sources disagreed on beyond the tolerance, by currency.",
+	}, []string{"currency"})
+
+	RateAnomalies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_anomalies_total",
+		Help:      "Fetched rates that stood out from their history, by currency and action taken.",
+	}, []string{"currency", "action"})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by result: delivered, retry or failed.",
+	}, []string{"result"})
+
+	StreamClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stream_clients",
+		Help:      "Clients connected to the rate event stream.",
+	})
+)
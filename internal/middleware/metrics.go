@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
+)
+
+// MetricsMiddleware records request counts and latencies labelled by the matched ServeMux pattern,
+// which keeps the label cardinality bounded. It must wrap the mux directly: the mux stores the
+// pattern on the request it receives, so a middleware that copies the request would not see it.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(rw.status)
+
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/rates/history/{currency}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	handler := MetricsMiddleware(mux)
+
+	tests := []struct {
+		name   string
+		path   string
+		route  string
+		status string
+	}{
+		{
+			name:   "Labels by pattern, not by path",
+			path:   "/api/v1/rates/history/USD",
+			route:  "GET /api/v1/rates/history/{currency}",
+			status: "404",
+		},
+		{
+			name:   "Unmatched routes share one label",
+			path:   "/wp-admin",
+			route:  "unmatched",
+			status: "404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(tt.route, http.MethodGet, tt.status)
+			before := testutil.ToFloat64(counter)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			require.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+}
+
+func TestResponseControllerThroughMiddleware(t *testing.T) {
+	// Streaming handlers reach the connection through both wrappers
+	handler := LoggingMiddleware(slog.New(slog.DiscardHandler), MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("data: 1\n\n"))
+
+		if err := rc.Flush(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint:errcheck // Test cleanup
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "data: 1\n\n", string(body))
+}
@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/sqlbind"
+)
+
+// Dialect holds the parts of the migrator that differ between database engines
+type Dialect struct {
+	name string
+
+	// numberedParams switches ? placeholders to $1, $2, ...
+	numberedParams bool
+
+	createTable string
+
+	// lock blocks until the session holds the migration lock or lockTimeout expires
+	lock   func(ctx context.Context, conn *sql.Conn) error
+	unlock func(ctx context.Context, conn *sql.Conn) error
+}
+
+var MariaDB = Dialect{
+	name: config.DriverMariaDB,
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT UNSIGNED PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		dirty BOOLEAN NOT NULL DEFAULT FALSE,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`,
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		var acquired sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&acquired); err != nil {
+			return err
+		}
+
+		if !acquired.Valid || acquired.Int64 != 1 {
+			return ErrLockTimeout
+		}
+
+		return nil
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+
+		return err
+	},
+}
+
+// postgresLockKey is the pg_advisory_lock key, the first bytes of lockName
+const postgresLockKey int64 = 0x63757272656e6379
+
+var Postgres = Dialect{
+	name:           config.DriverPostgres,
+	numberedParams: true,
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		dirty BOOLEAN NOT NULL DEFAULT FALSE,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		// pg_advisory_lock waits without a timeout of its own, so poll the non-blocking variant instead
+		deadline := time.Now().Add(lockTimeout)
+
+		for {
+			var acquired bool
+			if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", postgresLockKey).Scan(&acquired); err != nil {
+				return err
+			}
+
+			if acquired {
+				return nil
+			}
+
+			if time.Now().After(deadline) {
+				return ErrLockTimeout
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", postgresLockKey)
+
+		return err
+	},
+}
+
+var SQLite = Dialect{
+	name: config.DriverSQLite,
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		dirty BOOLEAN NOT NULL DEFAULT FALSE,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// SQLite has no advisory locks. An immediate transaction takes the database write lock instead,
+	// other migrators wait for it up to the busy timeout.
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+
+		return err
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "COMMIT")
+
+		return err
+	},
+}
+
+// DialectFor returns the dialect of a config.DatabaseConfig driver
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case config.DriverMariaDB:
+		return MariaDB, nil
+	case config.DriverPostgres:
+		return Postgres, nil
+	case config.DriverSQLite:
+		return SQLite, nil
+	default:
+		return Dialect{}, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// rebind rewrites ? placeholders for the dialect
+func (d Dialect) rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+
+	return sqlbind.Numbered(query)
+}
@@ -0,0 +1,406 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// lockName is the advisory lock held while migrations run
+	lockName = "currency_service_migrate"
+
+	// lockTimeout bounds how long a migrator waits for another one to finish
+	lockTimeout = time.Minute
+)
+
+var (
+	ErrDirty        = errors.New("database is dirty")
+	ErrLockTimeout  = errors.New("timed out waiting for the migration lock")
+	ErrNoDownScript = errors.New("migration has no down script")
+
+	fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+// Migration is a single schema change identified by its version
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a known migration and whether it has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	Dirty     bool
+}
+
+// Migrator applies the migrations found in an fs.FS and records them in the schema_migrations table.
+// Every operation holds a database advisory lock, so concurrent migrators wait for each other instead of racing.
+type Migrator struct {
+	db         *sql.DB
+	logger     *slog.Logger
+	dialect    Dialect
+	migrations []Migration
+}
+
+func New(logger *slog.Logger, db *sql.DB, fsys fs.FS, dialect Dialect) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migrator{
+		db:         db,
+		logger:     logger,
+		dialect:    dialect,
+		migrations: migrations,
+	}
+
+	m.logger = m.logger.With(slog.String("component", "migrate"), slog.String("dialect", dialect.name))
+
+	return m, nil
+}
+
+// Load reads NNNN_name.up.sql and NNNN_name.down.sql files from the root of fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q, expected NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse version of %q: %w", entry.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.state(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := state[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, migration, migration.Up, true); err != nil {
+				return err
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts up to steps of the most recently applied migrations and returns the ones reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.state(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range slices.Backward(m.migrations) {
+			if len(reverted) == steps {
+				break
+			}
+
+			if _, ok := state[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDownScript, migration.Version, migration.Name)
+			}
+
+			if err := m.apply(ctx, conn, migration, migration.Down, false); err != nil {
+				return err
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status lists every known migration together with when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.readState(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+
+			if row, ok := state[migration.Version]; ok {
+				status.AppliedAt = &row.appliedAt
+				status.Dirty = row.dirty
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Version returns the highest applied version, 0 when nothing has been applied yet
+func (m *Migrator) Version(ctx context.Context) (uint64, bool, error) {
+	var (
+		version uint64
+		dirty   bool
+	)
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		state, err := m.readState(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for v, row := range state {
+			if v > version {
+				version, dirty = v, row.dirty
+			}
+		}
+
+		return nil
+	})
+
+	return version, dirty, err
+}
+
+type appliedRow struct {
+	appliedAt time.Time
+	dirty     bool
+}
+
+// state returns the applied migrations and refuses to continue when a previous run left one half applied
+func (m *Migrator) state(ctx context.Context, conn *sql.Conn) (map[uint64]appliedRow, error) {
+	state, err := m.readState(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for version, row := range state {
+		if row.dirty {
+			return nil, fmt.Errorf("%w: migration %d failed part-way, fix the schema by hand and delete its row from schema_migrations", ErrDirty, version)
+		}
+	}
+
+	return state, nil
+}
+
+func (m *Migrator) readState(ctx context.Context, conn *sql.Conn) (map[uint64]appliedRow, error) {
+	_, err := conn.ExecContext(ctx, m.dialect.createTable)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, dirty, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	state := make(map[uint64]appliedRow)
+
+	for rows.Next() {
+		var (
+			version uint64
+			row     appliedRow
+		)
+
+		if err := rows.Scan(&version, &row.dirty, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+
+		state[version] = row
+	}
+
+	return state, rows.Err()
+}
+
+// apply runs script statement by statement. MariaDB commits DDL implicitly, so instead of a transaction
+// the version is marked dirty while the script runs and only cleaned up once every statement succeeded.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, script string, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	logger := m.logger.With(slog.Uint64("version", migration.Version), slog.String("name", migration.Name), slog.String("direction", direction))
+	start := time.Now()
+
+	mark := "INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, TRUE)"
+	if !up {
+		mark = "UPDATE schema_migrations SET dirty = TRUE WHERE version = ? AND name = ?"
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.rebind(mark), migration.Version, migration.Name); err != nil {
+		return fmt.Errorf("mark migration %d dirty: %w", migration.Version, err)
+	}
+
+	for i, statement := range SplitStatements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			logger.Error("migration failed", slog.Int("statement", i+1), slog.Any("error", err))
+
+			return fmt.Errorf("migration %d_%s %s, statement %d: %w", migration.Version, migration.Name, direction, i+1, err)
+		}
+	}
+
+	done := "UPDATE schema_migrations SET dirty = FALSE, applied_at = CURRENT_TIMESTAMP WHERE version = ?"
+	if !up {
+		done = "DELETE FROM schema_migrations WHERE version = ?"
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.rebind(done), migration.Version); err != nil {
+		return fmt.Errorf("record migration %d: %w", migration.Version, err)
+	}
+
+	logger.Info("migration applied", slog.Duration("duration", time.Since(start)))
+
+	return nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock.
+// Advisory locks are bound to the session, so the lock and the migrations must share one connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close() // nolint:errcheck // We can't do much about a close error here
+
+	if err := m.dialect.lock(ctx, conn); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		// Use a fresh context so the lock is released even when ctx was cancelled
+		if releaseErr := m.dialect.unlock(context.WithoutCancel(ctx), conn); releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("release migration lock: %w", releaseErr))
+		}
+	}()
+
+	return fn(conn)
+}
+
+// SplitStatements splits a script on the semicolons that end a line, dropping empty statements and
+// -- comment lines. Migrations must not rely on semicolons at the end of lines inside string literals.
+func SplitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+
+		current.Reset()
+	}
+
+	for line := range strings.Lines(script) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		if strings.HasSuffix(trimmed, ";") {
+			current.WriteString(strings.TrimSuffix(strings.TrimRight(line, "\r\n \t"), ";"))
+			flush()
+
+			continue
+		}
+
+		current.WriteString(line)
+	}
+
+	flush()
+
+	return statements
+}
@@ -0,0 +1,214 @@
+package migrate
+
+import (
+	"database/sql"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/migrations"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		fsys      fstest.MapFS
+		expected  []Migration
+		expectErr string
+	}{
+		{
+			name: "Orders by version and pairs up and down scripts",
+			fsys: fstest.MapFS{
+				"0010_add_index.up.sql":   {Data: []byte("CREATE INDEX a ON t (a);")},
+				"0002_create_t.up.sql":    {Data: []byte("CREATE TABLE t (a INT);")},
+				"0002_create_t.down.sql":  {Data: []byte("DROP TABLE t;")},
+				"migrations.go":           {Data: []byte("package migrations")},
+				"0010_add_index.down.sql": {Data: []byte("DROP INDEX a ON t;")},
+			},
+			expected: []Migration{
+				{Version: 2, Name: "create_t", Up: "CREATE TABLE t (a INT);", Down: "DROP TABLE t;"},
+				{Version: 10, Name: "add_index", Up: "CREATE INDEX a ON t (a);", Down: "DROP INDEX a ON t;"},
+			},
+		},
+		{
+			name:      "Invalid file name",
+			fsys:      fstest.MapFS{"create_t.sql": {Data: []byte("CREATE TABLE t (a INT);")}},
+			expectErr: `invalid migration file name "create_t.sql"`,
+		},
+		{
+			name:      "Down script without up script",
+			fsys:      fstest.MapFS{"0001_create_t.down.sql": {Data: []byte("DROP TABLE t;")}},
+			expectErr: "migration 1_create_t has no up script",
+		},
+		{
+			name: "Conflicting names",
+			fsys: fstest.MapFS{
+				"0001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (a INT);")},
+				"0001_create_u.down.sql": {Data: []byte("DROP TABLE u;")},
+			},
+			expectErr: "migration 1 has conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			loaded, err := Load(tt.fsys)
+
+			if tt.expectErr != "" {
+				require.ErrorContains(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, loaded)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	t.Parallel()
+
+	var versions [][]uint64
+
+	for _, driver := range []string{config.DriverMariaDB, config.DriverPostgres, config.DriverSQLite} {
+		fsys, err := migrations.ForDriver(driver)
+		require.NoError(t, err)
+
+		loaded, err := Load(fsys)
+		require.NoError(t, err)
+		require.NotEmpty(t, loaded)
+
+		var driverVersions []uint64
+
+		for i, m := range loaded {
+			require.Equal(t, uint64(i+1), m.Version, "%s migration versions must be consecutive", driver)
+			require.NotEmpty(t, m.Down, "%s migration %d_%s needs a down script", driver, m.Version, m.Name)
+
+			driverVersions = append(driverVersions, m.Version)
+		}
+
+		versions = append(versions, driverVersions)
+	}
+
+	for _, driverVersions := range versions[1:] {
+		require.Equal(t, versions[0], driverVersions, "every driver must have the same migrations")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		script   string
+		expected []string
+	}{
+		{
+			name:     "Single statement",
+			script:   "DROP TABLE IF EXISTS exchange_rates;\n",
+			expected: []string{"DROP TABLE IF EXISTS exchange_rates"},
+		},
+		{
+			name: "Multi-line statements and comments",
+			script: `-- rates table
+CREATE TABLE t (
+    a INT,
+    b VARCHAR(3)
+);
+
+-- index
+CREATE INDEX idx_b ON t (b);`,
+			expected: []string{
+				"CREATE TABLE t (\n    a INT,\n    b VARCHAR(3)\n)",
+				"CREATE INDEX idx_b ON t (b)",
+			},
+		},
+		{
+			name:     "Trailing statement without semicolon",
+			script:   "ALTER TABLE t ADD c INT;\nALTER TABLE t ADD d INT",
+			expected: []string{"ALTER TABLE t ADD c INT", "ALTER TABLE t ADD d INT"},
+		},
+		{
+			name:     "Semicolon inside a line is kept",
+			script:   "INSERT INTO t (b) VALUES ('a;b');\n",
+			expected: []string{"INSERT INTO t (b) VALUES ('a;b')"},
+		},
+		{
+			name:   "Empty script",
+			script: "\n-- nothing to do\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, SplitStatements(tt.script))
+		})
+	}
+}
+
+func TestMigratorSQLite(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "rates.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	fsys := fstest.MapFS{
+		"0001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (a INTEGER);")},
+		"0001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+		"0002_add_b.up.sql":      {Data: []byte("ALTER TABLE t ADD COLUMN b TEXT;\nCREATE INDEX idx_b ON t (b);")},
+		"0002_add_b.down.sql":    {Data: []byte("DROP INDEX idx_b;\nALTER TABLE t DROP COLUMN b;")},
+		"0003_broken.up.sql":     {Data: []byte("ALTER TABLE missing ADD COLUMN c TEXT;")},
+		"0003_broken.down.sql":   {Data: []byte("SELECT 1;")},
+	}
+
+	m, err := New(slog.Default(), db, fsys, SQLite)
+	require.NoError(t, err)
+
+	ctx := t.Context()
+
+	applied, err := m.Up(ctx)
+	require.ErrorContains(t, err, "migration 3_broken up, statement 1")
+	require.Len(t, applied, 2)
+
+	version, dirty, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), version)
+	require.True(t, dirty)
+
+	_, err = m.Up(ctx)
+	require.ErrorIs(t, err, ErrDirty)
+
+	// Fix by hand as the error message asks
+	_, err = db.Exec("DELETE FROM schema_migrations WHERE version = 3")
+	require.NoError(t, err)
+
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, "add_b", reverted[0].Name)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	require.NotNil(t, statuses[0].AppliedAt)
+	require.Nil(t, statuses[1].AppliedAt)
+	require.Nil(t, statuses[2].AppliedAt)
+
+	version, dirty, err = m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), version)
+	require.False(t, dirty)
+}
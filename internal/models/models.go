@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -11,4 +12,209 @@ type ExchangeRate struct {
 	Currency string          `json:"currency"`
 	Rate     decimal.Decimal `json:"rate"`
 	Date     time.Time       `json:"date"`
+	Source   string          `json:"source,omitempty"`
+}
+
+// HistoryQuery bounds a historical rates lookup. Zero values leave the corresponding bound open.
+type HistoryQuery struct {
+	From  time.Time // inclusive lower bound
+	To    time.Time // inclusive upper bound
+	After time.Time // exclusive lower bound, used as a keyset pagination cursor
+	Limit int       // maximum number of rows, 0 means unlimited
+
+	KnownAt time.Time // when set, rates and corrections stored after this instant are ignored
+}
+
+// SaveResult counts how a batch of saved rates compared with the stored ones, and how many the anomaly
+// checks flagged, held back or rejected
+type SaveResult struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Flagged   int `json:"flagged"`  // stored and recorded as anomalies
+	Held      int `json:"held"`     // not stored until approved
+	Rejected  int `json:"rejected"` // not stored
+
+	// Withheld are the rates held or rejected, left out of the rates stored
+	Withheld []ExchangeRate `json:"-"`
+}
+
+// RateRevision records one change of a stored rate. OldRate is null for the first value stored for the day.
+type RateRevision struct {
+	ID        int64               `json:"id"`
+	Currency  string              `json:"currency"`
+	Date      time.Time           `json:"date"`
+	OldRate   decimal.NullDecimal `json:"old_rate"`
+	NewRate   decimal.Decimal     `json:"new_rate"`
+	Source    string              `json:"source,omitempty"`
+	RunID     string              `json:"run_id,omitempty"`
+	RevisedAt time.Time           `json:"revised_at"`
+}
+
+// Kinds of fetch runs
+const (
+	FetchRunKindFetch    = "fetch"
+	FetchRunKindBackfill = "backfill"
+)
+
+// Fetch run outcomes
+const (
+	FetchRunRunning   = "running"   // started and not finished, or the process died mid-run
+	FetchRunSucceeded = "succeeded" // every requested currency was stored
+	FetchRunPartial   = "partial"   // some currencies failed, the others were stored
+	FetchRunFailed    = "failed"    // the run or every currency failed, the counts show what was stored before
+)
+
+// FetchRunStatuses lists every fetch run outcome
+var FetchRunStatuses = []string{FetchRunRunning, FetchRunSucceeded, FetchRunPartial, FetchRunFailed}
+
+// FetchRun is the journal entry of one fetch or backfill. Revisions written by the run carry its ID.
+type FetchRun struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Source     string     `json:"source,omitempty"`
+	SourceURL  string     `json:"source_url,omitempty"`
+	Currencies []string   `json:"currencies"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	SaveResult
+	Quarantined    int               `json:"quarantined"` // feed items rejected by validation
+	Error          string            `json:"error,omitempty"`
+	CurrencyErrors map[string]string `json:"currency_errors,omitempty"`
+}
+
+// QuarantinedItem is a feed item that failed parsing or validation. It is kept verbatim with the reason
+// instead of being stored as rates.
+type QuarantinedItem struct {
+	ID            int64     `json:"id"`
+	RunID         string    `json:"run_id,omitempty"`
+	Source        string    `json:"source"`
+	Item          string    `json:"item"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// RateDiscrepancy records sources quoting a rate further apart than the reconciliation tolerance allows,
+// and the value the consensus policy chose
+type RateDiscrepancy struct {
+	ID           int64                      `json:"id"`
+	RunID        string                     `json:"run_id,omitempty"`
+	Currency     string                     `json:"currency"`
+	Date         time.Time                  `json:"date"`
+	Quotes       map[string]decimal.Decimal `json:"quotes"` // rate by source
+	Chosen       decimal.Decimal            `json:"chosen"`
+	ChosenSource string                     `json:"chosen_source"`
+	Policy       string                     `json:"policy"`
+	Spread       decimal.Decimal            `json:"spread"` // (highest - lowest) / lowest
+	DetectedAt   time.Time                  `json:"detected_at"`
+}
+
+// Rate anomaly statuses
+const (
+	AnomalyFlagged  = "flagged"  // stored, recorded for review
+	AnomalyHeld     = "held"     // waiting for approval, not stored
+	AnomalyApproved = "approved" // held, then approved and stored
+	AnomalyRejected = "rejected" // never stored
+)
+
+// AnomalyStatuses lists every rate anomaly status
+var AnomalyStatuses = []string{AnomalyFlagged, AnomalyHeld, AnomalyApproved, AnomalyRejected}
+
+var (
+	ErrAnomalyNotFound = errors.New("rate anomaly not found")
+	ErrAnomalyNotHeld  = errors.New("rate anomaly is not held")
+)
+
+// RateAnomaly records a fetched rate that stood out from its currency's recent history
+type RateAnomaly struct {
+	ID         int64           `json:"id"`
+	RunID      string          `json:"run_id,omitempty"`
+	Currency   string          `json:"currency"`
+	Date       time.Time       `json:"date"`
+	Rate       decimal.Decimal `json:"rate"`
+	Source     string          `json:"source,omitempty"`
+	Status     string          `json:"status"`
+	Reason     string          `json:"reason"`
+	DetectedAt time.Time       `json:"detected_at"`
+	ResolvedAt *time.Time      `json:"resolved_at,omitempty"`
+}
+
+// AnomalyQuery selects rate anomalies, newest first. Zero values leave the corresponding filter open.
+type AnomalyQuery struct {
+	Status   string
+	Currency string
+	Limit    int // maximum number of anomalies, 0 means unlimited
+}
+
+// DiscrepancyQuery selects discrepancies, newest first. Zero values leave the corresponding filter open.
+type DiscrepancyQuery struct {
+	Currency string
+	Limit    int // maximum number of discrepancies, 0 means unlimited
+}
+
+// FetchRunQuery selects journal entries, newest first. Zero values leave the corresponding filter open.
+type FetchRunQuery struct {
+	Status string
+	Limit  int // maximum number of runs, 0 means unlimited
+}
+
+// Webhook subscription conditions
+const (
+	ConditionPublished = "published" // a rate was stored or revised
+	ConditionChange    = "change"    // a rate moved more than Threshold percent from the previous day's rate
+	ConditionCrossing  = "crossing"  // a rate crossed Threshold, from either side
+)
+
+// Conditions lists every webhook subscription condition
+var Conditions = []string{ConditionPublished, ConditionChange, ConditionCrossing}
+
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// Subscription registers a webhook notified when fetched rates meet its condition
+type Subscription struct {
+	ID         int64               `json:"id"`
+	URL        string              `json:"url"`
+	Currencies []string            `json:"currencies"` // empty for every currency
+	Condition  string              `json:"condition"`
+	Threshold  decimal.NullDecimal `json:"threshold"`        // percent for change, rate for crossing
+	Secret     string              `json:"secret,omitempty"` // HMAC key of the payload signature, only shown on creation
+	CreatedAt  time.Time           `json:"created_at"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"   // waiting for its first or next attempt
+	DeliveryDelivered = "delivered" // acknowledged with a 2xx response
+	DeliveryFailed    = "failed"    // out of attempts
+)
+
+// DeliveryStatuses lists every webhook delivery status
+var DeliveryStatuses = []string{DeliveryPending, DeliveryDelivered, DeliveryFailed}
+
+// WebhookDelivery is one notification sent, or to be sent, to a subscription
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	RunID          string     `json:"run_id,omitempty"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"` // HTTP status of the last attempt
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+
+	// URL and Secret are read from the subscription for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// DeliveryQuery selects webhook deliveries, newest first. Zero values leave the corresponding filter open.
+type DeliveryQuery struct {
+	SubscriptionID int64
+	Status         string
+	Limit          int // maximum number of deliveries, 0 means unlimited
 }
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// RecentRates returns up to limit stored rates of a currency dated on or before through, newest first
+func (r *Repository) RecentRates(ctx context.Context, currency string, through time.Time, limit int) ([]models.ExchangeRate, error) {
+	query := `SELECT currency, rate, date FROM exchange_rates WHERE currency = ? AND date <= ? ORDER BY date DESC LIMIT ?`
+
+	args := []any{currency, through.UTC(), limit}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to fetch recent rates", slog.String("currency", currency), slog.Any("error", err))
+
+		return nil, fmt.Errorf("fetch recent rates for %s: %w", currency, err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return r.scanRates(rows)
+}
+
+// RecordAnomalies stores the rates the anomaly checks singled out, attributed to the fetch run runID.
+// A rate recorded before with the same value is not recorded again, a rate held or rejected by one fetch
+// comes back with the next.
+func (r *Repository) RecordAnomalies(ctx context.Context, runID string, anomalies []models.RateAnomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint:errcheck // No-op once committed
+
+	now := r.now().UTC()
+
+	for _, a := range anomalies {
+		date := a.Date.UTC().Truncate(24 * time.Hour)
+
+		var seen int
+
+		query := `SELECT COUNT(*) FROM rate_anomalies WHERE currency = ? AND date = ? AND rate = ?`
+		if err := tx.QueryRowContext(ctx, r.rebind(query), r.bindArgs([]any{a.Currency, date, a.Rate})...).Scan(&seen); err != nil {
+			return fmt.Errorf("look up rate anomaly: %w", err)
+		}
+
+		if seen > 0 {
+			continue
+		}
+
+		query = `INSERT INTO rate_anomalies (run_id, currency, date, rate, source, status, reason, detected_at)
+                 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+		args := []any{sql.NullString{String: runID, Valid: runID != ""}, a.Currency, date, a.Rate, a.Source, a.Status, a.Reason, now}
+
+		if _, err := tx.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+			r.logger.Error("failed to record rate anomaly", slog.String("run_id", runID), slog.Any("error", err))
+
+			return fmt.Errorf("record rate anomaly: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rate anomalies: %w", err)
+	}
+
+	return nil
+}
+
+const selectAnomalies = `SELECT id, run_id, currency, date, rate, source, status, reason, detected_at, resolved_at
+              FROM rate_anomalies`
+
+// ListAnomalies returns recorded rate anomalies, most recent first
+func (r *Repository) ListAnomalies(ctx context.Context, q models.AnomalyQuery) ([]models.RateAnomaly, error) {
+	query := selectAnomalies + " WHERE 1 = 1"
+
+	var args []any
+
+	if q.Status != "" {
+		query += " AND status = ?"
+		args = append(args, q.Status)
+	}
+
+	if q.Currency != "" {
+		query += " AND currency = ?"
+		args = append(args, q.Currency)
+	}
+
+	query += " ORDER BY id DESC"
+
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to list rate anomalies", slog.Any("error", err))
+
+		return nil, fmt.Errorf("list rate anomalies: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return scanAnomalies(rows)
+}
+
+// ApproveAnomaly stores a held rate and marks its anomaly approved, in one transaction. The revision is attributed
+// to the fetch run that held the rate.
+func (r *Repository) ApproveAnomaly(ctx context.Context, id int64) (models.RateAnomaly, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateAnomaly{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint:errcheck // No-op once committed
+
+	rows, err := tx.QueryContext(ctx, r.rebind(selectAnomalies+" WHERE id = ? "+r.dialect.lockRows), id)
+	if err != nil {
+		return models.RateAnomaly{}, fmt.Errorf("fetch rate anomaly %d: %w", id, err)
+	}
+
+	found, err := scanAnomalies(rows)
+	rows.Close() // nolint:errcheck // Fully read already
+
+	switch {
+	case err != nil:
+		return models.RateAnomaly{}, err
+	case len(found) == 0:
+		return models.RateAnomaly{}, fmt.Errorf("%w: %d", models.ErrAnomalyNotFound, id)
+	case found[0].Status != models.AnomalyHeld:
+		return models.RateAnomaly{}, fmt.Errorf("%w: anomaly %d is %s", models.ErrAnomalyNotHeld, id, found[0].Status)
+	}
+
+	anomaly := found[0]
+	rate := models.ExchangeRate{Currency: anomaly.Currency, Rate: anomaly.Rate, Date: anomaly.Date, Source: anomaly.Source}
+
+	if _, err := r.saveRates(ctx, tx, anomaly.RunID, []models.ExchangeRate{rate}); err != nil {
+		return models.RateAnomaly{}, err
+	}
+
+	resolvedAt := r.now().UTC()
+
+	query := `UPDATE rate_anomalies SET status = ?, resolved_at = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, r.rebind(query), r.bindArgs([]any{models.AnomalyApproved, resolvedAt, id})...); err != nil {
+		return models.RateAnomaly{}, fmt.Errorf("approve rate anomaly %d: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.RateAnomaly{}, fmt.Errorf("commit approval: %w", err)
+	}
+
+	anomaly.Status = models.AnomalyApproved
+	anomaly.ResolvedAt = &resolvedAt
+
+	return anomaly, nil
+}
+
+func scanAnomalies(rows *sql.Rows) ([]models.RateAnomaly, error) {
+	var anomalies []models.RateAnomaly
+	for rows.Next() {
+		var (
+			a          models.RateAnomaly
+			runID      sql.NullString
+			resolvedAt sql.NullTime
+		)
+
+		if err := rows.Scan(&a.ID, &runID, &a.Currency, &a.Date, &a.Rate, &a.Source, &a.Status, &a.Reason,
+			&a.DetectedAt, &resolvedAt); err != nil {
+			return nil, fmt.Errorf("scan rate anomaly: %w", err)
+		}
+
+		a.RunID = runID.String
+
+		if resolvedAt.Valid {
+			a.ResolvedAt = &resolvedAt.Time
+		}
+
+		anomalies = append(anomalies, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return anomalies, nil
+}
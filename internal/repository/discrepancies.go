@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// SaveDiscrepancies stores rates the reconciled sources disagreed on, attributed to the fetch run runID
+func (r *Repository) SaveDiscrepancies(ctx context.Context, runID string, discrepancies []models.RateDiscrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+
+	now := r.now().UTC()
+
+	placeholders := make([]string, 0, len(discrepancies))
+	args := make([]any, 0, len(discrepancies)*9)
+
+	for _, d := range discrepancies {
+		quotes, err := json.Marshal(d.Quotes)
+		if err != nil {
+			return fmt.Errorf("encode quotes: %w", err)
+		}
+
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args,
+			sql.NullString{String: runID, Valid: runID != ""}, d.Currency, d.Date.UTC().Truncate(24*time.Hour),
+			string(quotes), d.Chosen, d.ChosenSource, d.Policy, d.Spread, now)
+	}
+
+	query := `INSERT INTO rate_discrepancies (run_id, currency, date, quotes, chosen, chosen_source, policy, spread, detected_at)
+              VALUES ` + strings.Join(placeholders, ",")
+
+	if _, err := r.db.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+		r.logger.Error("failed to save rate discrepancies", slog.String("run_id", runID), slog.Any("error", err))
+
+		return fmt.Errorf("save %d rate discrepancies: %w", len(discrepancies), err)
+	}
+
+	return nil
+}
+
+// ListDiscrepancies returns recorded rate discrepancies, most recent first
+func (r *Repository) ListDiscrepancies(ctx context.Context, q models.DiscrepancyQuery) ([]models.RateDiscrepancy, error) {
+	query := `SELECT id, run_id, currency, date, quotes, chosen, chosen_source, policy, spread, detected_at
+              FROM rate_discrepancies`
+
+	var args []any
+
+	if q.Currency != "" {
+		query += " WHERE currency = ?"
+		args = append(args, q.Currency)
+	}
+
+	query += " ORDER BY id DESC"
+
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to list rate discrepancies", slog.Any("error", err))
+
+		return nil, fmt.Errorf("list rate discrepancies: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	var discrepancies []models.RateDiscrepancy
+	for rows.Next() {
+		var (
+			d      models.RateDiscrepancy
+			runID  sql.NullString
+			quotes string
+		)
+
+		if err := rows.Scan(&d.ID, &runID, &d.Currency, &d.Date, &quotes, &d.Chosen, &d.ChosenSource, &d.Policy,
+			&d.Spread, &d.DetectedAt); err != nil {
+			return nil, fmt.Errorf("scan rate discrepancy: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(quotes), &d.Quotes); err != nil {
+			return nil, fmt.Errorf("decode quotes of rate discrepancy %d: %w", d.ID, err)
+		}
+
+		d.RunID = runID.String
+		discrepancies = append(discrepancies, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return discrepancies, nil
+}
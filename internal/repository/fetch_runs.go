@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// StartFetchRun journals a run as running. FinishFetchRun records its outcome, a run left running means the
+// process stopped before it finished.
+func (r *Repository) StartFetchRun(ctx context.Context, run models.FetchRun) error {
+	query := `INSERT INTO fetch_runs (id, kind, status, source, source_url, currencies, started_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	args := []any{run.ID, run.Kind, models.FetchRunRunning, run.Source, run.SourceURL, strings.Join(run.Currencies, ","), run.StartedAt.UTC()}
+
+	if _, err := r.db.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+		r.logger.Error("failed to start fetch run", slog.String("run_id", run.ID), slog.Any("error", err))
+
+		return fmt.Errorf("start fetch run %s: %w", run.ID, err)
+	}
+
+	return nil
+}
+
+// FinishFetchRun records the outcome of a run started with StartFetchRun
+func (r *Repository) FinishFetchRun(ctx context.Context, run models.FetchRun) error {
+	var finishedAt any
+	if run.FinishedAt != nil {
+		finishedAt = run.FinishedAt.UTC()
+	}
+
+	var currencyErrors sql.NullString
+	if len(run.CurrencyErrors) > 0 {
+		encoded, err := json.Marshal(run.CurrencyErrors)
+		if err != nil {
+			return fmt.Errorf("encode currency errors: %w", err)
+		}
+
+		currencyErrors = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	query := `UPDATE fetch_runs SET status = ?, source = ?, source_url = ?, finished_at = ?,
+              inserted = ?, updated = ?, unchanged = ?, flagged = ?, held = ?, rejected = ?, quarantined = ?,
+              error = ?, currency_errors = ?
+              WHERE id = ?`
+
+	args := []any{
+		run.Status, run.Source, run.SourceURL, finishedAt,
+		run.Inserted, run.Updated, run.Unchanged, run.Flagged, run.Held, run.Rejected, run.Quarantined,
+		sql.NullString{String: run.Error, Valid: run.Error != ""}, currencyErrors,
+		run.ID,
+	}
+
+	if _, err := r.db.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+		r.logger.Error("failed to finish fetch run", slog.String("run_id", run.ID), slog.Any("error", err))
+
+		return fmt.Errorf("finish fetch run %s: %w", run.ID, err)
+	}
+
+	return nil
+}
+
+// ListFetchRuns returns journaled runs, most recently started first
+func (r *Repository) ListFetchRuns(ctx context.Context, q models.FetchRunQuery) ([]models.FetchRun, error) {
+	query := `SELECT id, kind, status, source, source_url, currencies, started_at, finished_at,
+              inserted, updated, unchanged, flagged, held, rejected, quarantined, error, currency_errors
+              FROM fetch_runs`
+
+	var args []any
+
+	if q.Status != "" {
+		query += " WHERE status = ?"
+		args = append(args, q.Status)
+	}
+
+	query += " ORDER BY started_at DESC, id DESC"
+
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to list fetch runs", slog.Any("error", err))
+
+		return nil, fmt.Errorf("list fetch runs: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	var runs []models.FetchRun
+	for rows.Next() {
+		var (
+			run                    models.FetchRun
+			currencies             string
+			finishedAt             sql.NullTime
+			runErr, currencyErrors sql.NullString
+		)
+
+		if err := rows.Scan(&run.ID, &run.Kind, &run.Status, &run.Source, &run.SourceURL, &currencies,
+			&run.StartedAt, &finishedAt, &run.Inserted, &run.Updated, &run.Unchanged, &run.Flagged, &run.Held, &run.Rejected,
+			&run.Quarantined, &runErr, &currencyErrors); err != nil {
+			return nil, fmt.Errorf("scan fetch run: %w", err)
+		}
+
+		if currencies != "" {
+			run.Currencies = strings.Split(currencies, ",")
+		}
+
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+
+		run.Error = runErr.String
+
+		if currencyErrors.Valid {
+			if err := json.Unmarshal([]byte(currencyErrors.String), &run.CurrencyErrors); err != nil {
+				return nil, fmt.Errorf("decode currency errors of run %s: %w", run.ID, err)
+			}
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return runs, nil
+}
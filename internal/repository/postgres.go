@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+var postgresDialect = dialect{
+	name:           "postgres",
+	numberedParams: true,
+	upsertRates:    "ON CONFLICT (currency, date) DO UPDATE SET rate = EXCLUDED.rate",
+	lockRows:       "FOR UPDATE",
+	lockRevisions:  "SELECT id FROM revision_lock WHERE id = 1 FOR UPDATE",
+	returningID:    "RETURNING id",
+	latestRates: `SELECT DISTINCT ON (currency) currency, rate, date FROM exchange_rates
+              ORDER BY currency, date DESC`,
+	ratesBefore: `SELECT DISTINCT ON (currency) currency, rate, date FROM exchange_rates
+              WHERE date < ?
+              ORDER BY currency, date DESC`,
+}
+
+func NewPostgresRepository(cfg config.DatabaseConfig, logger *slog.Logger) (*Repository, error) {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     cfg.Name,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+
+	db, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	return newRepository(db, cfg, logger, postgresDialect)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// QuarantineItems stores feed items rejected by validation, attributed to the fetch run runID
+func (r *Repository) QuarantineItems(ctx context.Context, runID string, items []models.QuarantinedItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	now := r.now().UTC()
+
+	placeholders := make([]string, 0, len(items))
+	args := make([]any, 0, len(items)*5)
+
+	for _, item := range items {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
+		args = append(args, sql.NullString{String: runID, Valid: runID != ""}, item.Source, item.Item, item.Reason, now)
+	}
+
+	query := "INSERT INTO quarantined_items (run_id, source, item, reason, quarantined_at) VALUES " +
+		strings.Join(placeholders, ",")
+
+	if _, err := r.db.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+		r.logger.Error("failed to quarantine feed items", slog.String("run_id", runID), slog.Any("error", err))
+
+		return fmt.Errorf("quarantine %d feed items: %w", len(items), err)
+	}
+
+	return nil
+}
+
+// ListQuarantinedItems returns quarantined feed items, most recent first. limit 0 lists all of them.
+func (r *Repository) ListQuarantinedItems(ctx context.Context, limit int) ([]models.QuarantinedItem, error) {
+	query := `SELECT id, run_id, source, item, reason, quarantined_at FROM quarantined_items ORDER BY id DESC`
+
+	var args []any
+
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to list quarantined items", slog.Any("error", err))
+
+		return nil, fmt.Errorf("list quarantined items: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	var items []models.QuarantinedItem
+	for rows.Next() {
+		var (
+			item  models.QuarantinedItem
+			runID sql.NullString
+		)
+
+		if err := rows.Scan(&item.ID, &runID, &item.Source, &item.Item, &item.Reason, &item.QuarantinedAt); err != nil {
+			return nil, fmt.Errorf("scan quarantined item: %w", err)
+		}
+
+		item.RunID = runID.String
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return items, nil
+}
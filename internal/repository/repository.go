@@ -0,0 +1,647 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/sqlbind"
+	"github.com/shopspring/decimal"
+)
+
+// dialect holds the SQL that differs between database engines. Queries elsewhere are written with
+// ? placeholders and rebound for engines using numbered ones.
+type dialect struct {
+	name string
+
+	// numberedParams switches ? placeholders to $1, $2, ...
+	numberedParams bool
+
+	// upsertRates is appended to the multi-row exchange_rates insert
+	upsertRates string
+
+	// latestRates selects the newest rate of every currency ordered by currency
+	latestRates string
+
+	// ratesBefore selects, for every currency, the newest rate dated before the single parameter
+	ratesBefore string
+
+	// lockRows is appended to the select reading the stored rates a batch is about to overwrite
+	lockRows string
+
+	// lockRevisions is run before a transaction writes revisions. It serialises the writers so revision ids are
+	// handed out in commit order, which the rate stream relies on to read the log by id. Empty where transactions
+	// already take the write lock upfront.
+	lockRevisions string
+
+	// timeLayout, when set, passes time parameters as UTC text in this layout. Engines storing dates as
+	// text compare them lexically, so every value must be written in the same layout.
+	timeLayout string
+
+	// returningID is appended to inserts whose generated id is read back, for engines without LastInsertId
+	returningID string
+}
+
+// Repository stores exchange rates in a SQL database
+type Repository struct {
+	db      *sql.DB
+	logger  *slog.Logger
+	dialect dialect
+
+	now func() time.Time // stamps revisions, replaced in tests
+}
+
+// Open connects to the database engine selected by cfg.Driver
+func Open(cfg config.DatabaseConfig, logger *slog.Logger) (*Repository, error) {
+	switch cfg.Driver {
+	case config.DriverMariaDB:
+		return NewMariaDBRepository(cfg, logger)
+	case config.DriverPostgres:
+		return NewPostgresRepository(cfg, logger)
+	case config.DriverSQLite:
+		return NewSQLiteRepository(cfg, logger)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+func newRepository(db *sql.DB, cfg config.DatabaseConfig, logger *slog.Logger, d dialect) (*Repository, error) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnLifetime)
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	repoLogger := logger.With(
+		slog.String("component", "repository"),
+		slog.String("subsystem", d.name),
+	)
+
+	return &Repository{db: db, logger: repoLogger, dialect: d, now: time.Now}, nil
+}
+
+// rebind rewrites ? placeholders for the dialect
+func (r *Repository) rebind(query string) string {
+	if !r.dialect.numberedParams {
+		return query
+	}
+
+	return sqlbind.Numbered(query)
+}
+
+// bindArgs converts query parameters to the representation the dialect stores
+func (r *Repository) bindArgs(args []any) []any {
+	if r.dialect.timeLayout == "" {
+		return args
+	}
+
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC().Format(r.dialect.timeLayout)
+		}
+	}
+
+	return args
+}
+
+// execQuerier is implemented by both *sql.DB and *sql.Tx
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertID runs an insert and returns the id generated for the new row
+func (r *Repository) insertID(ctx context.Context, db execQuerier, query string, args []any) (int64, error) {
+	if r.dialect.returningID != "" {
+		var id int64
+		err := db.QueryRowContext(ctx, r.rebind(query+" "+r.dialect.returningID), r.bindArgs(args)...).Scan(&id)
+
+		return id, err
+	}
+
+	result, err := db.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *Repository) SaveRate(ctx context.Context, rate models.ExchangeRate) error {
+	_, err := r.SaveRates(ctx, "", []models.ExchangeRate{rate})
+
+	return err
+}
+
+// SaveRates upserts rates and records a revision for every rate that is new or differs from the stored value,
+// so overwrites stay auditable. runID ties the revisions to the fetch run that produced them and may be empty.
+// Rates equal to the stored ones are left untouched.
+func (r *Repository) SaveRates(ctx context.Context, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	rates = dedupeRates(rates)
+	if len(rates) == 0 {
+		return models.SaveResult{}, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.SaveResult{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint:errcheck // No-op once committed
+
+	result, err := r.saveRates(ctx, tx, runID, rates)
+	if err != nil {
+		return models.SaveResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.SaveResult{}, fmt.Errorf("commit rates: %w", err)
+	}
+
+	return result, nil
+}
+
+// maxStatementRows bounds the rows of a multi-row statement. At the 7 parameters of a revision it stays under
+// SQLite's default limit of 32,766 bound parameters, and well under the 65,535 of Postgres and MariaDB.
+const maxStatementRows = 4000
+
+// saveRates does the work of SaveRates within tx, rates must not hold the same currency and day twice. Large
+// batches such as a backfill are written in several statements of at most maxStatementRows rows.
+func (r *Repository) saveRates(ctx context.Context, tx *sql.Tx, runID string, rates []models.ExchangeRate) (models.SaveResult, error) {
+	var result models.SaveResult
+
+	if r.dialect.lockRevisions != "" {
+		if _, err := tx.ExecContext(ctx, r.dialect.lockRevisions); err != nil {
+			return result, fmt.Errorf("lock revisions: %w", err)
+		}
+	}
+
+	// Every revision of a save takes effect at the same instant
+	revisedAt := r.now().UTC()
+
+	for chunk := range slices.Chunk(rates, maxStatementRows) {
+		chunkResult, err := r.saveRatesChunk(ctx, tx, runID, revisedAt, chunk)
+		if err != nil {
+			return models.SaveResult{}, err
+		}
+
+		result.Inserted += chunkResult.Inserted
+		result.Updated += chunkResult.Updated
+		result.Unchanged += chunkResult.Unchanged
+	}
+
+	return result, nil
+}
+
+// saveRatesChunk saves at most maxStatementRows rates
+func (r *Repository) saveRatesChunk(
+	ctx context.Context, tx *sql.Tx, runID string, revisedAt time.Time, rates []models.ExchangeRate,
+) (models.SaveResult, error) {
+	var result models.SaveResult
+
+	stored, err := r.storedRates(ctx, tx, rates)
+	if err != nil {
+		r.logger.Error("failed to read stored rates", "count", len(rates), "err", err)
+
+		return result, err
+	}
+
+	var changed []models.ExchangeRate
+	var revisions []models.RateRevision
+
+	for _, rate := range rates {
+		old, ok := stored[keyOf(rate)]
+
+		switch {
+		case !ok:
+			result.Inserted++
+		case old.Equal(rate.Rate):
+			result.Unchanged++
+
+			continue
+		default:
+			result.Updated++
+		}
+
+		changed = append(changed, rate)
+		revisions = append(revisions, models.RateRevision{
+			Currency:  rate.Currency,
+			Date:      rate.Date,
+			OldRate:   decimal.NullDecimal{Decimal: old, Valid: ok},
+			NewRate:   rate.Rate,
+			Source:    rate.Source,
+			RunID:     runID,
+			RevisedAt: revisedAt,
+		})
+	}
+
+	if len(changed) == 0 {
+		return result, nil
+	}
+
+	if err := r.upsertRates(ctx, tx, changed); err != nil {
+		r.logger.Error("failed bulk upsert", "count", len(changed), "err", err)
+
+		return models.SaveResult{}, err
+	}
+
+	if err := r.insertRevisions(ctx, tx, revisions); err != nil {
+		r.logger.Error("failed to record rate revisions", "count", len(revisions), "err", err)
+
+		return models.SaveResult{}, err
+	}
+
+	return result, nil
+}
+
+type rateKey struct {
+	currency string
+	date     int64
+}
+
+func keyOf(rate models.ExchangeRate) rateKey {
+	return rateKey{currency: rate.Currency, date: rate.Date.Unix()}
+}
+
+// dedupeRates keeps the last of several rates for the same currency and day, a single upsert statement may
+// not touch a row twice
+func dedupeRates(rates []models.ExchangeRate) []models.ExchangeRate {
+	index := make(map[rateKey]int, len(rates))
+	deduped := make([]models.ExchangeRate, 0, len(rates))
+
+	for _, rate := range rates {
+		if i, ok := index[keyOf(rate)]; ok {
+			deduped[i] = rate
+
+			continue
+		}
+
+		index[keyOf(rate)] = len(deduped)
+		deduped = append(deduped, rate)
+	}
+
+	return deduped
+}
+
+// storedRates returns the current values of the given rates' rows, locking them until the transaction ends
+func (r *Repository) storedRates(ctx context.Context, tx *sql.Tx, rates []models.ExchangeRate) (map[rateKey]decimal.Decimal, error) {
+	placeholders := make([]string, 0, len(rates))
+	args := make([]any, 0, len(rates)*2)
+
+	for _, rate := range rates {
+		placeholders = append(placeholders, "(?, ?)")
+		args = append(args, rate.Currency, rate.Date)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT currency, rate, date FROM exchange_rates WHERE (currency, date) IN (%s) %s",
+		strings.Join(placeholders, ","),
+		r.dialect.lockRows,
+	)
+
+	rows, err := tx.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		return nil, fmt.Errorf("query stored rates: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	existing, err := r.scanRates(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[rateKey]decimal.Decimal, len(existing))
+	for _, rate := range existing {
+		stored[keyOf(rate)] = rate.Rate
+	}
+
+	return stored, nil
+}
+
+func (r *Repository) upsertRates(ctx context.Context, tx *sql.Tx, rates []models.ExchangeRate) error {
+	placeholders := make([]string, 0, len(rates))
+	args := make([]any, 0, len(rates)*3)
+
+	for _, rate := range rates {
+		placeholders = append(placeholders, "(?, ?, ?)")
+		args = append(args, rate.Currency, rate.Rate, rate.Date)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO exchange_rates (currency, rate, date) VALUES %s %s",
+		strings.Join(placeholders, ","),
+		r.dialect.upsertRates,
+	)
+
+	if _, err := tx.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+		return fmt.Errorf("upsert rates: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) insertRevisions(ctx context.Context, tx *sql.Tx, revisions []models.RateRevision) error {
+	placeholders := make([]string, 0, len(revisions))
+	args := make([]any, 0, len(revisions)*7)
+
+	for _, rev := range revisions {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, rev.Currency, rev.Date, rev.OldRate, rev.NewRate, rev.Source,
+			sql.NullString{String: rev.RunID, Valid: rev.RunID != ""}, rev.RevisedAt)
+	}
+
+	query := "INSERT INTO exchange_rate_revisions (currency, date, old_rate, new_rate, source, run_id, revised_at) VALUES " +
+		strings.Join(placeholders, ",")
+
+	if _, err := tx.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+		return fmt.Errorf("insert rate revisions: %w", err)
+	}
+
+	return nil
+}
+
+// knownRates shadows exchange_rates with the values stored at or before its single parameter, rebuilt from the
+// newest revision of every rate. Revisions are numbered in the order they were written, so the highest id is the
+// one in effect. Prefixed to a query reading exchange_rates, it answers the query as the service knew it then.
+const knownRates = `WITH exchange_rates AS (
+              SELECT currency, new_rate AS rate, date FROM exchange_rate_revisions
+              WHERE id IN (SELECT MAX(id) FROM exchange_rate_revisions WHERE revised_at <= ? GROUP BY currency, date)
+            ) `
+
+// knownAt rewrites a query reading exchange_rates to read the rates known at the given instant instead.
+// The zero time leaves the query reading the current rates.
+func knownAt(query string, args []any, at time.Time) (string, []any) {
+	if at.IsZero() {
+		return query, args
+	}
+
+	return knownRates + query, append([]any{at}, args...)
+}
+
+func (r *Repository) GetLatestRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	return r.GetLatestRatesKnownAt(ctx, time.Time{})
+}
+
+// GetLatestRatesKnownAt returns the latest rate of every currency as stored at the instant at, ignoring rates
+// and corrections written later. The zero time reads the current rates.
+func (r *Repository) GetLatestRatesKnownAt(ctx context.Context, at time.Time) ([]models.ExchangeRate, error) {
+	query, args := knownAt(r.dialect.latestRates, nil, at)
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to fetch latest rates", slog.Any("error", err))
+
+		return nil, fmt.Errorf("fetch latest rates: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return r.scanRates(rows)
+}
+
+// GetRatesAsOf returns, for every currency, the most recent rate published on or before the given date.
+// The Date of each returned rate is the day it was actually published, which may precede the requested one
+// on weekends and TARGET holidays.
+func (r *Repository) GetRatesAsOf(ctx context.Context, date time.Time) ([]models.ExchangeRate, error) {
+	return r.GetRatesAsOfKnownAt(ctx, date, time.Time{})
+}
+
+// GetRatesAsOfKnownAt is GetRatesAsOf answered with the rates stored at the instant at. The zero time reads the
+// current rates.
+func (r *Repository) GetRatesAsOfKnownAt(ctx context.Context, date, at time.Time) ([]models.ExchangeRate, error) {
+	// Dates are stored as midnight UTC, so anything before the start of the next day is "on or before"
+	endOfDay := date.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+
+	query, args := knownAt(r.dialect.ratesBefore, []any{endOfDay}, at)
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to fetch rates as of date",
+			slog.Time("date", date),
+			slog.Any("error", err))
+
+		return nil, fmt.Errorf("fetch rates as of %s: %w", date.Format(time.DateOnly), err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return r.scanRates(rows)
+}
+
+func (r *Repository) GetHistoricalRates(ctx context.Context, currency string) ([]models.ExchangeRate, error) {
+	return r.GetHistoricalRatesRange(ctx, currency, models.HistoryQuery{})
+}
+
+// GetHistoricalRatesRange returns the rates of a currency in ascending date order within the bounds of q.
+// The currency and date conditions are served by the unique (currency, date) index.
+func (r *Repository) GetHistoricalRatesRange(ctx context.Context, currency string, q models.HistoryQuery) ([]models.ExchangeRate, error) {
+	conditions := []string{"currency = ?"}
+	args := []any{currency}
+
+	if !q.From.IsZero() {
+		conditions = append(conditions, "date >= ?")
+		args = append(args, q.From)
+	}
+
+	if !q.To.IsZero() {
+		conditions = append(conditions, "date <= ?")
+		args = append(args, q.To)
+	}
+
+	if !q.After.IsZero() {
+		conditions = append(conditions, "date > ?")
+		args = append(args, q.After)
+	}
+
+	query := "SELECT currency, rate, date FROM exchange_rates WHERE " + strings.Join(conditions, " AND ") + " ORDER BY date ASC"
+
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	query, args = knownAt(query, args, q.KnownAt)
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to fetch historical rates",
+			slog.String("currency", currency),
+			slog.Any("error", err))
+
+		return nil, fmt.Errorf("fetch historical rates for %s: %w", currency, err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return r.scanRates(rows)
+}
+
+// GetRateRevisions returns every recorded value of a currency's rate for the given day, oldest first
+func (r *Repository) GetRateRevisions(ctx context.Context, currency string, date time.Time) ([]models.RateRevision, error) {
+	query := `SELECT id, currency, date, old_rate, new_rate, source, run_id, revised_at FROM exchange_rate_revisions
+              WHERE currency = ? AND date = ? ORDER BY id`
+
+	args := []any{currency, date.UTC().Truncate(24 * time.Hour)}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to fetch rate revisions",
+			slog.String("currency", currency),
+			slog.Time("date", date),
+			slog.Any("error", err))
+
+		return nil, fmt.Errorf("fetch rate revisions for %s: %w", currency, err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return scanRevisions(rows)
+}
+
+// RunRevisions returns the revisions a fetch or backfill run made, in the order it made them
+func (r *Repository) RunRevisions(ctx context.Context, runID string) ([]models.RateRevision, error) {
+	query := `SELECT id, currency, date, old_rate, new_rate, source, run_id, revised_at FROM exchange_rate_revisions
+              WHERE run_id = ? ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), runID)
+	if err != nil {
+		r.logger.Error("failed to fetch run revisions", slog.String("run_id", runID), slog.Any("error", err))
+
+		return nil, fmt.Errorf("fetch revisions of run %s: %w", runID, err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return scanRevisions(rows)
+}
+
+// RevisionsAfter returns up to limit revisions with an id above afterID, oldest first. Revision writers are
+// serialised, so a revision committed later never has a lower id than one already read.
+func (r *Repository) RevisionsAfter(ctx context.Context, afterID int64, limit int) ([]models.RateRevision, error) {
+	query := `SELECT id, currency, date, old_rate, new_rate, source, run_id, revised_at FROM exchange_rate_revisions
+              WHERE id > ? ORDER BY id LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), afterID, limit)
+	if err != nil {
+		r.logger.Error("failed to fetch revisions", slog.Int64("after_id", afterID), slog.Any("error", err))
+
+		return nil, fmt.Errorf("fetch revisions after %d: %w", afterID, err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return scanRevisions(rows)
+}
+
+// LatestRevisionID returns the id of the newest revision, 0 when no rate has been stored
+func (r *Repository) LatestRevisionID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM exchange_rate_revisions`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("fetch latest revision id: %w", err)
+	}
+
+	return id, nil
+}
+
+func scanRevisions(rows *sql.Rows) ([]models.RateRevision, error) {
+	var revisions []models.RateRevision
+	for rows.Next() {
+		var rev models.RateRevision
+		var runID sql.NullString
+
+		if err := rows.Scan(&rev.ID, &rev.Currency, &rev.Date, &rev.OldRate, &rev.NewRate, &rev.Source, &runID, &rev.RevisedAt); err != nil {
+			return nil, fmt.Errorf("scan rate revision: %w", err)
+		}
+
+		rev.RunID = runID.String
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// scanRates reads (currency, rate, date) rows into exchange rates
+func (r *Repository) scanRates(rows *sql.Rows) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.Date); err != nil {
+			r.logger.Error("failed to scan rate row", slog.Any("error", err))
+
+			return nil, fmt.Errorf("scan rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return rates, nil
+}
+
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+// Ping checks that the database is reachable
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// NewestRateDate returns the date of the most recent stored rate, the zero time when the table is empty
+func (r *Repository) NewestRateDate(ctx context.Context) (time.Time, error) {
+	var newest any
+
+	if err := r.db.QueryRowContext(ctx, "SELECT MAX(date) FROM exchange_rates").Scan(&newest); err != nil {
+		return time.Time{}, fmt.Errorf("query newest rate date: %w", err)
+	}
+
+	return r.parseTime(newest)
+}
+
+// parseTime reads a time from a result column. Aggregates lose the column type on engines storing dates as
+// text, so the driver hands those back as strings.
+func (r *Repository) parseTime(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	case string:
+		return r.parseTimeText(v)
+	case []byte:
+		return r.parseTimeText(string(v))
+	default:
+		return time.Time{}, fmt.Errorf("unexpected time value of type %T", v)
+	}
+}
+
+func (r *Repository) parseTimeText(s string) (time.Time, error) {
+	layout := r.dialect.timeLayout
+	if layout == "" {
+		layout = time.DateTime
+	}
+
+	t, err := time.ParseInLocation(layout, s, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse time %q: %w", s, err)
+	}
+
+	return t, nil
+}
+
+// Stats returns the connection pool statistics
+func (r *Repository) Stats() sql.DBStats {
+	return r.db.Stats()
+}
+
+// DB exposes the underlying connection pool for schema migrations
+func (r *Repository) DB() *sql.DB {
+	return r.db
+}
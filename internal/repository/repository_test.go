@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dialect  dialect
+		query    string
+		expected string
+	}{
+		{
+			name:     "MariaDB keeps question marks",
+			dialect:  mariaDBDialect,
+			query:    "SELECT currency FROM exchange_rates WHERE currency = ? AND date > ? LIMIT ?",
+			expected: "SELECT currency FROM exchange_rates WHERE currency = ? AND date > ? LIMIT ?",
+		},
+		{
+			name:     "Postgres numbers parameters",
+			dialect:  postgresDialect,
+			query:    "SELECT currency FROM exchange_rates WHERE currency = ? AND date > ? LIMIT ?",
+			expected: "SELECT currency FROM exchange_rates WHERE currency = $1 AND date > $2 LIMIT $3",
+		},
+		{
+			name:     "Postgres multi-row insert",
+			dialect:  postgresDialect,
+			query:    "INSERT INTO exchange_rates (currency, rate, date) VALUES (?, ?, ?),(?, ?, ?)",
+			expected: "INSERT INTO exchange_rates (currency, rate, date) VALUES ($1, $2, $3),($4, $5, $6)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Repository{dialect: tt.dialect}
+
+			require.Equal(t, tt.expected, r.rebind(tt.query))
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+var sqliteDialect = dialect{
+	name:        "sqlite",
+	upsertRates: "ON CONFLICT (currency, date) DO UPDATE SET rate = excluded.rate",
+	latestRates: `SELECT currency, rate, date FROM exchange_rates er1
+              WHERE date = (SELECT MAX(date) FROM exchange_rates er2 WHERE er1.currency = er2.currency)
+              ORDER BY currency`,
+	ratesBefore: `SELECT er.currency, er.rate, er.date FROM exchange_rates er
+              JOIN (SELECT currency, MAX(date) AS date FROM exchange_rates WHERE date < ? GROUP BY currency) latest
+                ON latest.currency = er.currency AND latest.date = er.date
+              ORDER BY er.currency`,
+	timeLayout: time.DateTime,
+}
+
+// NewSQLiteRepository opens, creating it if needed, the database file at cfg.Path.
+// The pure-Go driver keeps the binary free of CGO.
+func NewSQLiteRepository(cfg config.DatabaseConfig, logger *slog.Logger) (*Repository, error) {
+	// WAL lets readers proceed while the fetcher writes, busy_timeout makes concurrent writers wait instead of failing.
+	// Immediate transactions take the write lock upfront, which stands in for row locks when SaveRates reads the
+	// stored rates it is about to overwrite.
+	params := url.Values{
+		"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)", "foreign_keys(ON)"},
+		"_txlock": {"immediate"},
+	}
+
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	return newRepository(db, cfg, logger, sqliteDialect)
+}
@@ -0,0 +1,707 @@
+package repository
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/migrate"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/migrations"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepository returns a migrated SQLite repository in a temporary directory
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	repo, err := NewSQLiteRepository(config.DatabaseConfig{
+		Path:         filepath.Join(t.TempDir(), "rates.db"),
+		MaxOpenConns: 4,
+		MaxIdleConns: 4,
+	}, slog.Default())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, repo.Close()) })
+
+	fsys, err := migrations.ForDriver(config.DriverSQLite)
+	require.NoError(t, err)
+
+	migrator, err := migrate.New(slog.Default(), repo.DB(), fsys, migrate.SQLite)
+	require.NoError(t, err)
+
+	_, err = migrator.Up(t.Context())
+	require.NoError(t, err)
+
+	return repo
+}
+
+func day(d int) time.Time {
+	return time.Date(2026, 2, d, 0, 0, 0, 0, time.UTC)
+}
+
+func rate(currency, value string, date time.Time) models.ExchangeRate {
+	return models.ExchangeRate{Currency: currency, Rate: decimal.RequireFromString(value), Date: date}
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	newest, err := repo.NewestRateDate(ctx)
+	require.NoError(t, err)
+	require.True(t, newest.IsZero(), "empty table has no newest date")
+
+	result, err := repo.SaveRates(ctx, "", []models.ExchangeRate{
+		rate("USD", "1.1840", day(2)),
+		rate("USD", "1.1801", day(3)),
+		rate("GBP", "0.86580", day(2)),
+		rate("JPY", "183.59", day(2)),
+		rate("JPY", "183.92", day(3)),
+		rate("USD", "1.1790", day(6)),
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.SaveResult{Inserted: 6}, result)
+
+	// Upsert on (currency, date) keeps the exact decimal
+	require.NoError(t, repo.SaveRate(ctx, rate("USD", "1.18012345", day(3))))
+
+	t.Run("Latest rate per currency", func(t *testing.T) {
+		t.Parallel()
+
+		rates, err := repo.GetLatestRates(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{
+			rate("GBP", "0.8658", day(2)),
+			rate("JPY", "183.92", day(3)),
+			rate("USD", "1.179", day(6)),
+		}, rates)
+	})
+
+	t.Run("Rates as of a date fall back per currency", func(t *testing.T) {
+		t.Parallel()
+
+		rates, err := repo.GetRatesAsOf(ctx, day(4))
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{
+			rate("GBP", "0.8658", day(2)),
+			rate("JPY", "183.92", day(3)),
+			rate("USD", "1.18012345", day(3)),
+		}, rates)
+
+		rates, err = repo.GetRatesAsOf(ctx, day(1))
+		require.NoError(t, err)
+		require.Empty(t, rates)
+	})
+
+	t.Run("History range and pagination", func(t *testing.T) {
+		t.Parallel()
+
+		rates, err := repo.GetHistoricalRatesRange(ctx, "USD", models.HistoryQuery{From: day(3), To: day(6)})
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("USD", "1.18012345", day(3)), rate("USD", "1.179", day(6))}, rates)
+
+		rates, err = repo.GetHistoricalRatesRange(ctx, "USD", models.HistoryQuery{After: day(2), Limit: 1})
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("USD", "1.18012345", day(3))}, rates)
+
+		rates, err = repo.GetHistoricalRates(ctx, "CHF")
+		require.NoError(t, err)
+		require.Empty(t, rates)
+	})
+
+	t.Run("Newest rate date", func(t *testing.T) {
+		t.Parallel()
+
+		newest, err := repo.NewestRateDate(ctx)
+		require.NoError(t, err)
+		require.Equal(t, day(6), newest)
+	})
+}
+
+func TestSQLiteRepositoryRevisions(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	first := rate("USD", "1.1801", day(3))
+	first.Source = "banklv"
+
+	result, err := repo.SaveRates(ctx, "run-1", []models.ExchangeRate{first, rate("GBP", "0.8658", day(3))})
+	require.NoError(t, err)
+	require.Equal(t, models.SaveResult{Inserted: 2}, result)
+
+	// Only the corrected rate changes, the last of duplicate rates in a batch wins
+	corrected := rate("USD", "1.1805", day(3))
+	corrected.Source = "ecb"
+
+	result, err = repo.SaveRates(ctx, "run-2", []models.ExchangeRate{
+		rate("USD", "1.1799", day(3)),
+		corrected,
+		rate("GBP", "0.86580", day(3)),
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.SaveResult{Updated: 1, Unchanged: 1}, result)
+
+	revisions, err := repo.GetRateRevisions(ctx, "USD", day(3).Add(15*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+
+	require.Equal(t, "1.1801", revisions[0].NewRate.String())
+	require.False(t, revisions[0].OldRate.Valid, "first revision has no previous value")
+	require.Equal(t, "banklv", revisions[0].Source)
+	require.Equal(t, "run-1", revisions[0].RunID)
+
+	require.Equal(t, "1.1801", revisions[1].OldRate.Decimal.String())
+	require.Equal(t, "1.1805", revisions[1].NewRate.String())
+	require.Equal(t, "ecb", revisions[1].Source)
+	require.Equal(t, "run-2", revisions[1].RunID)
+	require.Equal(t, day(3), revisions[1].Date)
+	require.False(t, revisions[1].RevisedAt.Before(revisions[0].RevisedAt))
+
+	rates, err := repo.GetLatestRates(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []models.ExchangeRate{rate("GBP", "0.8658", day(3)), rate("USD", "1.1805", day(3))}, rates)
+
+	revisions, err = repo.GetRateRevisions(ctx, "USD", day(4))
+	require.NoError(t, err)
+	require.Empty(t, revisions)
+
+	// The revision log is read in id order by the rate stream
+	latest, err := repo.LatestRevisionID(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, latest)
+
+	revisions, err = repo.RevisionsAfter(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.EqualValues(t, 2, revisions[0].ID)
+	require.Equal(t, "GBP", revisions[0].Currency)
+	require.Equal(t, "1.1805", revisions[1].NewRate.String())
+
+	revisions, err = repo.RevisionsAfter(ctx, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+
+	revisions, err = repo.RevisionsAfter(ctx, latest, 10)
+	require.NoError(t, err)
+	require.Empty(t, revisions)
+}
+
+func TestSQLiteRepositoryOverlappingWriters(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	// The first writer stops inside its transaction until released
+	held := make(chan struct{})
+	release := make(chan struct{})
+
+	var calls atomic.Int32
+
+	repo.now = func() time.Time {
+		if calls.Add(1) == 1 {
+			close(held)
+			<-release
+		}
+
+		return day(3)
+	}
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := repo.SaveRates(ctx, "run-1", []models.ExchangeRate{rate("USD", "1.1801", day(3))})
+		first <- err
+	}()
+
+	<-held
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := repo.SaveRates(ctx, "run-2", []models.ExchangeRate{rate("GBP", "0.8658", day(3))})
+		second <- err
+	}()
+
+	// The second writer must not commit a revision ahead of the first
+	time.Sleep(100 * time.Millisecond)
+
+	revisions, err := repo.RevisionsAfter(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, revisions)
+
+	close(release)
+	require.NoError(t, <-first)
+	require.NoError(t, <-second)
+
+	revisions, err = repo.RevisionsAfter(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, "run-1", revisions[0].RunID)
+	require.Equal(t, "run-2", revisions[1].RunID)
+	require.Less(t, revisions[0].ID, revisions[1].ID)
+}
+
+func TestSQLiteRepositoryLargeBatch(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	// A backfill batch binds more parameters than one SQLite statement takes
+	currencies := []string{"USD", "GBP", "JPY", "CHF", "SEK", "NOK", "DKK", "PLN"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var rates []models.ExchangeRate
+	for d := range 1000 {
+		for i, currency := range currencies {
+			rates = append(rates, rate(currency, fmt.Sprintf("%d.%04d", i+1, d), start.AddDate(0, 0, d)))
+		}
+	}
+
+	require.Greater(t, len(rates)*7, 32766)
+
+	result, err := repo.SaveRates(ctx, "backfill-1", rates)
+	require.NoError(t, err)
+	require.Equal(t, models.SaveResult{Inserted: len(rates)}, result)
+
+	revisions, err := repo.RunRevisions(ctx, "backfill-1")
+	require.NoError(t, err)
+	require.Len(t, revisions, len(rates))
+	require.Equal(t, revisions[0].RevisedAt, revisions[len(revisions)-1].RevisedAt, "one save, one revision time")
+
+	// Saving it again spans the chunks as well
+	rates[len(rates)-1].Rate = decimal.RequireFromString("9.9999")
+
+	result, err = repo.SaveRates(ctx, "backfill-2", rates)
+	require.NoError(t, err)
+	require.Equal(t, models.SaveResult{Updated: 1, Unchanged: len(rates) - 1}, result)
+}
+
+func TestSQLiteRepositoryKnownAt(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	// Feb 3 rates are published that afternoon, USD is corrected the next morning when Feb 4 arrives
+	published := day(3).Add(16 * time.Hour)
+	corrected := day(4).Add(9 * time.Hour)
+
+	repo.now = func() time.Time { return published }
+
+	_, err := repo.SaveRates(ctx, "run-1", []models.ExchangeRate{
+		rate("USD", "1.1801", day(3)),
+		rate("GBP", "0.8658", day(3)),
+	})
+	require.NoError(t, err)
+
+	repo.now = func() time.Time { return corrected }
+
+	_, err = repo.SaveRates(ctx, "run-2", []models.ExchangeRate{
+		rate("USD", "1.1805", day(3)),
+		rate("USD", "1.1790", day(4)),
+	})
+	require.NoError(t, err)
+
+	between := published.Add(time.Hour)
+
+	t.Run("Latest", func(t *testing.T) {
+		t.Parallel()
+
+		rates, err := repo.GetLatestRatesKnownAt(ctx, between)
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("GBP", "0.8658", day(3)), rate("USD", "1.1801", day(3))}, rates)
+
+		rates, err = repo.GetLatestRatesKnownAt(ctx, published.Add(-time.Second))
+		require.NoError(t, err)
+		require.Empty(t, rates, "nothing was stored yet")
+
+		rates, err = repo.GetLatestRatesKnownAt(ctx, corrected)
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("GBP", "0.8658", day(3)), rate("USD", "1.179", day(4))}, rates)
+	})
+
+	t.Run("As of", func(t *testing.T) {
+		t.Parallel()
+
+		rates, err := repo.GetRatesAsOfKnownAt(ctx, day(4), between)
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("GBP", "0.8658", day(3)), rate("USD", "1.1801", day(3))}, rates)
+
+		rates, err = repo.GetRatesAsOf(ctx, day(3))
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("GBP", "0.8658", day(3)), rate("USD", "1.1805", day(3))}, rates)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		t.Parallel()
+
+		rates, err := repo.GetHistoricalRatesRange(ctx, "USD", models.HistoryQuery{KnownAt: between})
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("USD", "1.1801", day(3))}, rates)
+
+		rates, err = repo.GetHistoricalRatesRange(ctx, "USD", models.HistoryQuery{From: day(4), Limit: 1, KnownAt: corrected})
+		require.NoError(t, err)
+		require.Equal(t, []models.ExchangeRate{rate("USD", "1.179", day(4))}, rates)
+	})
+}
+
+func TestSQLiteRepositoryFetchRuns(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	started := time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC)
+	finished := started.Add(2 * time.Second)
+
+	failed := models.FetchRun{
+		ID:         "run-1",
+		Kind:       models.FetchRunKindFetch,
+		Currencies: []string{"USD", "XXX"},
+		StartedAt:  started,
+	}
+	require.NoError(t, repo.StartFetchRun(ctx, failed))
+
+	failed.Status = models.FetchRunPartial
+	failed.Source = "banklv"
+	failed.SourceURL = "https://www.bank.lv/vk/ecb_rss.xml"
+	failed.FinishedAt = &finished
+	failed.SaveResult = models.SaveResult{Inserted: 1}
+	failed.CurrencyErrors = map[string]string{"XXX": "rate not found for currency 'XXX'"}
+	require.NoError(t, repo.FinishFetchRun(ctx, failed))
+
+	running := models.FetchRun{
+		ID:        "run-2",
+		Kind:      models.FetchRunKindBackfill,
+		StartedAt: started.Add(time.Hour),
+	}
+	require.NoError(t, repo.StartFetchRun(ctx, running))
+
+	runs, err := repo.ListFetchRuns(ctx, models.FetchRunQuery{})
+	require.NoError(t, err)
+
+	running.Status = models.FetchRunRunning
+	require.Equal(t, []models.FetchRun{running, failed}, runs)
+
+	runs, err = repo.ListFetchRuns(ctx, models.FetchRunQuery{Status: models.FetchRunPartial, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, []models.FetchRun{failed}, runs)
+
+	runs, err = repo.ListFetchRuns(ctx, models.FetchRunQuery{Status: models.FetchRunFailed})
+	require.NoError(t, err)
+	require.Empty(t, runs)
+}
+
+func TestSQLiteRepositoryQuarantine(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	now := time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	run := models.FetchRun{ID: "run-1", Kind: models.FetchRunKindFetch, StartedAt: now}
+	require.NoError(t, repo.StartFetchRun(ctx, run))
+
+	rejected := []models.QuarantinedItem{
+		{Source: "banklv", Item: "<Item>AUD 1.683 USD</Item>", Reason: "odd number of fields (3)"},
+		{Source: "banklv", Item: "<Item>USD -1</Item>", Reason: "USD: rate -1 is not positive"},
+	}
+	require.NoError(t, repo.QuarantineItems(ctx, run.ID, rejected))
+	require.NoError(t, repo.QuarantineItems(ctx, run.ID, nil))
+
+	run.Status = models.FetchRunPartial
+	run.FinishedAt = &now
+	run.Quarantined = len(rejected)
+	require.NoError(t, repo.FinishFetchRun(ctx, run))
+
+	items, err := repo.ListQuarantinedItems(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, []models.QuarantinedItem{
+		{ID: 2, RunID: "run-1", Source: "banklv", Item: "<Item>USD -1</Item>", Reason: "USD: rate -1 is not positive", QuarantinedAt: now},
+		{ID: 1, RunID: "run-1", Source: "banklv", Item: "<Item>AUD 1.683 USD</Item>", Reason: "odd number of fields (3)", QuarantinedAt: now},
+	}, items)
+
+	items, err = repo.ListQuarantinedItems(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.EqualValues(t, 2, items[0].ID)
+
+	runs, err := repo.ListFetchRuns(ctx, models.FetchRunQuery{})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, 2, runs[0].Quarantined)
+}
+
+func TestSQLiteRepositoryDiscrepancies(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	now := time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	date := time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)
+
+	usd := models.RateDiscrepancy{
+		Currency: "USD",
+		Date:     date,
+		Quotes: map[string]decimal.Decimal{
+			"banklv": decimal.RequireFromString("1.18400000"),
+			"ecb":    decimal.RequireFromString("1.18010000"),
+		},
+		Chosen:       decimal.RequireFromString("1.1801"),
+		ChosenSource: "ecb",
+		Policy:       "majority",
+		Spread:       decimal.RequireFromString("0.00330480"),
+	}
+	gbp := usd
+	gbp.Currency = "GBP"
+
+	require.NoError(t, repo.SaveDiscrepancies(ctx, "run-1", []models.RateDiscrepancy{usd, gbp}))
+	require.NoError(t, repo.SaveDiscrepancies(ctx, "run-1", nil))
+
+	discrepancies, err := repo.ListDiscrepancies(ctx, models.DiscrepancyQuery{Currency: "USD"})
+	require.NoError(t, err)
+	require.Len(t, discrepancies, 1)
+
+	got := discrepancies[0]
+	require.EqualValues(t, 1, got.ID)
+	require.Equal(t, "run-1", got.RunID)
+	require.Equal(t, date, got.Date)
+	require.Equal(t, now, got.DetectedAt)
+	require.Equal(t, "ecb", got.ChosenSource)
+	require.True(t, got.Chosen.Equal(usd.Chosen))
+	require.True(t, got.Spread.Equal(usd.Spread))
+	require.Len(t, got.Quotes, 2)
+	require.True(t, got.Quotes["banklv"].Equal(usd.Quotes["banklv"]))
+
+	discrepancies, err = repo.ListDiscrepancies(ctx, models.DiscrepancyQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, discrepancies, 1)
+	require.Equal(t, "GBP", discrepancies[0].Currency)
+}
+
+func TestSQLiteRepositoryAnomalies(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	now := time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	_, err := repo.SaveRates(ctx, "run-1", []models.ExchangeRate{
+		rate("USD", "1.1801", day(1)), rate("USD", "1.1805", day(2)), rate("GBP", "0.8658", day(2)),
+	})
+	require.NoError(t, err)
+
+	recent, err := repo.RecentRates(ctx, "USD", day(5), 10)
+	require.NoError(t, err)
+	require.Equal(t, []models.ExchangeRate{rate("USD", "1.1805", day(2)), rate("USD", "1.1801", day(1))}, recent)
+
+	recent, err = repo.RecentRates(ctx, "USD", day(1), 10)
+	require.NoError(t, err)
+	require.Equal(t, []models.ExchangeRate{rate("USD", "1.1801", day(1))}, recent)
+
+	run := models.FetchRun{ID: "run-2", Kind: models.FetchRunKindFetch, StartedAt: now}
+	require.NoError(t, repo.StartFetchRun(ctx, run))
+
+	held := models.RateAnomaly{
+		Currency: "USD", Date: day(3), Rate: decimal.RequireFromString("1.32"), Source: "banklv",
+		Status: models.AnomalyHeld, Reason: "changed 11.82 % from 1.1805, above 10.00 %",
+	}
+	rejected := models.RateAnomaly{
+		Currency: "GBP", Date: day(3), Rate: decimal.RequireFromString("8.658"), Source: "banklv",
+		Status: models.AnomalyRejected, Reason: "changed 900.00 % from 0.8658, above 50.00 %",
+	}
+	require.NoError(t, repo.RecordAnomalies(ctx, run.ID, []models.RateAnomaly{held, rejected}))
+	// The same rates coming back with the next fetch are not recorded twice
+	require.NoError(t, repo.RecordAnomalies(ctx, "run-3", []models.RateAnomaly{held, rejected}))
+
+	run.Status = models.FetchRunSucceeded
+	run.FinishedAt = &now
+	run.Held, run.Rejected = 1, 1
+	require.NoError(t, repo.FinishFetchRun(ctx, run))
+
+	anomalies, err := repo.ListAnomalies(ctx, models.AnomalyQuery{})
+	require.NoError(t, err)
+	require.Len(t, anomalies, 2)
+	require.Equal(t, "GBP", anomalies[0].Currency)
+	require.Equal(t, "run-2", anomalies[1].RunID)
+	require.Equal(t, day(3), anomalies[1].Date)
+	require.Equal(t, now, anomalies[1].DetectedAt)
+	require.Nil(t, anomalies[1].ResolvedAt)
+
+	anomalies, err = repo.ListAnomalies(ctx, models.AnomalyQuery{Status: models.AnomalyHeld, Currency: "USD", Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, anomalies, 1)
+	require.EqualValues(t, 1, anomalies[0].ID)
+
+	approved, err := repo.ApproveAnomaly(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, models.AnomalyApproved, approved.Status)
+	require.Equal(t, now, *approved.ResolvedAt)
+
+	rates, err := repo.GetHistoricalRates(ctx, "USD")
+	require.NoError(t, err)
+	require.Len(t, rates, 3)
+	require.True(t, decimal.RequireFromString("1.32").Equal(rates[2].Rate), rates[2].Rate.String())
+
+	revisions, err := repo.GetRateRevisions(ctx, "USD", day(3))
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	require.Equal(t, "run-2", revisions[0].RunID, "attributed to the run that held the rate")
+
+	anomalies, err = repo.ListAnomalies(ctx, models.AnomalyQuery{Status: models.AnomalyApproved})
+	require.NoError(t, err)
+	require.Len(t, anomalies, 1)
+	require.Equal(t, now, *anomalies[0].ResolvedAt)
+
+	_, err = repo.ApproveAnomaly(ctx, 1)
+	require.ErrorIs(t, err, models.ErrAnomalyNotHeld)
+
+	_, err = repo.ApproveAnomaly(ctx, 2)
+	require.ErrorIs(t, err, models.ErrAnomalyNotHeld, "rejected rates cannot be approved")
+
+	_, err = repo.ApproveAnomaly(ctx, 42)
+	require.ErrorIs(t, err, models.ErrAnomalyNotFound)
+
+	runs, err := repo.ListFetchRuns(ctx, models.FetchRunQuery{})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, 1, runs[0].Held)
+	require.Equal(t, 1, runs[0].Rejected)
+	require.Zero(t, runs[0].Flagged)
+}
+
+func TestSQLiteRepositoryWebhooks(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestRepository(t)
+	ctx := t.Context()
+
+	now := time.Date(2026, 2, 3, 16, 15, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	_, err := repo.SaveRates(ctx, "run-1", []models.ExchangeRate{rate("USD", "1.1801", day(3)), rate("GBP", "0.8658", day(3))})
+	require.NoError(t, err)
+
+	_, err = repo.SaveRates(ctx, "run-2", []models.ExchangeRate{rate("USD", "1.1805", day(3)), rate("GBP", "0.8658", day(3))})
+	require.NoError(t, err)
+
+	revisions, err := repo.RunRevisions(ctx, "run-2")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1, "unchanged rates are not revised")
+	require.Equal(t, "1.1805", revisions[0].NewRate.String())
+	require.Equal(t, "1.1801", revisions[0].OldRate.Decimal.String())
+
+	treasury, err := repo.CreateSubscription(ctx, models.Subscription{
+		URL:        "https://treasury.example/hooks/rates",
+		Currencies: []string{"USD", "GBP"},
+		Condition:  models.ConditionChange,
+		Threshold:  decimal.NewNullDecimal(decimal.RequireFromString("0.5")),
+		Secret:     "treasury-secret-1",
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, treasury.ID)
+	require.Equal(t, now, treasury.CreatedAt)
+
+	everything, err := repo.CreateSubscription(ctx, models.Subscription{
+		URL:       "https://ops.example/hook",
+		Condition: models.ConditionPublished,
+		Secret:    "ops-secret-123456",
+	})
+	require.NoError(t, err)
+
+	subs, err := repo.ListSubscriptions(ctx)
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	require.Equal(t, []string{"USD", "GBP"}, subs[0].Currencies)
+	require.Equal(t, "0.5", subs[0].Threshold.Decimal.String())
+	require.Empty(t, subs[0].Secret, "secrets are not listed")
+	require.Equal(t, []string{}, subs[1].Currencies)
+	require.False(t, subs[1].Threshold.Valid)
+
+	require.NoError(t, repo.EnqueueDeliveries(ctx, []models.WebhookDelivery{
+		{SubscriptionID: treasury.ID, RunID: "run-2", Event: "rates.change", Payload: `{"rates":[]}`},
+		{SubscriptionID: everything.ID, RunID: "run-2", Event: "rates.published", Payload: `{"rates":[]}`},
+	}))
+
+	claimed, err := repo.ClaimDeliveries(ctx, time.Minute, 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, "https://treasury.example/hooks/rates", claimed[0].URL)
+	require.Equal(t, "treasury-secret-1", claimed[0].Secret)
+	require.Equal(t, models.DeliveryPending, claimed[0].Status)
+
+	// A claimed delivery is not handed out again while its lease lasts
+	claimed, err = repo.ClaimDeliveries(ctx, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.EqualValues(t, 2, claimed[0].ID)
+
+	claimed, err = repo.ClaimDeliveries(ctx, time.Minute, 10)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	delivered := now.Add(time.Second)
+	require.NoError(t, repo.RecordDeliveryAttempt(ctx, models.WebhookDelivery{
+		ID: 1, Status: models.DeliveryDelivered, Attempts: 1, ResponseStatus: 204, NextAttemptAt: now, DeliveredAt: &delivered,
+	}))
+	require.NoError(t, repo.RecordDeliveryAttempt(ctx, models.WebhookDelivery{
+		ID: 2, Status: models.DeliveryPending, Attempts: 1, ResponseStatus: 503, LastError: "unexpected response status",
+		NextAttemptAt: now.Add(30 * time.Second),
+	}))
+
+	deliveries, err := repo.ListDeliveries(ctx, models.DeliveryQuery{SubscriptionID: treasury.ID})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, models.DeliveryDelivered, deliveries[0].Status)
+	require.Equal(t, 204, deliveries[0].ResponseStatus)
+	require.Equal(t, delivered, *deliveries[0].DeliveredAt)
+	require.Equal(t, "run-2", deliveries[0].RunID)
+	require.Equal(t, now, deliveries[0].CreatedAt)
+
+	deliveries, err = repo.ListDeliveries(ctx, models.DeliveryQuery{Status: models.DeliveryPending, Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, "unexpected response status", deliveries[0].LastError)
+	require.Nil(t, deliveries[0].DeliveredAt)
+
+	// The retry is due once its backoff has passed, a run claims only its own deliveries
+	now = now.Add(time.Minute)
+
+	require.NoError(t, repo.EnqueueDeliveries(ctx, []models.WebhookDelivery{
+		{SubscriptionID: everything.ID, RunID: "run-3", Event: "rates.published", Payload: `{"rates":[]}`},
+	}))
+
+	claimed, err = repo.ClaimRunDeliveries(ctx, "run-3", time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.EqualValues(t, 3, claimed[0].ID)
+
+	claimed, err = repo.ClaimDeliveries(ctx, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.EqualValues(t, 2, claimed[0].ID)
+
+	require.NoError(t, repo.DeleteSubscription(ctx, everything.ID))
+	require.ErrorIs(t, repo.DeleteSubscription(ctx, everything.ID), models.ErrSubscriptionNotFound)
+
+	deliveries, err = repo.ListDeliveries(ctx, models.DeliveryQuery{})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "the delivery log goes with its subscription")
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// CreateSubscription registers a webhook subscription and returns it with its id
+func (r *Repository) CreateSubscription(ctx context.Context, sub models.Subscription) (models.Subscription, error) {
+	sub.CreatedAt = r.now().UTC()
+
+	query := `INSERT INTO webhook_subscriptions (url, currencies, condition_type, threshold, secret, created_at)
+              VALUES (?, ?, ?, ?, ?, ?)`
+
+	args := []any{sub.URL, strings.Join(sub.Currencies, ","), sub.Condition, sub.Threshold, sub.Secret, sub.CreatedAt}
+
+	id, err := r.insertID(ctx, r.db, query, args)
+	if err != nil {
+		r.logger.Error("failed to create subscription", slog.Any("error", err))
+
+		return models.Subscription{}, fmt.Errorf("create subscription: %w", err)
+	}
+
+	sub.ID = id
+
+	return sub, nil
+}
+
+// ListSubscriptions returns every webhook subscription, oldest first. Secrets are left out.
+func (r *Repository) ListSubscriptions(ctx context.Context) ([]models.Subscription, error) {
+	query := `SELECT id, url, currencies, condition_type, threshold, created_at FROM webhook_subscriptions ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("failed to list subscriptions", slog.Any("error", err))
+
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	var subs []models.Subscription
+	for rows.Next() {
+		var (
+			sub        models.Subscription
+			currencies string
+		)
+
+		if err := rows.Scan(&sub.ID, &sub.URL, &currencies, &sub.Condition, &sub.Threshold, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan subscription: %w", err)
+		}
+
+		sub.Currencies = []string{}
+		if currencies != "" {
+			sub.Currencies = strings.Split(currencies, ",")
+		}
+
+		subs = append(subs, sub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return subs, nil
+}
+
+// DeleteSubscription removes a subscription together with its delivery log
+func (r *Repository) DeleteSubscription(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, r.rebind(`DELETE FROM webhook_subscriptions WHERE id = ?`), id)
+	if err != nil {
+		r.logger.Error("failed to delete subscription", slog.Int64("id", id), slog.Any("error", err))
+
+		return fmt.Errorf("delete subscription %d: %w", id, err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %d", models.ErrSubscriptionNotFound, id)
+	}
+
+	return nil
+}
+
+// EnqueueDeliveries stores new deliveries as pending, due right away
+func (r *Repository) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint:errcheck // No-op once committed
+
+	now := r.now().UTC()
+
+	query := `INSERT INTO webhook_deliveries
+                  (subscription_id, run_id, event, payload, status, attempts, last_error, next_attempt_at, created_at)
+              VALUES (?, ?, ?, ?, ?, 0, '', ?, ?)`
+
+	for _, d := range deliveries {
+		args := []any{d.SubscriptionID, sql.NullString{String: d.RunID, Valid: d.RunID != ""}, d.Event, d.Payload,
+			models.DeliveryPending, now, now}
+
+		if _, err := tx.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+			r.logger.Error("failed to enqueue webhook delivery",
+				slog.Int64("subscription_id", d.SubscriptionID),
+				slog.Any("error", err))
+
+			return fmt.Errorf("enqueue webhook delivery: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+const selectDeliveries = `SELECT d.id, d.subscription_id, d.run_id, d.event, d.payload, d.status, d.attempts,
+                     d.response_status, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, s.url, s.secret
+              FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id`
+
+// ClaimDeliveries returns up to limit pending deliveries that are due and puts their next attempt off by lease,
+// so a concurrent sender does not pick them up while they are being sent
+func (r *Repository) ClaimDeliveries(ctx context.Context, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	return r.claimDeliveries(ctx, lease, limit, "")
+}
+
+// ClaimRunDeliveries is ClaimDeliveries limited to the deliveries of fetch run runID
+func (r *Repository) ClaimRunDeliveries(ctx context.Context, runID string, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	return r.claimDeliveries(ctx, lease, limit, runID)
+}
+
+func (r *Repository) claimDeliveries(ctx context.Context, lease time.Duration, limit int, runID string) ([]models.WebhookDelivery, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback() // nolint:errcheck // No-op once committed
+
+	now := r.now().UTC()
+
+	query := selectDeliveries + ` WHERE d.status = ? AND d.next_attempt_at <= ?`
+	args := []any{models.DeliveryPending, now}
+
+	if runID != "" {
+		query += ` AND d.run_id = ?`
+		args = append(args, runID)
+	}
+
+	query += ` ORDER BY d.next_attempt_at, d.id LIMIT ? ` + r.dialect.lockRows
+	args = append(args, limit)
+
+	rows, err := tx.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to claim webhook deliveries", slog.Any("error", err))
+
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	rows.Close() // nolint:errcheck // Fully read already
+
+	if err != nil {
+		return nil, err
+	}
+
+	leaseUntil := now.Add(lease)
+
+	for i := range deliveries {
+		query := `UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, r.rebind(query), r.bindArgs([]any{leaseUntil, deliveries[i].ID})...); err != nil {
+			return nil, fmt.Errorf("claim webhook delivery %d: %w", deliveries[i].ID, err)
+		}
+
+		deliveries[i].NextAttemptAt = leaseUntil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit claimed deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordDeliveryAttempt stores the outcome of an attempt: the status, the attempt count, the response and when the
+// next attempt is due
+func (r *Repository) RecordDeliveryAttempt(ctx context.Context, d models.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries
+              SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?, delivered_at = ?
+              WHERE id = ?`
+
+	var deliveredAt any
+	if d.DeliveredAt != nil {
+		deliveredAt = d.DeliveredAt.UTC()
+	}
+
+	args := []any{d.Status, d.Attempts, d.ResponseStatus, d.LastError, d.NextAttemptAt.UTC(), deliveredAt, d.ID}
+
+	if _, err := r.db.ExecContext(ctx, r.rebind(query), r.bindArgs(args)...); err != nil {
+		r.logger.Error("failed to record webhook delivery attempt", slog.Int64("id", d.ID), slog.Any("error", err))
+
+		return fmt.Errorf("record webhook delivery %d: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+// ListDeliveries returns the delivery log, most recent first
+func (r *Repository) ListDeliveries(ctx context.Context, q models.DeliveryQuery) ([]models.WebhookDelivery, error) {
+	query := selectDeliveries + " WHERE 1 = 1"
+
+	var args []any
+
+	if q.SubscriptionID != 0 {
+		query += " AND d.subscription_id = ?"
+		args = append(args, q.SubscriptionID)
+	}
+
+	if q.Status != "" {
+		query += " AND d.status = ?"
+		args = append(args, q.Status)
+	}
+
+	query += " ORDER BY d.id DESC"
+
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), r.bindArgs(args)...)
+	if err != nil {
+		r.logger.Error("failed to list webhook deliveries", slog.Any("error", err))
+
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck // We can't do much about a close error here
+
+	return scanDeliveries(rows)
+}
+
+func scanDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var (
+			d           models.WebhookDelivery
+			runID       sql.NullString
+			deliveredAt sql.NullTime
+		)
+
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &runID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &deliveredAt, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+
+		d.RunID = runID.String
+
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rows: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	// Embed the tz database so the scheduler works in minimal container images
+	_ "time/tzdata"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+// Job is the unit of work executed on every tick
+type Job func(ctx context.Context) error
+
+// retryAfterError is implemented by job errors that know when retrying can succeed at the earliest,
+// such as a fetch rejected by an open circuit breaker
+type retryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// Scheduler runs a Job on a cron schedule with random jitter, retries failed runs and never lets two runs overlap.
+type Scheduler struct {
+	logger      *slog.Logger
+	schedule    cron.Schedule
+	location    *time.Location
+	jitter      time.Duration
+	maxAttempts int
+	retryDelay  time.Duration
+	job         Job
+
+	running atomic.Bool
+	wg      sync.WaitGroup
+}
+
+func New(logger *slog.Logger, cfg config.SchedulerConfig, job Job) (*Scheduler, error) {
+	schedule, err := cron.ParseStandard(cfg.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("parse cron expression %q: %w", cfg.Cron, err)
+	}
+
+	location, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("load time zone %q: %w", cfg.TimeZone, err)
+	}
+
+	if cfg.MaxAttempts < 1 {
+		return nil, errors.New("max attempts must be at least 1")
+	}
+
+	s := &Scheduler{
+		logger:      logger,
+		schedule:    schedule,
+		location:    location,
+		jitter:      cfg.Jitter,
+		maxAttempts: cfg.MaxAttempts,
+		retryDelay:  cfg.RetryDelay,
+		job:         job,
+	}
+
+	s.logger = s.logger.With(slog.String("component", "scheduler"), slog.String("cron", cfg.Cron), slog.String("tz", cfg.TimeZone))
+
+	return s, nil
+}
+
+// Next returns the time of the next run after now, including jitter
+func (s *Scheduler) Next(now time.Time) time.Time {
+	next := s.schedule.Next(now.In(s.location))
+
+	if s.jitter > 0 {
+		next = next.Add(rand.N(s.jitter))
+	}
+
+	return next
+}
+
+// Run blocks until ctx is cancelled, triggering the job on every scheduled tick.
+// It waits for an in-flight run to finish before returning.
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+
+	for {
+		next := s.Next(time.Now())
+		s.logger.Info("next run scheduled", slog.Time("at", next))
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		case <-timer.C:
+			s.wg.Add(1)
+
+			go func() {
+				defer s.wg.Done()
+
+				s.Trigger(ctx)
+			}()
+		}
+	}
+}
+
+// Trigger executes the job immediately, retrying with exponential backoff on failure. A retry is put off
+// further when the error says it cannot succeed earlier.
+// It returns false without running the job when a previous run is still in progress.
+func (s *Scheduler) Trigger(ctx context.Context) bool {
+	if !s.running.CompareAndSwap(false, true) {
+		s.logger.Warn("previous run still in progress, skipping")
+
+		return false
+	}
+	defer s.running.Store(false)
+
+	delay := s.retryDelay
+
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		start := time.Now()
+
+		err := s.job(ctx)
+		if err == nil {
+			s.logger.Info("run succeeded", slog.Int("attempt", attempt), slog.Duration("duration", time.Since(start)))
+
+			return true
+		}
+
+		if attempt == s.maxAttempts {
+			s.logger.Error("run failed, giving up", slog.Int("attempt", attempt), slog.Any("error", err))
+
+			return true
+		}
+
+		wait := delay
+
+		var retryAfterErr retryAfterError
+		if errors.As(err, &retryAfterErr) {
+			wait = max(wait, retryAfterErr.RetryAfter())
+		}
+
+		s.logger.Warn("run failed, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("retry_in", wait),
+			slog.Any("error", err))
+
+		select {
+		case <-ctx.Done():
+			return true
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+	}
+
+	return true
+}
@@ -0,0 +1,223 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() config.SchedulerConfig {
+	return config.SchedulerConfig{
+		Cron:        "15 16 * * MON-FRI",
+		TimeZone:    "CET",
+		MaxAttempts: 3,
+		RetryDelay:  time.Millisecond,
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		modify    func(cfg *config.SchedulerConfig)
+		expectErr string
+	}{
+		{
+			name:   "Valid configuration",
+			modify: func(cfg *config.SchedulerConfig) {},
+		},
+		{
+			name:      "Invalid cron expression",
+			modify:    func(cfg *config.SchedulerConfig) { cfg.Cron = "every day" },
+			expectErr: "parse cron expression",
+		},
+		{
+			name:      "Unknown time zone",
+			modify:    func(cfg *config.SchedulerConfig) { cfg.TimeZone = "Mars/Olympus" },
+			expectErr: "load time zone",
+		},
+		{
+			name:      "No attempts",
+			modify:    func(cfg *config.SchedulerConfig) { cfg.MaxAttempts = 0 },
+			expectErr: "max attempts must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := testConfig()
+			tt.modify(&cfg)
+
+			_, err := New(slog.Default(), cfg, func(ctx context.Context) error { return nil })
+
+			if tt.expectErr != "" {
+				require.ErrorContains(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+
+	cet, err := time.LoadLocation("CET")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		jitter   time.Duration
+		now      time.Time
+		expected time.Time
+	}{
+		{
+			name:     "Later the same weekday",
+			now:      time.Date(2026, 2, 6, 9, 0, 0, 0, cet), // Friday
+			expected: time.Date(2026, 2, 6, 16, 15, 0, 0, cet),
+		},
+		{
+			name:     "Skips the weekend",
+			now:      time.Date(2026, 2, 6, 17, 0, 0, 0, cet), // Friday
+			expected: time.Date(2026, 2, 9, 16, 15, 0, 0, cet),
+		},
+		{
+			name:     "Evaluated in the configured time zone",
+			now:      time.Date(2026, 2, 6, 15, 0, 0, 0, time.UTC), // 16:00 CET
+			expected: time.Date(2026, 2, 6, 16, 15, 0, 0, cet),
+		},
+		{
+			name:     "Jitter delays the run",
+			jitter:   time.Minute,
+			now:      time.Date(2026, 2, 6, 9, 0, 0, 0, cet),
+			expected: time.Date(2026, 2, 6, 16, 15, 0, 0, cet),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := testConfig()
+			cfg.Jitter = tt.jitter
+
+			s, err := New(slog.Default(), cfg, func(ctx context.Context) error { return nil })
+			require.NoError(t, err)
+
+			next := s.Next(tt.now)
+
+			require.False(t, next.Before(tt.expected), "next run %s before %s", next, tt.expected)
+			require.Less(t, next.Sub(tt.expected), tt.jitter+time.Nanosecond)
+		})
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		failures      int32
+		expectedCalls int32
+	}{
+		{
+			name:          "Succeeds first time",
+			failures:      0,
+			expectedCalls: 1,
+		},
+		{
+			name:          "Retries until success",
+			failures:      2,
+			expectedCalls: 3,
+		},
+		{
+			name:          "Gives up after max attempts",
+			failures:      10,
+			expectedCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+
+			s, err := New(slog.Default(), testConfig(), func(ctx context.Context) error {
+				if calls.Add(1) <= tt.failures {
+					return errors.New("bank.lv unavailable")
+				}
+
+				return nil
+			})
+			require.NoError(t, err)
+
+			require.True(t, s.Trigger(t.Context()))
+			require.Equal(t, tt.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestTriggerSkipsOverlappingRuns(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s, err := New(slog.Default(), testConfig(), func(ctx context.Context) error {
+		close(started)
+		<-release
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	done := make(chan bool)
+	go func() { done <- s.Trigger(t.Context()) }()
+
+	<-started
+	require.False(t, s.Trigger(t.Context()), "overlapping run must be skipped")
+
+	close(release)
+	require.True(t, <-done)
+}
+
+type retryAfterErr time.Duration
+
+func (e retryAfterErr) Error() string { return "circuit open" }
+
+func (e retryAfterErr) RetryAfter() time.Duration { return time.Duration(e) }
+
+func TestTriggerWaitsForRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	const wait = 50 * time.Millisecond
+
+	var calls []time.Time
+
+	s, err := New(slog.Default(), testConfig(), func(ctx context.Context) error {
+		calls = append(calls, time.Now())
+		if len(calls) == 1 {
+			return fmt.Errorf("fetch rates: %w", retryAfterErr(wait))
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.True(t, s.Trigger(t.Context()))
+	require.Len(t, calls, 2)
+	require.GreaterOrEqual(t, calls[1].Sub(calls[0]), wait, "retry must wait for the error's RetryAfter, not the 1ms retry delay")
+}
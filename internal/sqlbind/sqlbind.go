@@ -0,0 +1,26 @@
+// Package sqlbind rewrites the ? placeholders queries are written with for engines using numbered ones
+package sqlbind
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Numbered rewrites ? placeholders to $1, $2, ... Queries must not contain ? inside string literals.
+func Numbered(query string) string {
+	var b strings.Builder
+
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
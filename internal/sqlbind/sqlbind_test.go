@@ -0,0 +1,41 @@
+package sqlbind
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumbered(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "Numbers parameters",
+			query:    "SELECT currency FROM exchange_rates WHERE currency = ? AND date > ? LIMIT ?",
+			expected: "SELECT currency FROM exchange_rates WHERE currency = $1 AND date > $2 LIMIT $3",
+		},
+		{
+			name:     "Multi-row insert",
+			query:    "INSERT INTO exchange_rates (currency, rate, date) VALUES (?, ?, ?),(?, ?, ?)",
+			expected: "INSERT INTO exchange_rates (currency, rate, date) VALUES ($1, $2, $3),($4, $5, $6)",
+		},
+		{
+			name:     "No parameters",
+			query:    "SELECT MAX(version) FROM schema_migrations",
+			expected: "SELECT MAX(version) FROM schema_migrations",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, Numbered(tt.query))
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Package stream fans the rates stored in the database out to long-lived client connections. A single broker
+// polls the revision log, so rates stored by any process, a cron-run fetch as well as this server's scheduler,
+// reach every subscriber.
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+)
+
+// Store is what the broker needs from the database
+type Store interface {
+	LatestRevisionID(ctx context.Context) (int64, error)
+	RevisionsAfter(ctx context.Context, afterID int64, limit int) ([]models.RateRevision, error)
+}
+
+// ErrClosed is returned when subscribing to a broker that has stopped
+var ErrClosed = errors.New("rate stream closed")
+
+// pageSize is the number of revisions read from the database at once
+const pageSize = 500
+
+// subscriberBuffer is the number of batches a subscriber may fall behind before it is dropped
+const subscriberBuffer = 16
+
+// Broker polls the revision log and publishes new revisions to its subscribers
+type Broker struct {
+	logger   *slog.Logger
+	store    Store
+	interval time.Duration
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBroker(logger *slog.Logger, store Store, interval time.Duration) *Broker {
+	return &Broker{
+		logger:   logger.With(slog.String("component", "stream")),
+		store:    store,
+		interval: interval,
+		subs:     make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the revisions stored after it was made, in id order. C is closed when the broker stops
+// or when the subscriber fell too far behind; a client resumes from the last id it saw.
+type Subscription struct {
+	C <-chan []models.RateRevision
+
+	ch     chan []models.RateRevision
+	broker *Broker
+}
+
+// Subscribe registers a subscriber, which must be closed once it is done
+func (b *Broker) Subscribe() (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	ch := make(chan []models.RateRevision, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, broker: b}
+	b.subs[sub] = struct{}{}
+
+	metrics.StreamClients.Inc()
+
+	return sub, nil
+}
+
+// Close unregisters the subscriber
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.drop(s)
+}
+
+// drop removes a subscriber and closes its channel, b.mu must be held
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	delete(b.subs, sub)
+	close(sub.ch)
+
+	metrics.StreamClients.Dec()
+}
+
+// LatestRevisionID returns the id new subscribers start after when they do not resume
+func (b *Broker) LatestRevisionID(ctx context.Context) (int64, error) {
+	return b.store.LatestRevisionID(ctx)
+}
+
+// RevisionsAfter reads the revisions a resuming subscriber missed
+func (b *Broker) RevisionsAfter(ctx context.Context, afterID int64, limit int) ([]models.RateRevision, error) {
+	return b.store.RevisionsAfter(ctx, afterID, limit)
+}
+
+// Run polls the revision log every interval until ctx is done, then closes every subscription
+func (b *Broker) Run(ctx context.Context) error {
+	defer b.stop()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	var (
+		cursor int64
+		ready  bool
+	)
+
+	for {
+		var err error
+
+		// Revisions stored before the broker started are only sent to subscribers asking for them
+		if !ready {
+			cursor, err = b.store.LatestRevisionID(ctx)
+			ready = err == nil
+		} else {
+			cursor, err = b.poll(ctx, cursor)
+		}
+
+		if err != nil && ctx.Err() == nil {
+			b.logger.Error("failed to poll rate revisions", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll publishes the revisions after cursor and returns the id of the last one
+func (b *Broker) poll(ctx context.Context, cursor int64) (int64, error) {
+	for {
+		revisions, err := b.store.RevisionsAfter(ctx, cursor, pageSize)
+		if err != nil {
+			return cursor, err
+		}
+
+		if len(revisions) > 0 {
+			b.publish(revisions)
+			cursor = revisions[len(revisions)-1].ID
+		}
+
+		if len(revisions) < pageSize {
+			return cursor, nil
+		}
+	}
+}
+
+// publish hands a batch to every subscriber. A subscriber whose buffer is full is dropped rather than
+// holding up the others.
+func (b *Broker) publish(revisions []models.RateRevision) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- revisions:
+		default:
+			b.logger.Warn("dropping rate stream subscriber that fell behind")
+			b.drop(sub)
+		}
+	}
+}
+
+// stop closes every subscription and refuses new ones
+func (b *Broker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
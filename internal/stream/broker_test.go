@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore is an in-memory revision log
+type mockStore struct {
+	mu        sync.Mutex
+	revisions []models.RateRevision
+	polls     int
+}
+
+func (m *mockStore) add(currency, rate string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revisions = append(m.revisions, models.RateRevision{
+		ID:       int64(len(m.revisions) + 1),
+		Currency: currency,
+		NewRate:  decimal.RequireFromString(rate),
+	})
+}
+
+func (m *mockStore) LatestRevisionID(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return int64(len(m.revisions)), nil
+}
+
+func (m *mockStore) RevisionsAfter(ctx context.Context, afterID int64, limit int) ([]models.RateRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.polls++
+
+	var revisions []models.RateRevision
+	for _, rev := range m.revisions {
+		if rev.ID > afterID && len(revisions) < limit {
+			revisions = append(revisions, rev)
+		}
+	}
+
+	return revisions, nil
+}
+
+// startBroker runs a broker polling every millisecond until the test ends. It returns once the broker polls,
+// revisions added before are not published.
+func startBroker(t *testing.T, store *mockStore) (*Broker, context.CancelFunc, <-chan struct{}) {
+	t.Helper()
+
+	broker := NewBroker(slog.Default(), store, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_ = broker.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		return store.polls > 0
+	}, 5*time.Second, time.Millisecond)
+
+	return broker, cancel, done
+}
+
+func receive(t *testing.T, sub *Subscription) []models.RateRevision {
+	t.Helper()
+
+	select {
+	case revisions, ok := <-sub.C:
+		require.True(t, ok, "subscription closed")
+
+		return revisions
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no revisions published")
+
+		return nil
+	}
+}
+
+func TestBroker(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	store.add("USD", "1.1801") // stored before the broker started
+
+	broker, _, _ := startBroker(t, store)
+
+	first, err := broker.Subscribe()
+	require.NoError(t, err)
+
+	second, err := broker.Subscribe()
+	require.NoError(t, err)
+
+	store.add("USD", "1.1805")
+	store.add("GBP", "0.8658")
+
+	for _, sub := range []*Subscription{first, second} {
+		var ids []int64
+		for len(ids) < 2 {
+			for _, rev := range receive(t, sub) {
+				ids = append(ids, rev.ID)
+			}
+		}
+
+		require.Equal(t, []int64{2, 3}, ids)
+	}
+
+	second.Close()
+	second.Close() // closing twice is harmless
+
+	_, ok := <-second.C
+	require.False(t, ok)
+
+	store.add("JPY", "183.56")
+	require.EqualValues(t, 4, receive(t, first)[0].ID)
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	broker, _, _ := startBroker(t, store)
+
+	sub, err := broker.Subscribe()
+	require.NoError(t, err)
+
+	// Every batch is published as it is polled, the subscriber reads none of them
+	for i := range subscriberBuffer + 1 {
+		store.add("USD", "1.18")
+
+		require.Eventually(t, func() bool {
+			broker.mu.Lock()
+			defer broker.mu.Unlock()
+
+			_, subscribed := broker.subs[sub]
+
+			return len(sub.ch) == i+1 || !subscribed
+		}, 5*time.Second, time.Millisecond)
+	}
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+
+	require.Equal(t, subscriberBuffer, received, "the channel is closed once the buffer overflows")
+}
+
+func TestBrokerStop(t *testing.T) {
+	t.Parallel()
+
+	broker, cancel, done := startBroker(t, &mockStore{})
+
+	sub, err := broker.Subscribe()
+	require.NoError(t, err)
+
+	cancel()
+	<-done
+
+	_, ok := <-sub.C
+	require.False(t, ok, "open subscriptions are closed")
+
+	sub.Close()
+
+	_, err = broker.Subscribe()
+	require.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,83 @@
+// Package webhook notifies subscribers about the rates fetch runs store. Notifications are kept in a delivery log
+// and sent as HMAC-signed JSON, failed attempts are retried with exponential backoff.
+package webhook
+
+import (
+	"slices"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Event is the payload delivered to a subscription
+type Event struct {
+	Event          string              `json:"event"`
+	SubscriptionID int64               `json:"subscription_id"`
+	RunID          string              `json:"run_id"`
+	Condition      string              `json:"condition"`
+	Threshold      decimal.NullDecimal `json:"threshold"`
+	Rates          []RateChange        `json:"rates"`
+	CreatedAt      time.Time           `json:"created_at"`
+}
+
+// RateChange is a rate a fetch run stored, with the previous day's rate it is compared with
+type RateChange struct {
+	Currency      string              `json:"currency"`
+	Date          time.Time           `json:"date"`
+	Rate          decimal.Decimal     `json:"rate"`
+	PreviousRate  decimal.NullDecimal `json:"previous_rate"`  // null for the first rate of a currency
+	ChangePercent decimal.NullDecimal `json:"change_percent"` // from the previous rate
+	Revised       bool                `json:"revised"`        // replaces a value stored for the same day
+}
+
+// percentScale is the number of decimal places change percentages are rounded to
+const percentScale = 4
+
+func newRateChange(rev models.RateRevision, previous decimal.NullDecimal) RateChange {
+	change := RateChange{
+		Currency:     rev.Currency,
+		Date:         rev.Date,
+		Rate:         rev.NewRate,
+		PreviousRate: previous,
+		Revised:      rev.OldRate.Valid,
+	}
+
+	if previous.Valid && previous.Decimal.IsPositive() {
+		percent := rev.NewRate.Sub(previous.Decimal).Div(previous.Decimal).Shift(2).Round(percentScale)
+		change.ChangePercent = decimal.NewNullDecimal(percent)
+	}
+
+	return change
+}
+
+// eventName is the event a subscription's notifications are sent as
+func eventName(condition string) string {
+	return "rates." + condition
+}
+
+// matches reports whether a stored rate meets the subscription's currency filter and condition
+func matches(sub models.Subscription, change RateChange) bool {
+	if len(sub.Currencies) > 0 && !slices.Contains(sub.Currencies, change.Currency) {
+		return false
+	}
+
+	switch sub.Condition {
+	case models.ConditionPublished:
+		return true
+	case models.ConditionChange:
+		return sub.Threshold.Valid && change.ChangePercent.Valid &&
+			change.ChangePercent.Decimal.Abs().GreaterThan(sub.Threshold.Decimal)
+	case models.ConditionCrossing:
+		if !sub.Threshold.Valid || !change.PreviousRate.Valid {
+			return false
+		}
+
+		level, previous := sub.Threshold.Decimal, change.PreviousRate.Decimal
+
+		return previous.LessThan(level) && change.Rate.GreaterThanOrEqual(level) ||
+			previous.GreaterThan(level) && change.Rate.LessThanOrEqual(level)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,353 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/metrics"
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Store is what the notifier needs from the database
+type Store interface {
+	ListSubscriptions(ctx context.Context) ([]models.Subscription, error)
+	// RunRevisions returns the revisions a run made, which are the rates it stored
+	RunRevisions(ctx context.Context, runID string) ([]models.RateRevision, error)
+	RecentRates(ctx context.Context, currency string, through time.Time, limit int) ([]models.ExchangeRate, error)
+
+	EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	ClaimDeliveries(ctx context.Context, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	ClaimRunDeliveries(ctx context.Context, runID string, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	RecordDeliveryAttempt(ctx context.Context, d models.WebhookDelivery) error
+}
+
+// Config configures webhook delivery
+type Config struct {
+	Timeout        time.Duration // bounds a single attempt
+	MaxAttempts    int           // attempts before a delivery fails for good
+	RetryBaseDelay time.Duration // wait before the second attempt, doubled for every further one
+	RetryMaxDelay  time.Duration // caps the wait between two attempts
+	PollInterval   time.Duration // how often Run looks for deliveries due
+
+	// AllowPrivateTargets lets subscriptions reach loopback, link-local and private addresses, for receivers on
+	// the service's own network
+	AllowPrivateTargets bool
+}
+
+// Results of a delivery attempt as counted in metrics
+const (
+	resultDelivered = "delivered"
+	resultRetry     = "retry"
+	resultFailed    = "failed"
+)
+
+// claimBatch is the number of deliveries claimed and sent at once
+const claimBatch = 20
+
+// maxResponseBody is how much of a response is read before the connection is reused
+const maxResponseBody = 64 << 10
+
+// Notifier turns the rates stored by a fetch run into webhook deliveries and sends them
+type Notifier struct {
+	logger *slog.Logger
+	store  Store
+	client *http.Client
+	cfg    Config
+
+	now func() time.Time // stamps payloads and attempts, replaced in tests
+}
+
+func NewNotifier(logger *slog.Logger, store Store, cfg Config) *Notifier {
+	return &Notifier{
+		logger: logger.With(slog.String("component", "webhooks")),
+		store:  store,
+		client: newClient(cfg),
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Notify records a delivery for every subscription the rates stored by run runID concern and makes the first
+// attempt of up to one batch of them right away. The rest and the retries are left to Run.
+func (n *Notifier) Notify(ctx context.Context, runID string) error {
+	revisions, err := n.store.RunRevisions(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	return n.notify(ctx, runID, revisions)
+}
+
+// NotifyApproval is Notify for a held rate stored once its anomaly was approved. Only the approved rate is
+// announced, the other rates of the run that held it were announced when it stored them.
+func (n *Notifier) NotifyApproval(ctx context.Context, anomaly models.RateAnomaly) error {
+	revisions, err := n.store.RunRevisions(ctx, anomaly.RunID)
+	if err != nil {
+		return err
+	}
+
+	approved := slices.DeleteFunc(revisions, func(rev models.RateRevision) bool {
+		return rev.Currency != anomaly.Currency || !rev.Date.Equal(anomaly.Date) || !rev.NewRate.Equal(anomaly.Rate)
+	})
+
+	return n.notify(ctx, anomaly.RunID, approved)
+}
+
+func (n *Notifier) notify(ctx context.Context, runID string, revisions []models.RateRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	subs, err := n.store.ListSubscriptions(ctx)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+
+	changes, err := n.rateChanges(ctx, revisions)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := n.deliveries(runID, subs, changes)
+	if err != nil {
+		return err
+	}
+
+	if err := n.store.EnqueueDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+
+	// The attempts are bounded by the client timeout, not by the deadline of the fetch that stored the rates,
+	// which would cut them short and count them as failed
+	ctx = context.WithoutCancel(ctx)
+
+	claimed, err := n.store.ClaimRunDeliveries(ctx, runID, n.lease(), claimBatch)
+	if err != nil {
+		return err
+	}
+
+	n.attemptAll(ctx, claimed)
+
+	return nil
+}
+
+// rateChanges pairs every revision with the rate stored for the previous day
+func (n *Notifier) rateChanges(ctx context.Context, revisions []models.RateRevision) ([]RateChange, error) {
+	changes := make([]RateChange, 0, len(revisions))
+
+	for _, rev := range revisions {
+		recent, err := n.store.RecentRates(ctx, rev.Currency, rev.Date, 2)
+		if err != nil {
+			return nil, fmt.Errorf("read previous %s rate: %w", rev.Currency, err)
+		}
+
+		var previous decimal.NullDecimal
+		for _, rate := range recent {
+			if rate.Date.Before(rev.Date) {
+				previous = decimal.NewNullDecimal(rate.Rate)
+
+				break
+			}
+		}
+
+		changes = append(changes, newRateChange(rev, previous))
+	}
+
+	return changes, nil
+}
+
+// deliveries builds one delivery per subscription with the stored rates meeting its condition
+func (n *Notifier) deliveries(runID string, subs []models.Subscription, changes []RateChange) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	for _, sub := range subs {
+		var matched []RateChange
+		for _, change := range changes {
+			if matches(sub, change) {
+				matched = append(matched, change)
+			}
+		}
+
+		if len(matched) == 0 {
+			continue
+		}
+
+		event := Event{
+			Event:          eventName(sub.Condition),
+			SubscriptionID: sub.ID,
+			RunID:          runID,
+			Condition:      sub.Condition,
+			Threshold:      sub.Threshold,
+			Rates:          matched,
+			CreatedAt:      n.now().UTC(),
+		}
+
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("encode webhook payload: %w", err)
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			RunID:          runID,
+			Event:          event.Event,
+			Payload:        string(payload),
+		})
+	}
+
+	return deliveries, nil
+}
+
+// DeliverDue sends every delivery that is due, concurrently in batches
+func (n *Notifier) DeliverDue(ctx context.Context) error {
+	for {
+		deliveries, err := n.store.ClaimDeliveries(ctx, n.lease(), claimBatch)
+		if err != nil {
+			return err
+		}
+
+		n.attemptAll(ctx, deliveries)
+
+		if len(deliveries) < claimBatch {
+			return nil
+		}
+	}
+}
+
+// lease is how long a claimed delivery is not picked up again, time enough for its attempt to finish
+func (n *Notifier) lease() time.Duration {
+	return n.cfg.Timeout + time.Minute
+}
+
+// attemptAll sends deliveries concurrently and waits for every attempt
+func (n *Notifier) attemptAll(ctx context.Context, deliveries []models.WebhookDelivery) {
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Go(func() { n.attempt(ctx, d) })
+	}
+
+	wg.Wait()
+}
+
+// Run sends the deliveries that are due every poll interval until ctx is done
+func (n *Notifier) Run(ctx context.Context) error {
+	ticker := time.NewTicker(n.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := n.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			n.logger.Error("failed to send webhook deliveries", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome
+func (n *Notifier) attempt(ctx context.Context, d models.WebhookDelivery) {
+	status, err := n.send(ctx, d)
+	now := n.now().UTC()
+
+	d.Attempts++
+	d.ResponseStatus = status
+	d.LastError = ""
+
+	result := resultDelivered
+
+	switch {
+	case err == nil:
+		d.Status = models.DeliveryDelivered
+		d.DeliveredAt = &now
+	case d.Attempts >= n.cfg.MaxAttempts, errors.Is(err, ErrPrivateTarget):
+		// A target refused once is refused on every attempt
+		result = resultFailed
+		d.Status = models.DeliveryFailed
+		d.LastError = err.Error()
+	default:
+		result = resultRetry
+		d.Status = models.DeliveryPending
+		d.LastError = err.Error()
+		d.NextAttemptAt = now.Add(n.backoff(d.Attempts))
+	}
+
+	metrics.WebhookDeliveries.WithLabelValues(result).Inc()
+
+	logger := n.logger.With(
+		slog.Int64("delivery_id", d.ID),
+		slog.Int64("subscription_id", d.SubscriptionID),
+		slog.Int("attempt", d.Attempts))
+
+	if err != nil {
+		logger.Warn("webhook delivery failed", slog.String("result", result), slog.Any("error", err))
+	} else {
+		logger.Info("webhook delivered", slog.Int("status", status))
+	}
+
+	// The outcome is recorded even when ctx ended the attempt, otherwise it would be sent again once the lease ends
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := n.store.RecordDeliveryAttempt(recordCtx, d); err != nil {
+		logger.Error("failed to record webhook delivery attempt", slog.Any("error", err))
+	}
+}
+
+// send posts the signed payload and returns the response status, an error unless it is a 2xx
+func (n *Notifier) send(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	sentAt := n.now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "currency-service-webhooks")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(sentAt.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, sentAt, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close() // nolint:errcheck // We can't do much about a close error here
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New("unexpected response status " + resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (n *Notifier) backoff(attempts int) time.Duration {
+	delay := n.cfg.RetryBaseDelay
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= n.cfg.RetryMaxDelay {
+			return n.cfg.RetryMaxDelay
+		}
+	}
+
+	return min(delay, n.cfg.RetryMaxDelay)
+}
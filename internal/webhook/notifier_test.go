@@ -0,0 +1,545 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VladislavsPerkanuks/Backscreen-Task/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore keeps subscriptions, rates and deliveries in memory
+type mockStore struct {
+	mu sync.Mutex
+
+	subs       []models.Subscription
+	revisions  []models.RateRevision
+	rates      []models.ExchangeRate
+	deliveries []models.WebhookDelivery
+	now        time.Time
+}
+
+func (m *mockStore) ListSubscriptions(ctx context.Context) ([]models.Subscription, error) {
+	return m.subs, nil
+}
+
+func (m *mockStore) RunRevisions(ctx context.Context, runID string) ([]models.RateRevision, error) {
+	var revisions []models.RateRevision
+	for _, rev := range m.revisions {
+		if rev.RunID == runID {
+			revisions = append(revisions, rev)
+		}
+	}
+
+	return revisions, nil
+}
+
+func (m *mockStore) RecentRates(ctx context.Context, currency string, through time.Time, limit int) ([]models.ExchangeRate, error) {
+	var recent []models.ExchangeRate
+	for _, rate := range slices.Backward(m.rates) {
+		if rate.Currency == currency && !rate.Date.After(through) && len(recent) < limit {
+			recent = append(recent, rate)
+		}
+	}
+
+	return recent, nil
+}
+
+func (m *mockStore) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range deliveries {
+		d.ID = int64(len(m.deliveries) + 1)
+		d.Status = models.DeliveryPending
+		d.NextAttemptAt = m.now
+
+		for _, sub := range m.subs {
+			if sub.ID == d.SubscriptionID {
+				d.URL, d.Secret = sub.URL, sub.Secret
+			}
+		}
+
+		m.deliveries = append(m.deliveries, d)
+	}
+
+	return nil
+}
+
+func (m *mockStore) ClaimDeliveries(ctx context.Context, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	return m.claim("", lease, limit)
+}
+
+func (m *mockStore) ClaimRunDeliveries(ctx context.Context, runID string, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	return m.claim(runID, lease, limit)
+}
+
+func (m *mockStore) claim(runID string, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimed []models.WebhookDelivery
+	for i, d := range m.deliveries {
+		if runID != "" && d.RunID != runID {
+			continue
+		}
+
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(m.now) && len(claimed) < limit {
+			m.deliveries[i].NextAttemptAt = m.now.Add(lease)
+			claimed = append(claimed, m.deliveries[i])
+		}
+	}
+
+	return claimed, nil
+}
+
+func (m *mockStore) RecordDeliveryAttempt(ctx context.Context, d models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deliveries[d.ID-1] = d
+
+	return nil
+}
+
+func day(d int) time.Time {
+	return time.Date(2026, 2, d, 0, 0, 0, 0, time.UTC)
+}
+
+func threshold(s string) decimal.NullDecimal {
+	return decimal.NewNullDecimal(decimal.RequireFromString(s))
+}
+
+func TestMatches(t *testing.T) {
+	t.Parallel()
+
+	change := func(currency, previous, rate string) RateChange {
+		rev := models.RateRevision{Currency: currency, Date: day(3), NewRate: decimal.RequireFromString(rate)}
+
+		var prev decimal.NullDecimal
+		if previous != "" {
+			prev = threshold(previous)
+		}
+
+		return newRateChange(rev, prev)
+	}
+
+	tests := []struct {
+		name     string
+		sub      models.Subscription
+		change   RateChange
+		expected bool
+	}{
+		{
+			name:     "Published",
+			sub:      models.Subscription{Condition: models.ConditionPublished},
+			change:   change("USD", "", "1.18"),
+			expected: true,
+		},
+		{
+			name:     "Other currency",
+			sub:      models.Subscription{Condition: models.ConditionPublished, Currencies: []string{"GBP", "JPY"}},
+			change:   change("USD", "1.17", "1.18"),
+			expected: false,
+		},
+		{
+			name:     "Change above the threshold",
+			sub:      models.Subscription{Condition: models.ConditionChange, Threshold: threshold("0.5")},
+			change:   change("USD", "1.18", "1.1729"),
+			expected: true,
+		},
+		{
+			name:     "Change within the threshold",
+			sub:      models.Subscription{Condition: models.ConditionChange, Threshold: threshold("0.5")},
+			change:   change("USD", "1.18", "1.1850"),
+			expected: false,
+		},
+		{
+			name:     "Change of the first rate",
+			sub:      models.Subscription{Condition: models.ConditionChange, Threshold: threshold("0.5")},
+			change:   change("USD", "", "1.18"),
+			expected: false,
+		},
+		{
+			name:     "Crossing upwards",
+			sub:      models.Subscription{Condition: models.ConditionCrossing, Threshold: threshold("1.2")},
+			change:   change("USD", "1.19", "1.20"),
+			expected: true,
+		},
+		{
+			name:     "Crossing downwards",
+			sub:      models.Subscription{Condition: models.ConditionCrossing, Threshold: threshold("1.2")},
+			change:   change("USD", "1.21", "1.19"),
+			expected: true,
+		},
+		{
+			name:     "Staying above the level",
+			sub:      models.Subscription{Condition: models.ConditionCrossing, Threshold: threshold("1.2")},
+			change:   change("USD", "1.20", "1.21"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, matches(tt.sub, tt.change))
+		})
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+
+	var (
+		mu       sync.Mutex
+		received []request
+		failing  = true
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		received = append(received, request{header: r.Header, body: body})
+
+		if r.URL.Path == "/gone" || failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 2, 3, 16, 20, 0, 0, time.UTC)
+
+	store := &mockStore{
+		subs: []models.Subscription{
+			{ID: 1, URL: server.URL + "/treasury", Condition: models.ConditionChange, Threshold: threshold("0.5"), Secret: "treasury-secret-1"},
+			{ID: 2, URL: server.URL + "/gone", Condition: models.ConditionPublished, Currencies: []string{"GBP"}, Secret: "gone-secret-12345"},
+			{ID: 3, URL: server.URL + "/never", Condition: models.ConditionCrossing, Threshold: threshold("2"), Secret: "never-secret-1234"},
+		},
+		revisions: []models.RateRevision{
+			{Currency: "USD", Date: day(3), NewRate: decimal.RequireFromString("1.1729"), RunID: "run-1"},
+			{Currency: "GBP", Date: day(3), NewRate: decimal.RequireFromString("0.8658"), RunID: "run-1"},
+		},
+		rates: []models.ExchangeRate{
+			{Currency: "USD", Rate: decimal.RequireFromString("1.18"), Date: day(2)},
+			{Currency: "GBP", Rate: decimal.RequireFromString("0.8650"), Date: day(2)},
+			{Currency: "USD", Rate: decimal.RequireFromString("1.1729"), Date: day(3)},
+			{Currency: "GBP", Rate: decimal.RequireFromString("0.8658"), Date: day(3)},
+		},
+		now: now,
+	}
+
+	notifier := NewNotifier(slog.Default(), store, Config{
+		Timeout:        time.Second,
+		MaxAttempts:    2,
+		RetryBaseDelay: 30 * time.Second,
+		RetryMaxDelay:  time.Hour,
+		PollInterval:   time.Second,
+
+		AllowPrivateTargets: true, // the test server listens on loopback
+	})
+	notifier.now = func() time.Time { return now }
+
+	require.NoError(t, notifier.Notify(t.Context(), "run-1"))
+
+	// The crossing subscription is not concerned, the others failed their first attempt
+	require.Len(t, store.deliveries, 2)
+	require.Len(t, received, 2)
+
+	for _, d := range store.deliveries {
+		require.Equal(t, models.DeliveryPending, d.Status)
+		require.Equal(t, 1, d.Attempts)
+		require.Equal(t, http.StatusServiceUnavailable, d.ResponseStatus)
+		require.Equal(t, "unexpected response status 503 Service Unavailable", d.LastError)
+		require.Equal(t, now.Add(30*time.Second), d.NextAttemptAt)
+	}
+
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(store.deliveries[0].Payload), &event))
+	require.Equal(t, "rates.change", event.Event)
+	require.Equal(t, "run-1", event.RunID)
+	require.Len(t, event.Rates, 1)
+	require.Equal(t, "USD", event.Rates[0].Currency)
+	require.Equal(t, "-0.6017", event.Rates[0].ChangePercent.Decimal.String())
+	require.Equal(t, "1.18", event.Rates[0].PreviousRate.Decimal.String())
+
+	// Nothing is due before the backoff has passed
+	require.NoError(t, notifier.DeliverDue(t.Context()))
+	require.Len(t, received, 2)
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	now = now.Add(time.Minute)
+	store.now = now
+
+	require.NoError(t, notifier.DeliverDue(t.Context()))
+	require.Len(t, received, 4)
+
+	treasury, gone := store.deliveries[0], store.deliveries[1]
+
+	require.Equal(t, models.DeliveryDelivered, treasury.Status)
+	require.Equal(t, 2, treasury.Attempts)
+	require.Equal(t, http.StatusNoContent, treasury.ResponseStatus)
+	require.Empty(t, treasury.LastError)
+	require.Equal(t, now, *treasury.DeliveredAt)
+
+	require.Equal(t, models.DeliveryFailed, gone.Status, "out of attempts")
+	require.Equal(t, 2, gone.Attempts)
+
+	for _, req := range received {
+		id, err := strconv.ParseInt(req.header.Get(HeaderDelivery), 10, 64)
+		require.NoError(t, err)
+
+		d := store.deliveries[id-1]
+		require.Equal(t, d.Payload, string(req.body))
+		require.Equal(t, d.Event, req.header.Get(HeaderEvent))
+		require.Equal(t, "application/json", req.header.Get("Content-Type"))
+
+		sentAt, err := strconv.ParseInt(req.header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		require.Equal(t, Sign(d.Secret, time.Unix(sentAt, 0), req.body), req.header.Get(HeaderSignature))
+	}
+}
+
+func TestNotifierSendsOnlyItsRun(t *testing.T) {
+	t.Parallel()
+
+	var received atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 2, 3, 16, 20, 0, 0, time.UTC)
+
+	store := &mockStore{
+		subs: []models.Subscription{
+			{ID: 1, URL: server.URL, Condition: models.ConditionPublished, Secret: "treasury-secret-1"},
+		},
+		revisions: []models.RateRevision{
+			{Currency: "USD", Date: day(3), NewRate: decimal.RequireFromString("1.1729"), RunID: "run-2"},
+		},
+		now: now,
+	}
+
+	// A retry of an earlier run that is due, left to Run
+	require.NoError(t, store.EnqueueDeliveries(t.Context(), []models.WebhookDelivery{
+		{SubscriptionID: 1, RunID: "run-1", Event: "rates.published", Payload: "{}"},
+	}))
+
+	notifier := NewNotifier(slog.Default(), store, Config{
+		Timeout: time.Second, MaxAttempts: 3, PollInterval: time.Second, AllowPrivateTargets: true,
+	})
+	notifier.now = func() time.Time { return now }
+
+	// The fetch running out of time does not cut the first attempt short
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	require.NoError(t, notifier.Notify(ctx, "run-2"))
+	require.EqualValues(t, 1, received.Load())
+
+	earlier, current := store.deliveries[0], store.deliveries[1]
+
+	require.Equal(t, models.DeliveryPending, earlier.Status)
+	require.Zero(t, earlier.Attempts)
+
+	require.Equal(t, "run-2", current.RunID)
+	require.Equal(t, models.DeliveryDelivered, current.Status)
+	require.Equal(t, 1, current.Attempts)
+}
+
+func TestNotifyApproval(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		received []Event
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		_ = json.NewDecoder(r.Body).Decode(&event)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		received = append(received, event)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := &mockStore{
+		subs: []models.Subscription{
+			{ID: 1, URL: server.URL, Condition: models.ConditionPublished, Secret: "treasury-secret-1"},
+		},
+		// USD was stored by the run, GBP was held by it and stored once approved
+		revisions: []models.RateRevision{
+			{Currency: "USD", Date: day(3), NewRate: decimal.RequireFromString("1.1729"), RunID: "run-1"},
+			{Currency: "GBP", Date: day(3), NewRate: decimal.RequireFromString("0.9658"), RunID: "run-1"},
+		},
+	}
+
+	notifier := NewNotifier(slog.Default(), store, Config{
+		Timeout: time.Second, MaxAttempts: 3, PollInterval: time.Second, AllowPrivateTargets: true,
+	})
+
+	require.NoError(t, notifier.NotifyApproval(t.Context(), models.RateAnomaly{
+		ID:       7,
+		RunID:    "run-1",
+		Currency: "GBP",
+		Date:     day(3),
+		Rate:     decimal.RequireFromString("0.9658"),
+		Status:   models.AnomalyApproved,
+	}))
+
+	require.Len(t, store.deliveries, 1)
+	require.Len(t, received, 1)
+	require.Equal(t, "run-1", received[0].RunID)
+	require.Len(t, received[0].Rates, 1)
+	require.Equal(t, "GBP", received[0].Rates[0].Currency)
+}
+
+func TestNotifierRefusesPrivateTargets(t *testing.T) {
+	t.Parallel()
+
+	var received atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The subscription was accepted while its host resolved to a public address, it now points at loopback
+	store := &mockStore{
+		subs: []models.Subscription{
+			{ID: 1, URL: server.URL, Condition: models.ConditionPublished, Secret: "treasury-secret-1"},
+		},
+		revisions: []models.RateRevision{
+			{Currency: "USD", Date: day(3), NewRate: decimal.RequireFromString("1.1729"), RunID: "run-1"},
+		},
+	}
+
+	notifier := NewNotifier(slog.Default(), store, Config{Timeout: time.Second, MaxAttempts: 3, PollInterval: time.Second})
+
+	require.NoError(t, notifier.Notify(t.Context(), "run-1"))
+	require.Zero(t, received.Load())
+
+	d := store.deliveries[0]
+	require.Equal(t, models.DeliveryFailed, d.Status, "a refused target is not retried")
+	require.Equal(t, 1, d.Attempts)
+	require.Contains(t, d.LastError, ErrPrivateTarget.Error())
+}
+
+func TestCheckTarget(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		url       string
+		expectErr error
+	}{
+		{name: "public address", url: "https://93.184.215.14/hooks"},
+		{name: "public IPv6 address", url: "https://[2606:2800:21f:cb07:6820:80da:af6b:8b2c]/hooks"},
+		{name: "loopback", url: "http://127.0.0.1:3306/", expectErr: ErrPrivateTarget},
+		{name: "localhost", url: "http://localhost:8080/admin", expectErr: ErrPrivateTarget},
+		{name: "IPv6 loopback", url: "http://[::1]/", expectErr: ErrPrivateTarget},
+		{name: "IPv4-mapped loopback", url: "http://[::ffff:127.0.0.1]/", expectErr: ErrPrivateTarget},
+		{name: "link-local metadata endpoint", url: "http://169.254.169.254/latest/meta-data", expectErr: ErrPrivateTarget},
+		{name: "private network", url: "https://10.0.0.5/hooks", expectErr: ErrPrivateTarget},
+		{name: "private IPv6 network", url: "https://[fd00::1]/hooks", expectErr: ErrPrivateTarget},
+		{name: "unspecified", url: "http://0.0.0.0:8080/", expectErr: ErrPrivateTarget},
+	}
+
+	notifier := NewNotifier(slog.Default(), &mockStore{}, Config{Timeout: time.Second})
+	permissive := NewNotifier(slog.Default(), &mockStore{}, Config{Timeout: time.Second, AllowPrivateTargets: true})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			target, err := url.Parse(tt.url)
+			require.NoError(t, err)
+
+			if tt.expectErr != nil {
+				require.ErrorIs(t, notifier.CheckTarget(t.Context(), target), tt.expectErr)
+			} else {
+				require.NoError(t, notifier.CheckTarget(t.Context(), target))
+			}
+
+			require.NoError(t, permissive.CheckTarget(t.Context(), target))
+		})
+	}
+}
+
+func TestNotifierWithoutSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{
+		revisions: []models.RateRevision{{Currency: "USD", Date: day(3), NewRate: decimal.RequireFromString("1.18"), RunID: "run-1"}},
+	}
+
+	require.NoError(t, NewNotifier(slog.Default(), store, Config{MaxAttempts: 1}).Notify(t.Context(), "run-1"))
+	require.Empty(t, store.deliveries)
+}
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	at := time.Unix(1770135600, 0)
+	body := []byte(`{"event":"rates.published"}`)
+
+	signature := Sign("secret", at, body)
+	require.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+	require.Equal(t, signature, Sign("secret", at, body))
+	require.NotEqual(t, signature, Sign("other", at, body))
+	require.NotEqual(t, signature, Sign("secret", at.Add(time.Second), body))
+
+	require.Len(t, NewSecret(), 64)
+	require.NotEqual(t, NewSecret(), NewSecret())
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	notifier := NewNotifier(slog.Default(), &mockStore{}, Config{RetryBaseDelay: 30 * time.Second, RetryMaxDelay: 5 * time.Minute})
+
+	var delays []time.Duration
+	for attempts := 1; attempts <= 6; attempts++ {
+		delays = append(delays, notifier.backoff(attempts))
+	}
+
+	require.Equal(t, []time.Duration{
+		30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute,
+	}, delays)
+}
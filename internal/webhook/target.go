@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateTarget is returned for a webhook URL that reaches into the network the service runs in
+var ErrPrivateTarget = errors.New("webhook target is not a public address")
+
+// publicAddress reports whether ip may receive webhooks. Loopback, link-local, private, unspecified and multicast
+// addresses belong to the network the service runs in, a subscriber could make it post to its database or to the
+// cloud metadata endpoint.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsPrivate() &&
+		!ip.IsUnspecified()
+}
+
+// CheckTarget resolves the host of a webhook URL and rejects it unless every address it resolves to is public.
+// Deliveries check the address they connect to again, which also covers DNS rebinding and redirects.
+func (n *Notifier) CheckTarget(ctx context.Context, target *url.URL) error {
+	if n.cfg.AllowPrivateTargets {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", target.Hostname(), err)
+	}
+
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateTarget, target.Hostname(), addr)
+		}
+	}
+
+	return nil
+}
+
+// refusePrivate is a net.Dialer Control refusing to connect to addresses that are not public
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("parse dialed address: %w", err)
+	}
+
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// newClient returns the client deliveries are sent with, connecting only to public addresses unless
+// cfg.AllowPrivateTargets
+func newClient(cfg Config) *http.Client {
+	if cfg.AllowPrivateTargets {
+		return &http.Client{Timeout: cfg.Timeout}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	// Through a proxy the address dialed and checked would be the proxy's, not the subscriber's
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   refusePrivate,
+	}).DialContext
+
+	return &http.Client{Timeout: cfg.Timeout, Transport: transport}
+}
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS exchange_rate_revisions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    currency VARCHAR(3) NOT NULL,
+    date DATETIME NOT NULL,
+    old_rate DECIMAL(20, 8) NULL,
+    new_rate DECIMAL(20, 8) NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT '',
+    run_id VARCHAR(36) NULL,
+    revised_at DATETIME(6) NOT NULL,
+    INDEX idx_currency_date (currency, date),
+    INDEX idx_run_id (run_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Rates stored before revisions were tracked get their first value as the initial revision
+INSERT INTO exchange_rate_revisions (currency, date, old_rate, new_rate, revised_at)
+SELECT currency, date, NULL, rate, created_at FROM exchange_rates ORDER BY id;
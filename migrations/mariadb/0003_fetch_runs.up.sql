@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS fetch_runs (
+    id VARCHAR(36) PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT '',
+    source_url VARCHAR(2048) NOT NULL DEFAULT '',
+    currencies TEXT NOT NULL,
+    started_at DATETIME(6) NOT NULL,
+    finished_at DATETIME(6) NULL,
+    inserted INT NOT NULL DEFAULT 0,
+    updated INT NOT NULL DEFAULT 0,
+    unchanged INT NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    currency_errors TEXT NULL,
+    INDEX idx_started_at (started_at),
+    INDEX idx_status_started_at (status, started_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS quarantined_items (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    run_id VARCHAR(36) NULL,
+    source VARCHAR(32) NOT NULL,
+    item TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    quarantined_at DATETIME(6) NOT NULL,
+    INDEX idx_quarantined_at (quarantined_at),
+    INDEX idx_run_id (run_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE fetch_runs ADD COLUMN quarantined INT NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS rate_discrepancies (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    run_id VARCHAR(36) NULL,
+    currency VARCHAR(3) NOT NULL,
+    date DATETIME NOT NULL,
+    quotes TEXT NOT NULL,
+    chosen DECIMAL(20, 8) NOT NULL,
+    chosen_source VARCHAR(32) NOT NULL,
+    policy VARCHAR(16) NOT NULL,
+    spread DECIMAL(20, 8) NOT NULL,
+    detected_at DATETIME(6) NOT NULL,
+    INDEX idx_currency_date (currency, date),
+    INDEX idx_run_id (run_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS rate_anomalies (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    run_id VARCHAR(36) NULL,
+    currency VARCHAR(3) NOT NULL,
+    date DATETIME NOT NULL,
+    rate DECIMAL(20, 8) NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL,
+    detected_at DATETIME(6) NOT NULL,
+    resolved_at DATETIME(6) NULL,
+    INDEX idx_status (status),
+    INDEX idx_currency_date (currency, date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE fetch_runs ADD COLUMN flagged INT NOT NULL DEFAULT 0;
+ALTER TABLE fetch_runs ADD COLUMN held INT NOT NULL DEFAULT 0;
+ALTER TABLE fetch_runs ADD COLUMN rejected INT NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Writers of exchange_rate_revisions lock this row first, so ids are handed out in the order revisions commit
+CREATE TABLE IF NOT EXISTS revision_lock (
+    id INT PRIMARY KEY
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO revision_lock (id) VALUES (1);
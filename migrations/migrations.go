@@ -0,0 +1,21 @@
+// Package migrations embeds the versioned SQL schema migrations, one directory per database driver.
+// Files are named NNNN_description.up.sql with an optional NNNN_description.down.sql counterpart.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed mariadb/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// ForDriver returns the migrations of a database driver, e.g. "mariadb", "postgres" or "sqlite"
+func ForDriver(driver string) (fs.FS, error) {
+	if _, err := fs.Stat(files, driver); err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+
+	return fs.Sub(files, driver)
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id BIGSERIAL PRIMARY KEY,
+    currency VARCHAR(3) NOT NULL,
+    rate NUMERIC(20, 8) NOT NULL,
+    date DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uk_currency_date UNIQUE (currency, date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_date ON exchange_rates (date);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS exchange_rate_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    currency VARCHAR(3) NOT NULL,
+    date DATE NOT NULL,
+    old_rate NUMERIC(20, 8),
+    new_rate NUMERIC(20, 8) NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT '',
+    run_id VARCHAR(36),
+    revised_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revisions_currency_date ON exchange_rate_revisions (currency, date);
+CREATE INDEX IF NOT EXISTS idx_revisions_run_id ON exchange_rate_revisions (run_id);
+
+-- Rates stored before revisions were tracked get their first value as the initial revision
+INSERT INTO exchange_rate_revisions (currency, date, old_rate, new_rate, revised_at)
+SELECT currency, date, NULL, rate, created_at FROM exchange_rates ORDER BY id;
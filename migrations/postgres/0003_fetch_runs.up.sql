@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS fetch_runs (
+    id VARCHAR(36) PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT '',
+    source_url TEXT NOT NULL DEFAULT '',
+    currencies TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    inserted INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    unchanged INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    currency_errors TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_fetch_runs_started_at ON fetch_runs (started_at);
+CREATE INDEX IF NOT EXISTS idx_fetch_runs_status_started_at ON fetch_runs (status, started_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS quarantined_items (
+    id BIGSERIAL PRIMARY KEY,
+    run_id VARCHAR(36),
+    source VARCHAR(32) NOT NULL,
+    item TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    quarantined_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantined_items_quarantined_at ON quarantined_items (quarantined_at);
+CREATE INDEX IF NOT EXISTS idx_quarantined_items_run_id ON quarantined_items (run_id);
+
+ALTER TABLE fetch_runs ADD COLUMN quarantined INTEGER NOT NULL DEFAULT 0;
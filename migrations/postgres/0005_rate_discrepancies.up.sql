@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS rate_discrepancies (
+    id BIGSERIAL PRIMARY KEY,
+    run_id VARCHAR(36),
+    currency VARCHAR(3) NOT NULL,
+    date DATE NOT NULL,
+    quotes TEXT NOT NULL,
+    chosen NUMERIC(20, 8) NOT NULL,
+    chosen_source VARCHAR(32) NOT NULL,
+    policy VARCHAR(16) NOT NULL,
+    spread NUMERIC(20, 8) NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_discrepancies_currency_date ON rate_discrepancies (currency, date);
+CREATE INDEX IF NOT EXISTS idx_rate_discrepancies_run_id ON rate_discrepancies (run_id);
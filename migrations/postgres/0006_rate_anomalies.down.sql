@@ -0,0 +1,5 @@
+ALTER TABLE fetch_runs DROP COLUMN rejected;
+ALTER TABLE fetch_runs DROP COLUMN held;
+ALTER TABLE fetch_runs DROP COLUMN flagged;
+
+DROP TABLE IF EXISTS rate_anomalies;
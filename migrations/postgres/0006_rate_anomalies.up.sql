@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS rate_anomalies (
+    id BIGSERIAL PRIMARY KEY,
+    run_id VARCHAR(36),
+    currency VARCHAR(3) NOT NULL,
+    date DATE NOT NULL,
+    rate NUMERIC(20, 8) NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_anomalies_status ON rate_anomalies (status);
+CREATE INDEX IF NOT EXISTS idx_rate_anomalies_currency_date ON rate_anomalies (currency, date);
+
+ALTER TABLE fetch_runs ADD COLUMN flagged INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE fetch_runs ADD COLUMN held INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE fetch_runs ADD COLUMN rejected INTEGER NOT NULL DEFAULT 0;
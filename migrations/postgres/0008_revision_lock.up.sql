@@ -0,0 +1,6 @@
+-- Writers of exchange_rate_revisions lock this row first, so ids are handed out in the order revisions commit
+CREATE TABLE IF NOT EXISTS revision_lock (
+    id INTEGER PRIMARY KEY
+);
+
+INSERT INTO revision_lock (id) VALUES (1);
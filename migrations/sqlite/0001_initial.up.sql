@@ -0,0 +1,11 @@
+-- Rates are stored as text to keep their exact decimal value, dates as UTC "YYYY-MM-DD HH:MM:SS" text
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency TEXT NOT NULL,
+    rate TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uk_currency_date UNIQUE (currency, date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_date ON exchange_rates (date);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS exchange_rate_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    old_rate TEXT,
+    new_rate TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    run_id TEXT,
+    revised_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revisions_currency_date ON exchange_rate_revisions (currency, date);
+CREATE INDEX IF NOT EXISTS idx_revisions_run_id ON exchange_rate_revisions (run_id);
+
+-- Rates stored before revisions were tracked get their first value as the initial revision
+INSERT INTO exchange_rate_revisions (currency, date, old_rate, new_rate, revised_at)
+SELECT currency, date, NULL, rate, created_at FROM exchange_rates ORDER BY id;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS fetch_runs (
+    id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    source_url TEXT NOT NULL DEFAULT '',
+    currencies TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    inserted INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    unchanged INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    currency_errors TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_fetch_runs_started_at ON fetch_runs (started_at);
+CREATE INDEX IF NOT EXISTS idx_fetch_runs_status_started_at ON fetch_runs (status, started_at);
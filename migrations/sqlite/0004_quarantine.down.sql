@@ -0,0 +1,3 @@
+ALTER TABLE fetch_runs DROP COLUMN quarantined;
+
+DROP TABLE IF EXISTS quarantined_items;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS quarantined_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT,
+    source TEXT NOT NULL,
+    item TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    quarantined_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantined_items_quarantined_at ON quarantined_items (quarantined_at);
+CREATE INDEX IF NOT EXISTS idx_quarantined_items_run_id ON quarantined_items (run_id);
+
+ALTER TABLE fetch_runs ADD COLUMN quarantined INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS rate_discrepancies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT,
+    currency TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    quotes TEXT NOT NULL,
+    chosen TEXT NOT NULL,
+    chosen_source TEXT NOT NULL,
+    policy TEXT NOT NULL,
+    spread TEXT NOT NULL,
+    detected_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_discrepancies_currency_date ON rate_discrepancies (currency, date);
+CREATE INDEX IF NOT EXISTS idx_rate_discrepancies_run_id ON rate_discrepancies (run_id);
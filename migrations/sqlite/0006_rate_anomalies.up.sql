@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS rate_anomalies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT,
+    currency TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    rate TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    detected_at DATETIME NOT NULL,
+    resolved_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_anomalies_status ON rate_anomalies (status);
+CREATE INDEX IF NOT EXISTS idx_rate_anomalies_currency_date ON rate_anomalies (currency, date);
+
+ALTER TABLE fetch_runs ADD COLUMN flagged INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE fetch_runs ADD COLUMN held INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE fetch_runs ADD COLUMN rejected INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Kept for parity with the other engines, immediate transactions already serialise revision writers here
+CREATE TABLE IF NOT EXISTS revision_lock (
+    id INTEGER PRIMARY KEY
+);
+
+INSERT INTO revision_lock (id) VALUES (1);